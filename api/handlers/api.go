@@ -3,6 +3,10 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
@@ -17,7 +21,7 @@ import (
 )
 
 type NotificationService interface {
-	Dispatch(ctx context.Context, input services.DispatchInput) error
+	Dispatch(ctx context.Context, input services.DispatchInput) (string, error)
 }
 
 type TemplateRepository interface {
@@ -27,12 +31,16 @@ type TemplateRepository interface {
 
 type WorkflowRepository interface {
 	Save(ctx context.Context, wf workflow.Workflow) (workflow.Workflow, error)
+	SaveIfMatch(ctx context.Context, wf workflow.Workflow, revision int) (workflow.Workflow, error)
 	FindByID(ctx context.Context, id string) (workflow.Workflow, error)
 	List(ctx context.Context) ([]workflow.Workflow, error)
 	Delete(ctx context.Context, id string) error
 	ListVersions(ctx context.Context, workflowID string) ([]workflow.VersionMeta, error)
 	GetVersion(ctx context.Context, workflowID, versionID string) (workflow.Version, error)
 	RestoreVersion(ctx context.Context, workflowID, versionID string) (workflow.Workflow, error)
+	PublishVersion(ctx context.Context, workflowID, versionID string) (workflow.Workflow, error)
+	UpdateVersion(ctx context.Context, workflowID, versionID string, update workflow.VersionUpdate) (workflow.VersionMeta, error)
+	FindPublished(ctx context.Context, id string) (workflow.Workflow, error)
 }
 
 type QueueInspector interface {
@@ -78,6 +86,13 @@ type API struct {
 	streamHub     *stream.Hub
 	redisStore    *stream.RedisStore
 	storage       StorageReader
+	executions    ExecutionReader
+	tester        WorkflowTester
+	bundles       WorkflowBundler
+	deadLetters   DeadLetterStore
+	streamAdmin   StreamAdmin
+	eventSchemas  EventSchemaRegistry
+	streamHealth  StreamHealth
 }
 
 type StreamConfig struct {
@@ -85,7 +100,7 @@ type StreamConfig struct {
 	Topic   string
 }
 
-func NewAPI(notificationSvc NotificationService, tplRepo TemplateRepository, wfRepo WorkflowRepository, queueInspector QueueInspector, serviceConfigRepo ServiceConfigRepository, channelRepo ChannelRepository, streamConfig StreamConfig, streamHub *stream.Hub, redisStore *stream.RedisStore, storageReader StorageReader) *API {
+func NewAPI(notificationSvc NotificationService, tplRepo TemplateRepository, wfRepo WorkflowRepository, queueInspector QueueInspector, serviceConfigRepo ServiceConfigRepository, channelRepo ChannelRepository, streamConfig StreamConfig, streamHub *stream.Hub, redisStore *stream.RedisStore, storageReader StorageReader, executionReader ExecutionReader, tester WorkflowTester, bundler WorkflowBundler, deadLetters DeadLetterStore, streamAdmin StreamAdmin, eventSchemas EventSchemaRegistry, streamHealth StreamHealth) *API {
 	return &API{
 		notifications: notificationSvc,
 		templates:     tplRepo,
@@ -98,6 +113,13 @@ func NewAPI(notificationSvc NotificationService, tplRepo TemplateRepository, wfR
 		streamHub:     streamHub,
 		redisStore:    redisStore,
 		storage:       storageReader,
+		executions:    executionReader,
+		tester:        tester,
+		bundles:       bundler,
+		deadLetters:   deadLetters,
+		streamAdmin:   streamAdmin,
+		eventSchemas:  eventSchemas,
+		streamHealth:  streamHealth,
 	}
 }
 
@@ -118,16 +140,18 @@ func (a *API) DispatchNotification(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "workflowId and templateId are required")
 	}
 
-	if err := a.notifications.Dispatch(c.Context(), services.DispatchInput{
+	executionID, err := a.notifications.Dispatch(c.Context(), services.DispatchInput{
 		WorkflowID: req.WorkflowID,
 		TemplateID: req.TemplateID,
 		Variables:  req.Variables,
 		Payload:    req.Payload,
-	}); err != nil {
+		Source:     services.SourceManual,
+	})
+	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
 	}
 
-	return c.SendStatus(fiber.StatusAccepted)
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"executionId": executionID})
 }
 
 type templateRequest struct {
@@ -197,12 +221,83 @@ func (a *API) SaveWorkflow(c *fiber.Ctx) error {
 		CanvasZoom:  req.CanvasZoom,
 	}
 
-	saved, err := a.workflows.Save(c.Context(), wf)
+	// Tokens stored before this save stay redacted in the response; new ones are shown once.
+	var previous []workflow.Node
+	exists := false
+	if wf.ID != "" {
+		if current, findErr := a.workflows.FindByID(c.Context(), wf.ID); findErr == nil {
+			previous, exists = current.Nodes, true
+		}
+	}
+
+	var saved workflow.Workflow
+	var err error
+	switch ifMatch := c.Get(fiber.HeaderIfMatch); ifMatch {
+	case "*":
+		saved, err = a.workflows.Save(c.Context(), wf)
+	case "":
+		// Without If-Match only new workflows can be saved.
+		if exists {
+			return fiber.NewError(fiber.StatusPreconditionRequired, "If-Match header is required to update an existing workflow")
+		}
+		saved, err = a.workflows.SaveIfMatch(c.Context(), wf, 0)
+	default:
+		revision, parseErr := parseWorkflowETag(ifMatch)
+		if parseErr != nil {
+			return fiber.NewError(fiber.StatusBadRequest, parseErr.Error())
+		}
+		saved, err = a.workflows.SaveIfMatch(c.Context(), wf, revision)
+	}
+	if err != nil {
+		var validationErr *workflow.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error":    "workflow is invalid and cannot be activated",
+				"problems": validationErr.Problems,
+			})
+		}
+		if errors.Is(err, workflow.ErrRevisionConflict) {
+			resp := fiber.Map{"error": err.Error()}
+			if current, findErr := a.workflows.FindByID(c.Context(), wf.ID); findErr == nil {
+				c.Set(fiber.HeaderETag, workflowETag(current.Revision))
+				resp["current"] = workflow.RedactWebhookSecrets(current)
+			}
+			return c.Status(fiber.StatusConflict).JSON(resp)
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	publication, err := workflow.PublicationOf(c.Context(), a.workflows, saved)
 	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(saved)
+	c.Set(fiber.HeaderETag, workflowETag(saved.Revision))
+	return c.Status(fiber.StatusCreated).JSON(savedWorkflow{
+		Workflow:    workflow.RevealNewWebhookTokens(saved, previous),
+		Publication: publication,
+	})
+}
+
+// savedWorkflow is the save response: the stored draft and whether production runs it.
+// Saving never publishes, so a new workflow stays "unpublished" until a version is.
+type savedWorkflow struct {
+	workflow.Workflow
+	Publication string `json:"publication"`
+}
+
+func workflowETag(revision int) string {
+	return `"` + strconv.Itoa(revision) + `"`
+}
+
+// parseWorkflowETag accepts the value produced by workflowETag, optionally weak.
+func parseWorkflowETag(value string) (int, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+	revision, err := strconv.Atoi(strings.Trim(value, `"`))
+	if err != nil || revision < 0 {
+		return 0, fmt.Errorf("invalid If-Match value %q", value)
+	}
+	return revision, nil
 }
 
 func (a *API) ListWorkflows(c *fiber.Ctx) error {
@@ -211,11 +306,12 @@ func (a *API) ListWorkflows(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
 	}
 
-	if wfs == nil {
-		wfs = []workflow.Workflow{}
+	out := make([]workflow.Workflow, 0, len(wfs))
+	for _, wf := range wfs {
+		out = append(out, workflow.RedactWebhookSecrets(wf))
 	}
 
-	return c.JSON(wfs)
+	return c.JSON(out)
 }
 
 func (a *API) GetWorkflow(c *fiber.Ctx) error {
@@ -226,10 +322,11 @@ func (a *API) GetWorkflow(c *fiber.Ctx) error {
 
 	wf, err := a.workflows.FindByID(c.Context(), id)
 	if err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "workflow not found")
+		return workflowError(err, "workflow not found")
 	}
 
-	return c.JSON(wf)
+	c.Set(fiber.HeaderETag, workflowETag(wf.Revision))
+	return c.JSON(workflow.RedactWebhookSecrets(wf))
 }
 
 func (a *API) DeleteWorkflow(c *fiber.Ctx) error {
@@ -252,7 +349,7 @@ func (a *API) ListWorkflowVersions(c *fiber.Ctx) error {
 	}
 
 	if _, err := a.workflows.FindByID(c.Context(), workflowID); err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "workflow not found")
+		return workflowError(err, "workflow not found")
 	}
 
 	versions, err := a.workflows.ListVersions(c.Context(), workflowID)
@@ -275,12 +372,63 @@ func (a *API) GetWorkflowVersion(c *fiber.Ctx) error {
 
 	ver, err := a.workflows.GetVersion(c.Context(), workflowID, versionID)
 	if err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "version not found")
+		return workflowError(err, "version not found")
 	}
 
+	ver.Nodes = workflow.RedactWebhookNodes(ver.Nodes)
 	return c.JSON(ver)
 }
 
+type versionUpdateRequest struct {
+	Label   *string `json:"label"`
+	Comment *string `json:"comment"`
+	Pinned  *bool   `json:"pinned"`
+}
+
+func (a *API) UpdateWorkflowVersion(c *fiber.Ctx) error {
+	workflowID := c.Params("id")
+	versionID := c.Params("versionId")
+	if workflowID == "" || versionID == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "id and versionId are required")
+	}
+
+	var req versionUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	meta, err := a.workflows.UpdateVersion(c.Context(), workflowID, versionID, workflow.VersionUpdate{
+		Label:   req.Label,
+		Comment: req.Comment,
+		Pinned:  req.Pinned,
+	})
+	if err != nil {
+		return workflowError(err, "version not found")
+	}
+
+	return c.JSON(meta)
+}
+
+func (a *API) DiffWorkflowVersions(c *fiber.Ctx) error {
+	workflowID := c.Params("id")
+	fromID := c.Params("a")
+	toID := c.Params("b")
+	if workflowID == "" || fromID == "" || toID == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "id, a and b are required")
+	}
+
+	from, err := a.workflows.GetVersion(c.Context(), workflowID, fromID)
+	if err != nil {
+		return workflowError(err, "version not found")
+	}
+	to, err := a.workflows.GetVersion(c.Context(), workflowID, toID)
+	if err != nil {
+		return workflowError(err, "version not found")
+	}
+
+	return c.JSON(workflow.Diff(from, to))
+}
+
 func (a *API) RestoreWorkflowVersion(c *fiber.Ctx) error {
 	workflowID := c.Params("id")
 	versionID := c.Params("versionId")
@@ -290,10 +438,42 @@ func (a *API) RestoreWorkflowVersion(c *fiber.Ctx) error {
 
 	restored, err := a.workflows.RestoreVersion(c.Context(), workflowID, versionID)
 	if err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "version not found")
+		return workflowError(err, "version not found")
+	}
+
+	c.Set(fiber.HeaderETag, workflowETag(restored.Revision))
+	return c.JSON(workflow.RedactWebhookSecrets(restored))
+}
+
+func (a *API) PublishWorkflowVersion(c *fiber.Ctx) error {
+	workflowID := c.Params("id")
+	versionID := c.Params("versionId")
+	if workflowID == "" || versionID == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "id and versionId are required")
 	}
 
-	return c.JSON(restored)
+	published, err := a.workflows.PublishVersion(c.Context(), workflowID, versionID)
+	if err != nil {
+		var validationErr *workflow.ValidationError
+		if errors.As(err, &validationErr) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error":    "version is invalid and cannot be published",
+				"problems": validationErr.Problems,
+			})
+		}
+		return workflowError(err, "version not found")
+	}
+
+	return c.JSON(workflow.RedactWebhookSecrets(published))
+}
+
+// workflowError maps a workflow repository error to 404 when the workflow or version
+// does not exist and to 500 otherwise.
+func workflowError(err error, notFound string) error {
+	if errors.Is(err, workflow.ErrNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, notFound)
+	}
+	return fiber.NewError(fiber.StatusInternalServerError, err.Error())
 }
 
 func (a *API) ListQueue(c *fiber.Ctx) error {
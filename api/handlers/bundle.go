@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"notiair/internal/bundle"
+	"notiair/internal/workflow"
+)
+
+type WorkflowBundler interface {
+	Export(ctx context.Context, workflowID string) (bundle.Bundle, error)
+	Import(ctx context.Context, b bundle.Bundle, opts bundle.ImportOptions) (bundle.ImportResult, error)
+}
+
+// ExportWorkflow returns the workflow as a bundle. ?format=yaml switches the encoding.
+func (a *API) ExportWorkflow(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "id is required")
+	}
+
+	b, err := a.bundles.Export(c.Context(), id)
+	if err != nil {
+		return workflowError(err, "workflow not found")
+	}
+
+	format := bundle.ParseFormat(c.Query("format"))
+	data, err := bundle.Encode(b, format)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	ext := "json"
+	contentType := fiber.MIMEApplicationJSON
+	if format == bundle.FormatYAML {
+		ext = "yaml"
+		contentType = "application/yaml"
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="workflow-`+id+`.`+ext+`"`)
+	return c.Send(data)
+}
+
+// ImportWorkflow accepts a JSON or YAML bundle as the request body. The conflict mode
+// comes from ?onConflict= and channel remapping from repeated ?channel=name=id.
+func (a *API) ImportWorkflow(c *fiber.Ctx) error {
+	mode, err := bundle.ParseConflictMode(c.Query("onConflict"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	b, err := bundle.Decode(c.Body(), bundle.ParseFormat(string(c.Request().Header.ContentType())))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	mapping := make(map[string]string)
+	for _, raw := range c.Context().QueryArgs().PeekMulti("channel") {
+		pair := string(raw)
+		sep := strings.LastIndex(pair, "=")
+		if sep <= 0 || sep == len(pair)-1 {
+			return fiber.NewError(fiber.StatusBadRequest, "channel mapping must be name=id")
+		}
+		mapping[pair[:sep]] = pair[sep+1:]
+	}
+
+	result, err := a.bundles.Import(c.Context(), b, bundle.ImportOptions{OnConflict: mode, Channels: mapping})
+	if err != nil {
+		var unresolved *bundle.UnresolvedError
+		var validationErr *workflow.ValidationError
+		switch {
+		case errors.Is(err, bundle.ErrConflict):
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		case errors.As(err, &unresolved):
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error":      "bundle has unresolved references",
+				"unresolved": unresolved.Refs,
+			})
+		case errors.As(err, &validationErr):
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error":    validationErr.Error(),
+				"problems": validationErr.Problems,
+			})
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	publication, err := workflow.PublicationOf(c.Context(), a.workflows, result.Workflow)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	status := fiber.StatusOK
+	if result.Created {
+		status = fiber.StatusCreated
+	}
+	return c.Status(status).JSON(importResponse{ImportResult: result, Publication: publication})
+}
+
+// importResponse adds the publication state: an import saves a draft, like SaveWorkflow.
+type importResponse struct {
+	bundle.ImportResult
+	Publication string `json:"publication"`
+}
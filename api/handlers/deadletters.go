@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"notiair/internal/stream"
+)
+
+type DeadLetterStore interface {
+	List(ctx context.Context, limit int) ([]stream.DeadLetter, error)
+	Replay(ctx context.Context, partition int32, offset int64) (stream.DeadLetter, error)
+}
+
+// ListDeadLetters returns the latest stream messages that went to the dead-letter topic.
+func (a *API) ListDeadLetters(c *fiber.Ctx) error {
+	if a.deadLetters == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "dead-letter queue is not configured")
+	}
+
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	letters, err := a.deadLetters.List(c.Context(), limit)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(fiber.Map{"items": letters})
+}
+
+// ReplayDeadLetter publishes a dead letter back to its original topic.
+func (a *API) ReplayDeadLetter(c *fiber.Ctx) error {
+	if a.deadLetters == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "dead-letter queue is not configured")
+	}
+
+	partition, offset, err := stream.ParseDeadLetterID(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	letter, err := a.deadLetters.Replay(c.Context(), partition, offset)
+	if err != nil {
+		if errors.Is(err, stream.ErrDeadLetterNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return c.Status(fiber.StatusAccepted).JSON(letter)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+
+	"notiair/internal/routing"
+	"notiair/internal/workflow"
+)
+
+type WorkflowTester interface {
+	DryRun(ctx context.Context, wf workflow.Workflow, payload map[string]any) (routing.DryRunResult, error)
+}
+
+type workflowDraftGraph struct {
+	Nodes   []workflow.Node   `json:"nodes"`
+	Edges   []workflow.Edge   `json:"edges"`
+	Filters map[string]string `json:"filters"`
+}
+
+type testWorkflowRequest struct {
+	Payload map[string]any      `json:"payload"`
+	Draft   *workflowDraftGraph `json:"draft,omitempty"`
+}
+
+type testTaskResponse struct {
+	ChannelID string         `json:"channelId"`
+	Body      string         `json:"body,omitempty"`
+	Payload   map[string]any `json:"payload"`
+}
+
+type testStorageResponse struct {
+	NodeID      string `json:"nodeId"`
+	Mode        string `json:"mode"`
+	ContentType string `json:"contentType"`
+	Data        string `json:"data"`
+}
+
+type testWorkflowResponse struct {
+	Tasks    []testTaskResponse      `json:"tasks"`
+	Storage  []testStorageResponse   `json:"storage"`
+	Steps    []executionStepResponse `json:"steps"`
+	Problems []workflow.Problem      `json:"problems"`
+	Error    string                  `json:"error,omitempty"`
+}
+
+func stepToResponse(seq int, st routing.Step) executionStepResponse {
+	return executionStepResponse{
+		Seq:        seq,
+		NodeID:     st.NodeID,
+		Variant:    st.Variant,
+		Input:      st.Input,
+		Output:     st.Output,
+		Decision:   st.Decision,
+		Error:      st.Error,
+		StartedAt:  st.StartedAt.Format("2006-01-02T15:04:05.000Z07:00"),
+		DurationMs: float64(st.Duration.Microseconds()) / 1000,
+	}
+}
+
+// TestWorkflow runs the saved workflow, or a draft graph from the body, against a
+// payload without storing records, writing outbox rows or enqueuing tasks.
+func (a *API) TestWorkflow(c *fiber.Ctx) error {
+	workflowID := c.Params("id")
+	if workflowID == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "id is required")
+	}
+
+	var req testWorkflowRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	wf, err := a.workflows.FindByID(c.Context(), workflowID)
+	if err != nil {
+		if req.Draft == nil {
+			return fiber.NewError(fiber.StatusNotFound, "workflow not found")
+		}
+		wf = workflow.Workflow{ID: workflowID}
+	}
+	if req.Draft != nil {
+		wf.Nodes = req.Draft.Nodes
+		wf.Edges = req.Draft.Edges
+		wf.Filters = req.Draft.Filters
+	}
+
+	result, runErr := a.tester.DryRun(c.Context(), wf, req.Payload)
+
+	resp := testWorkflowResponse{
+		Tasks:    make([]testTaskResponse, len(result.Tasks)),
+		Storage:  make([]testStorageResponse, len(result.Storage)),
+		Steps:    make([]executionStepResponse, len(result.Steps)),
+		Problems: workflow.Validate(wf),
+	}
+	if resp.Problems == nil {
+		resp.Problems = []workflow.Problem{}
+	}
+	if runErr != nil {
+		resp.Error = runErr.Error()
+	}
+	for i, task := range result.Tasks {
+		body, _ := task.Payload["body"].(string)
+		resp.Tasks[i] = testTaskResponse{ChannelID: task.ChannelID, Body: body, Payload: task.Payload}
+	}
+	for i, rec := range result.Storage {
+		resp.Storage[i] = testStorageResponse{
+			NodeID:      rec.NodeID,
+			Mode:        string(rec.Mode),
+			ContentType: rec.ContentType,
+			Data:        string(rec.Data),
+		}
+	}
+	for i, st := range result.Steps {
+		resp.Steps[i] = stepToResponse(i+1, st)
+	}
+
+	return c.JSON(resp)
+}
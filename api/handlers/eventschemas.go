@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+
+	"notiair/internal/eventschema"
+)
+
+type EventSchemaRegistry interface {
+	List(ctx context.Context) ([]eventschema.Definition, error)
+	Get(ctx context.Context, eventType string) (eventschema.Definition, error)
+	Save(ctx context.Context, eventType, description string, schema json.RawMessage) (eventschema.Definition, error)
+	Delete(ctx context.Context, eventType string) error
+	Rejections(ctx context.Context, eventType string, limit int) ([]eventschema.Rejection, error)
+}
+
+type eventSchemaRequest struct {
+	Description string          `json:"description"`
+	Schema      json.RawMessage `json:"schema"`
+}
+
+// ListEventSchemas returns every registered schema with the placeholder paths it declares.
+func (a *API) ListEventSchemas(c *fiber.Ctx) error {
+	definitions, err := a.eventSchemas.List(c.Context())
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(definitions)
+}
+
+func (a *API) GetEventSchema(c *fiber.Ctx) error {
+	eventType, err := eventTypeParam(c)
+	if err != nil {
+		return err
+	}
+	definition, err := a.eventSchemas.Get(c.Context(), eventType)
+	if err != nil {
+		return eventSchemaError(err)
+	}
+	return c.JSON(definition)
+}
+
+// PutEventSchema registers the JSON Schema that stream events of the event_type must match.
+func (a *API) PutEventSchema(c *fiber.Ctx) error {
+	eventType, err := eventTypeParam(c)
+	if err != nil {
+		return err
+	}
+
+	var req eventSchemaRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if len(req.Schema) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "schema is required")
+	}
+
+	definition, err := a.eventSchemas.Save(c.Context(), eventType, req.Description, req.Schema)
+	if err != nil {
+		return eventSchemaError(err)
+	}
+	return c.JSON(definition)
+}
+
+func (a *API) DeleteEventSchema(c *fiber.Ctx) error {
+	eventType, err := eventTypeParam(c)
+	if err != nil {
+		return err
+	}
+	if err := a.eventSchemas.Delete(c.Context(), eventType); err != nil {
+		return eventSchemaError(err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListRejectedEvents returns the latest stream events that failed schema validation.
+func (a *API) ListRejectedEvents(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	rejections, err := a.eventSchemas.Rejections(c.Context(), c.Query("eventType"), limit)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(fiber.Map{"items": rejections})
+}
+
+func eventTypeParam(c *fiber.Ctx) (string, error) {
+	eventType, err := url.PathUnescape(c.Params("eventType"))
+	if err != nil || eventType == "" {
+		return "", fiber.NewError(fiber.StatusBadRequest, "invalid event type")
+	}
+	return eventType, nil
+}
+
+func eventSchemaError(err error) error {
+	switch {
+	case errors.Is(err, eventschema.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, eventschema.ErrInvalidSchema):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	default:
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"notiair/internal/persistence/execution"
+)
+
+type ExecutionReader interface {
+	ListByWorkflow(ctx context.Context, filter execution.ListFilter) ([]execution.Execution, error)
+	CountByWorkflow(ctx context.Context, workflowID string) (int, error)
+	FindByID(ctx context.Context, id string) (execution.Execution, error)
+}
+
+type executionListResponse struct {
+	Items []executionResponse `json:"items"`
+	Total int                 `json:"total"`
+}
+
+type executionResponse struct {
+	ID            string                  `json:"id"`
+	WorkflowID    string                  `json:"workflowId"`
+	VersionID     string                  `json:"versionId,omitempty"`
+	VersionNumber int                     `json:"versionNumber"`
+	TriggerSource string                  `json:"triggerSource"`
+	Input         map[string]any          `json:"input,omitempty"`
+	Status        string                  `json:"status"`
+	Error         string                  `json:"error,omitempty"`
+	TaskCount     int                     `json:"taskCount"`
+	StartedAt     string                  `json:"startedAt"`
+	DurationMs    float64                 `json:"durationMs"`
+	Steps         []executionStepResponse `json:"steps,omitempty"`
+}
+
+type executionStepResponse struct {
+	Seq        int            `json:"seq"`
+	NodeID     string         `json:"nodeId"`
+	Variant    string         `json:"variant"`
+	Input      map[string]any `json:"input,omitempty"`
+	Output     map[string]any `json:"output,omitempty"`
+	Decision   string         `json:"decision"`
+	Error      string         `json:"error,omitempty"`
+	StartedAt  string         `json:"startedAt"`
+	DurationMs float64        `json:"durationMs"`
+}
+
+func executionToResponse(exec execution.Execution, withDetails bool) executionResponse {
+	resp := executionResponse{
+		ID:            exec.ID,
+		WorkflowID:    exec.WorkflowID,
+		VersionID:     exec.VersionID,
+		VersionNumber: exec.VersionNumber,
+		TriggerSource: exec.TriggerSource,
+		Status:        string(exec.Status),
+		Error:         exec.Error,
+		TaskCount:     exec.TaskCount,
+		StartedAt:     exec.StartedAt.Format("2006-01-02T15:04:05Z07:00"),
+		DurationMs:    exec.DurationMs,
+	}
+	if !withDetails {
+		return resp
+	}
+
+	resp.Input = exec.Input
+	resp.Steps = make([]executionStepResponse, len(exec.Steps))
+	for i, st := range exec.Steps {
+		resp.Steps[i] = executionStepResponse{
+			Seq:        st.Seq,
+			NodeID:     st.NodeID,
+			Variant:    st.Variant,
+			Input:      st.Input,
+			Output:     st.Output,
+			Decision:   st.Decision,
+			Error:      st.Error,
+			StartedAt:  st.StartedAt.Format("2006-01-02T15:04:05.000Z07:00"),
+			DurationMs: st.DurationMs,
+		}
+	}
+	return resp
+}
+
+func (a *API) ListWorkflowExecutions(c *fiber.Ctx) error {
+	workflowID := c.Params("id")
+	if workflowID == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "id is required")
+	}
+
+	limit := 20
+	offset := 0
+	if v := c.Query("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			limit = n
+		}
+	}
+	if v := c.Query("offset"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			offset = n
+		}
+	}
+
+	total, err := a.executions.CountByWorkflow(c.Context(), workflowID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	executions, err := a.executions.ListByWorkflow(c.Context(), execution.ListFilter{
+		WorkflowID: workflowID,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	items := make([]executionResponse, len(executions))
+	for i, exec := range executions {
+		items[i] = executionToResponse(exec, false)
+	}
+
+	return c.JSON(executionListResponse{Items: items, Total: total})
+}
+
+func (a *API) GetExecution(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "id is required")
+	}
+
+	exec, err := a.executions.FindByID(c.Context(), id)
+	if errors.Is(err, execution.ErrNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, "execution not found")
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(executionToResponse(exec, true))
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+
+	"notiair/internal/stream"
+)
+
+type StreamHealth interface {
+	Health(ctx context.Context) []stream.SourceHealth
+}
+
+// Health is the liveness probe: the process serves HTTP.
+func (a *API) Health(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// Ready is the readiness probe: 503 while any stream consumer is not reading its broker.
+func (a *API) Ready(c *fiber.Ctx) error {
+	consumers := a.streamConsumers(c.Context())
+	ready := stream.Ready(consumers)
+	status := fiber.StatusOK
+	if !ready {
+		status = fiber.StatusServiceUnavailable
+	}
+	return c.Status(status).JSON(fiber.Map{"ready": ready, "consumers": consumers})
+}
+
+// ListStreamConsumers reports the state, restarts, assigned partitions and lag of every stream consumer.
+func (a *API) ListStreamConsumers(c *fiber.Ctx) error {
+	consumers := a.streamConsumers(c.Context())
+	return c.JSON(fiber.Map{"ready": stream.Ready(consumers), "consumers": consumers})
+}
+
+func (a *API) streamConsumers(ctx context.Context) []stream.SourceHealth {
+	if a.streamHealth == nil {
+		return []stream.SourceHealth{}
+	}
+	return a.streamHealth.Health(ctx)
+}
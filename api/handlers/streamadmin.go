@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"notiair/internal/stream"
+)
+
+type StreamAdmin interface {
+	Status(ctx context.Context) stream.ConsumerStatus
+	// Pause and Resume apply to every replica of the consumer.
+	Pause(ctx context.Context) error
+	Resume(ctx context.Context) error
+	ResetOffsets(ctx context.Context, reset stream.OffsetReset) (map[int32]int64, error)
+	// StartReplay runs the replay in the background; ReplayJob reports its progress.
+	StartReplay(ctx context.Context, req stream.ReplayRequest) (stream.ReplayJob, error)
+	ReplayJob(ctx context.Context, id string) (stream.ReplayJob, error)
+}
+
+type offsetResetRequest struct {
+	Topic     string     `json:"topic"`
+	Partition *int32     `json:"partition"`
+	Offset    *int64     `json:"offset"`
+	Timestamp *time.Time `json:"timestamp"`
+}
+
+type replayRequest struct {
+	Topic      string    `json:"topic"`
+	WorkflowID string    `json:"workflowId"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+}
+
+// GetStreamConsumer reports whether the stream consumer is paused and what it reads.
+func (a *API) GetStreamConsumer(c *fiber.Ctx) error {
+	if a.streamAdmin == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "stream consumer is not running")
+	}
+	return c.JSON(a.streamAdmin.Status(c.Context()))
+}
+
+// PauseStreamConsumer stops processing stream events on every replica until ResumeStreamConsumer.
+func (a *API) PauseStreamConsumer(c *fiber.Ctx) error {
+	if a.streamAdmin == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "stream consumer is not running")
+	}
+	if err := a.streamAdmin.Pause(c.Context()); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(a.streamAdmin.Status(c.Context()))
+}
+
+func (a *API) ResumeStreamConsumer(c *fiber.Ctx) error {
+	if a.streamAdmin == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "stream consumer is not running")
+	}
+	if err := a.streamAdmin.Resume(c.Context()); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(a.streamAdmin.Status(c.Context()))
+}
+
+// ResetStreamOffsets moves the consumer group offset of a topic to an offset or a point in time.
+// Every replica leaves the group while the offsets are committed.
+func (a *API) ResetStreamOffsets(c *fiber.Ctx) error {
+	if a.streamAdmin == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "stream consumer is not running")
+	}
+
+	var req offsetResetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if req.Topic == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "topic is required")
+	}
+
+	offsets, err := a.streamAdmin.ResetOffsets(c.Context(), stream.OffsetReset{
+		Topic:     req.Topic,
+		Partition: req.Partition,
+		Offset:    req.Offset,
+		Timestamp: req.Timestamp,
+	})
+	if err != nil {
+		if errors.Is(err, stream.ErrGroupActive) {
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		}
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return c.JSON(fiber.Map{"topic": req.Topic, "offsets": offsets})
+}
+
+// ReplayStream starts a background job that runs one workflow with the events of a topic
+// from a time range, and returns the job to poll with GetStreamReplay.
+func (a *API) ReplayStream(c *fiber.Ctx) error {
+	if a.streamAdmin == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "stream consumer is not running")
+	}
+
+	var req replayRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if req.Topic == "" || req.WorkflowID == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "topic and workflowId are required")
+	}
+	if req.From.IsZero() || req.To.IsZero() {
+		return fiber.NewError(fiber.StatusBadRequest, "from and to are required")
+	}
+
+	wf, err := a.workflows.FindPublished(c.Context(), req.WorkflowID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "workflow not found")
+	}
+
+	job, err := a.streamAdmin.StartReplay(c.Context(), stream.ReplayRequest{
+		Workflow: wf,
+		Topic:    req.Topic,
+		From:     req.From,
+		To:       req.To,
+	})
+	if err != nil {
+		if errors.Is(err, stream.ErrNoStreamTrigger) {
+			return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+		}
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// GetStreamReplay reports the status and counters of a replay job.
+func (a *API) GetStreamReplay(c *fiber.Ctx) error {
+	if a.streamAdmin == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "stream consumer is not running")
+	}
+
+	job, err := a.streamAdmin.ReplayJob(c.Context(), c.Params("id"))
+	if err != nil {
+		if errors.Is(err, stream.ErrReplayJobNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(job)
+}
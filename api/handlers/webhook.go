@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"notiair/internal/webhook"
+	"notiair/internal/workflow"
+	"notiair/services"
+)
+
+// ReceiveWebhook runs the published workflow whose webhook trigger owns the token.
+func (a *API) ReceiveWebhook(c *fiber.Ctx) error {
+	workflowID := c.Params("workflowId")
+	token := c.Params("token")
+
+	wf, err := a.workflows.FindPublished(c.Context(), workflowID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "webhook not found")
+	}
+	node, cfg, ok := workflow.FindWebhookTrigger(wf, token)
+	if !ok {
+		return fiber.NewError(fiber.StatusNotFound, "webhook not found")
+	}
+	if !wf.IsActive {
+		return fiber.NewError(fiber.StatusConflict, "workflow is not active")
+	}
+
+	headers := make(map[string]string)
+	for name, values := range c.GetReqHeaders() {
+		headers[name] = strings.Join(values, ", ")
+	}
+
+	req := webhook.Request{
+		Method:      c.Method(),
+		ContentType: string(c.Request().Header.ContentType()),
+		Headers:     headers,
+		Query:       c.Queries(),
+		Body:        c.Body(),
+	}
+	receivedAt := time.Now()
+	if err := webhook.Verify(cfg.Secret, req, receivedAt); err != nil {
+		if errors.Is(err, webhook.ErrInvalidSignature) {
+			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+		}
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	// CloudEvents also get the event fields stream triggers use (event_id, context, ...).
+	payload := webhook.Payload(req, receivedAt)
+	ce, isCloudEvent, err := webhook.ParseCloudEvent(req)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if isCloudEvent {
+		for k, v := range ce.Fields() {
+			payload[k] = v
+		}
+	}
+
+	executionID, err := a.notifications.Dispatch(c.Context(), services.DispatchInput{
+		WorkflowID: wf.ID,
+		Variables:  map[string]string{},
+		Payload:    payload,
+		Source:     services.SourceWebhook,
+		TriggerIDs: []string{node.ID},
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":       err.Error(),
+			"executionId": executionID,
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"executionId": executionID})
+}
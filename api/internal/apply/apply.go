@@ -0,0 +1,332 @@
+package apply
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"notiair/internal/bundle"
+	workflowpersist "notiair/internal/persistence/workflow"
+	"notiair/internal/workflow"
+)
+
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+	ActionNoop   Action = "no-op"
+)
+
+// Definition is one workflow bundle read from disk.
+type Definition struct {
+	File   string
+	Bundle bundle.Bundle
+}
+
+type Change struct {
+	Action     Action
+	WorkflowID string
+	Name       string
+	File       string
+	// Fields lists what differs for updates.
+	Fields   []string
+	Problems []workflow.Problem
+
+	bundle *bundle.Bundle
+}
+
+var markers = map[Action]string{
+	ActionCreate: "+",
+	ActionUpdate: "~",
+	ActionDelete: "-",
+	ActionNoop:   "=",
+}
+
+type Plan struct {
+	Changes []Change
+}
+
+// Invalid returns changes that would be rejected on save: active workflows with problems.
+func (p Plan) Invalid() []Change {
+	var out []Change
+	for _, c := range p.Changes {
+		if len(c.Problems) > 0 && c.bundle != nil && c.bundle.Workflow.IsActive {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (p Plan) HasChanges() bool {
+	for _, c := range p.Changes {
+		if c.Action != ActionNoop {
+			return true
+		}
+	}
+	return false
+}
+
+// Write prints the plan in a terraform-like layout.
+func (p Plan) Write(w io.Writer) {
+	counts := make(map[Action]int)
+	for _, c := range p.Changes {
+		counts[c.Action]++
+		line := fmt.Sprintf("%s %s %s (%s)", markers[c.Action], c.Action, c.WorkflowID, c.Name)
+		if c.File != "" {
+			line += " from " + c.File
+		}
+		if len(c.Fields) > 0 {
+			line += ": " + strings.Join(c.Fields, ", ")
+		}
+		fmt.Fprintln(w, line)
+		for _, problem := range c.Problems {
+			if problem.NodeID != "" {
+				fmt.Fprintf(w, "    ! node %s: %s: %s\n", problem.NodeID, problem.Field, problem.Message)
+			} else {
+				fmt.Fprintf(w, "    ! %s: %s\n", problem.Field, problem.Message)
+			}
+		}
+	}
+	fmt.Fprintf(w, "Plan: %d to create, %d to update, %d to delete, %d unchanged.\n",
+		counts[ActionCreate], counts[ActionUpdate], counts[ActionDelete], counts[ActionNoop])
+}
+
+// LoadDir reads every *.yaml, *.yml and *.json bundle in dir, sorted by file name.
+func LoadDir(dir string) ([]Definition, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var defs []Definition
+	seen := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if ext != ".yaml" && ext != ".yml" && ext != ".json" {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		b, err := bundle.Decode(data, bundle.ParseFormat(ext))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if b.Workflow.ID == "" {
+			return nil, fmt.Errorf("%s: workflow id is required", path)
+		}
+		if other, dup := seen[b.Workflow.ID]; dup {
+			return nil, fmt.Errorf("%s: workflow %s is already defined in %s", path, b.Workflow.ID, other)
+		}
+		seen[b.Workflow.ID] = path
+		defs = append(defs, Definition{File: path, Bundle: b})
+	}
+	return defs, nil
+}
+
+type WorkflowStore interface {
+	List(ctx context.Context) ([]workflow.Workflow, error)
+	ListPublished(ctx context.Context) ([]workflow.Workflow, error)
+	ListVersions(ctx context.Context, workflowID string) ([]workflow.VersionMeta, error)
+	PublishVersion(ctx context.Context, workflowID, versionID string) (workflow.Workflow, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type Bundler interface {
+	Resolve(ctx context.Context, b bundle.Bundle, channelMap map[string]string) (bundle.Resolved, error)
+	Import(ctx context.Context, b bundle.Bundle, opts bundle.ImportOptions) (bundle.ImportResult, error)
+}
+
+type Syncer struct {
+	workflows WorkflowStore
+	bundles   Bundler
+}
+
+func NewSyncer(workflows WorkflowStore, bundles Bundler) *Syncer {
+	return &Syncer{workflows: workflows, bundles: bundles}
+}
+
+// Plan compares definitions with stored workflows: the graph with the published version,
+// which is what production executes, and name, description and isActive with the
+// workflow itself. Workflows missing from defs are planned for deletion only when
+// prune is set.
+func (s *Syncer) Plan(ctx context.Context, defs []Definition, prune bool) (Plan, error) {
+	existing, err := s.workflows.List(ctx)
+	if err != nil {
+		return Plan{}, err
+	}
+	byID := make(map[string]workflow.Workflow, len(existing))
+	for _, wf := range existing {
+		byID[wf.ID] = wf
+	}
+	live, err := s.workflows.ListPublished(ctx)
+	if err != nil {
+		return Plan{}, err
+	}
+	published := make(map[string]workflow.Workflow, len(live))
+	for _, wf := range live {
+		published[wf.ID] = wf
+	}
+
+	var plan Plan
+	defined := make(map[string]bool, len(defs))
+	for i := range defs {
+		def := defs[i]
+		defined[def.Bundle.Workflow.ID] = true
+
+		resolved, err := s.bundles.Resolve(ctx, def.Bundle, nil)
+		if err != nil {
+			return Plan{}, fmt.Errorf("%s: %w", def.File, err)
+		}
+
+		change := Change{
+			WorkflowID: def.Bundle.Workflow.ID,
+			Name:       def.Bundle.Workflow.Name,
+			File:       def.File,
+			Problems:   workflow.Validate(resolved.Workflow),
+			bundle:     &def.Bundle,
+		}
+		if current, ok := byID[change.WorkflowID]; !ok {
+			change.Action = ActionCreate
+		} else if change.Fields = diffFields(current, published[change.WorkflowID], resolved); len(change.Fields) > 0 {
+			change.Action = ActionUpdate
+		} else {
+			change.Action = ActionNoop
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	if prune {
+		var orphans []workflow.Workflow
+		for _, wf := range existing {
+			if !defined[wf.ID] {
+				orphans = append(orphans, wf)
+			}
+		}
+		sort.Slice(orphans, func(i, j int) bool { return orphans[i].ID < orphans[j].ID })
+		for _, wf := range orphans {
+			plan.Changes = append(plan.Changes, Change{Action: ActionDelete, WorkflowID: wf.ID, Name: wf.Name})
+		}
+	}
+
+	return plan, nil
+}
+
+// Apply executes a plan produced by Plan: it saves each definition as the draft and
+// publishes the resulting version. It stops at the first failure.
+func (s *Syncer) Apply(ctx context.Context, plan Plan) error {
+	if invalid := plan.Invalid(); len(invalid) > 0 {
+		ids := make([]string, len(invalid))
+		for i, c := range invalid {
+			ids[i] = c.WorkflowID
+		}
+		return fmt.Errorf("plan has invalid active workflows: %s", strings.Join(ids, ", "))
+	}
+
+	for _, c := range plan.Changes {
+		switch c.Action {
+		case ActionCreate, ActionUpdate:
+			if c.bundle == nil {
+				return errors.New("plan change has no definition")
+			}
+			result, err := s.bundles.Import(ctx, *c.bundle, bundle.ImportOptions{OnConflict: bundle.ConflictOverwrite})
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", c.Action, c.WorkflowID, err)
+			}
+			if err := s.publish(ctx, result.Workflow); err != nil {
+				return fmt.Errorf("publish %s: %w", c.WorkflowID, err)
+			}
+		case ActionDelete:
+			if err := s.workflows.Delete(ctx, c.WorkflowID); err != nil {
+				return fmt.Errorf("delete %s: %w", c.WorkflowID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// publish makes the version created by saving wf the published one.
+func (s *Syncer) publish(ctx context.Context, wf workflow.Workflow) error {
+	versions, err := s.workflows.ListVersions(ctx, wf.ID)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		if v.VersionNumber != wf.VersionNumber {
+			continue
+		}
+		if v.IsPublished {
+			return nil
+		}
+		_, err := s.workflows.PublishVersion(ctx, wf.ID, v.ID)
+		return err
+	}
+	return fmt.Errorf("version %d not found", wf.VersionNumber)
+}
+
+// diffFields compares the definition with the stored workflow; the graph is compared
+// with the published version, or reported as unpublished when there is none.
+func diffFields(current, published workflow.Workflow, resolved bundle.Resolved) []string {
+	want := resolved.Workflow
+	// Saves fill in trigger kinds and keep redacted webhook secrets, so a definition
+	// without them is not a change.
+	want.Nodes, _ = workflow.AssignTriggerKinds(want.Nodes)
+	if restored, err := workflow.RestoreWebhookSecrets(want, current.Nodes); err == nil {
+		want = restored
+	}
+	var fields []string
+	if current.Name != want.Name {
+		fields = append(fields, "name")
+	}
+	if current.Description != want.Description {
+		fields = append(fields, "description")
+	}
+	if current.IsActive != want.IsActive {
+		fields = append(fields, "isActive")
+	}
+	published.Nodes, _ = workflow.AssignTriggerKinds(published.Nodes)
+	switch {
+	case published.ID == "":
+		fields = append(fields, "unpublished")
+	// Resolve inlines template bodies, so a template missing here changes the hash only
+	// if its body differs from the published one.
+	case contentHash(published) != contentHash(want):
+		fields = append(fields, "graph")
+	}
+	return fields
+}
+
+// contentHash hashes the graph the same way the version history does, so a definition
+// that matches the stored workflow is a no-op.
+func contentHash(wf workflow.Workflow) string {
+	nodes := wf.Nodes
+	if nodes == nil {
+		nodes = []workflow.Node{}
+	}
+	edges := wf.Edges
+	if edges == nil {
+		edges = []workflow.Edge{}
+	}
+	filters := wf.Filters
+	if filters == nil {
+		filters = map[string]string{}
+	}
+
+	nodesJSON, _ := json.Marshal(nodes)
+	edgesJSON, _ := json.Marshal(edges)
+	return workflowpersist.ComputeContentHash(nodesJSON, edgesJSON, filters)
+}
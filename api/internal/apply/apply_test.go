@@ -0,0 +1,228 @@
+package apply
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"notiair/internal/bundle"
+	"notiair/internal/persistence/channel"
+	workflowpersist "notiair/internal/persistence/workflow"
+	"notiair/internal/templates"
+	"notiair/internal/workflow"
+)
+
+type noChannels struct{}
+
+func (noChannels) FindByID(ctx context.Context, id string) (channel.Channel, error) {
+	return channel.Channel{}, os.ErrNotExist
+}
+
+func (noChannels) FindByName(ctx context.Context, name string) ([]channel.Channel, error) {
+	return nil, nil
+}
+
+const ordersYAML = `kind: notiair.workflow
+version: 1
+workflow:
+  id: wf-orders
+  name: Orders
+  nodes:
+    - id: tr
+      type: trigger
+      config: {variant: trigger}
+      position: {x: 0, y: 0}
+    - id: ch
+      type: action
+      config: {variant: channel, channelId: chan-1}
+      position: {x: 120, y: 0}
+  edges:
+    - {from: tr, to: ch}
+  isActive: true
+`
+
+const templatedYAML = `kind: notiair.workflow
+version: 1
+workflow:
+  id: wf-templated
+  name: Templated
+  nodes:
+    - id: tr
+      type: trigger
+      config: {variant: trigger}
+      position: {x: 0, y: 0}
+    - id: tpl
+      type: action
+      config: {variant: template, templateRef: order-created}
+      position: {x: 120, y: 0}
+    - id: ch
+      type: action
+      config: {variant: channel, channelId: chan-1}
+      position: {x: 240, y: 0}
+  edges:
+    - {from: tr, to: tpl}
+    - {from: tpl, to: ch}
+  isActive: true
+templates:
+  - name: order-created
+    body: "Order {{id}}"
+`
+
+// newRepository returns a workflow repository with version history, so applied
+// definitions can be published.
+func newRepository(t *testing.T) workflow.Repository {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&workflowpersist.WorkflowEntity{}, &workflowpersist.WorkflowVersionEntity{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return workflow.NewDBRepository(workflowpersist.NewRepository(db))
+}
+
+func writeDefinition(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+}
+
+func plan(t *testing.T, syncer *Syncer, dir string, prune bool) Plan {
+	t.Helper()
+	defs, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	p, err := syncer.Plan(context.Background(), defs, prune)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	return p
+}
+
+func actions(p Plan) []Action {
+	out := make([]Action, len(p.Changes))
+	for i, c := range p.Changes {
+		out[i] = c.Action
+	}
+	return out
+}
+
+func TestSyncPlansAndApplies(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeDefinition(t, dir, "orders.yaml", ordersYAML)
+	writeDefinition(t, dir, "README.md", "ignored")
+
+	repo := newRepository(t)
+	if _, err := repo.Save(ctx, workflow.Workflow{ID: "wf-legacy", Name: "Legacy"}); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	syncer := NewSyncer(repo, bundle.NewService(repo, templates.NewMemoryRepository(), noChannels{}))
+
+	p := plan(t, syncer, dir, false)
+	if got := actions(p); len(got) != 1 || got[0] != ActionCreate {
+		t.Fatalf("expected create, got %v", got)
+	}
+	if err := syncer.Apply(ctx, p); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	p = plan(t, syncer, dir, false)
+	if p.HasChanges() {
+		t.Fatalf("expected no-op after apply, got %v", actions(p))
+	}
+	live, err := repo.FindPublished(ctx, "wf-orders")
+	if err != nil || len(live.Nodes) != 2 {
+		t.Fatalf("expected applied graph to be published, got %+v (%v)", live, err)
+	}
+
+	// Editing the draft alone does not change what production runs, so the plan is still a no-op.
+	draft, _ := repo.FindByID(ctx, "wf-orders")
+	draft.Edges = nil
+	if _, err := repo.Save(ctx, draft); err != nil {
+		t.Fatalf("edit draft: %v", err)
+	}
+	if p = plan(t, syncer, dir, false); p.HasChanges() {
+		t.Fatalf("expected draft edits to be ignored, got %v", p.Changes)
+	}
+
+	writeDefinition(t, dir, "orders.yaml", strings.Replace(ordersYAML, "chan-1", "chan-2", 1))
+	p = plan(t, syncer, dir, true)
+	if got := actions(p); len(got) != 2 || got[0] != ActionUpdate || got[1] != ActionDelete {
+		t.Fatalf("expected update and delete, got %v", got)
+	}
+	if fields := p.Changes[0].Fields; len(fields) != 1 || fields[0] != "graph" {
+		t.Fatalf("expected graph change, got %v", fields)
+	}
+
+	var out bytes.Buffer
+	p.Write(&out)
+	if !strings.Contains(out.String(), "Plan: 0 to create, 1 to update, 1 to delete, 0 unchanged.") {
+		t.Fatalf("unexpected plan output:\n%s", out.String())
+	}
+}
+
+func TestApplyTemplatedDefinitionTwiceIsNoOp(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeDefinition(t, dir, "templated.yaml", templatedYAML)
+
+	repo := newRepository(t)
+	// Each apply run starts with an empty template store, like the apply command.
+	newSyncer := func() *Syncer {
+		return NewSyncer(repo, bundle.NewService(repo, templates.NewMemoryRepository(), noChannels{}))
+	}
+
+	p := plan(t, newSyncer(), dir, false)
+	if err := newSyncer().Apply(ctx, p); err != nil {
+		t.Fatalf("first apply: %v", err)
+	}
+	versions, err := repo.ListVersions(ctx, "wf-templated")
+	if err != nil || len(versions) != 1 {
+		t.Fatalf("versions after first apply: %v %v", versions, err)
+	}
+
+	p = plan(t, newSyncer(), dir, false)
+	if p.HasChanges() {
+		t.Fatalf("expected no-op on the second run, got %+v", p.Changes)
+	}
+	if err := newSyncer().Apply(ctx, p); err != nil {
+		t.Fatalf("second apply: %v", err)
+	}
+	if versions, _ := repo.ListVersions(ctx, "wf-templated"); len(versions) != 1 {
+		t.Fatalf("second apply created a version: %v", versions)
+	}
+
+	live, err := repo.FindPublished(ctx, "wf-templated")
+	if err != nil {
+		t.Fatalf("find published: %v", err)
+	}
+	if body := live.Nodes[1].Config.(map[string]any)["templateBody"]; body != "Order {{id}}" {
+		t.Fatalf("published template body %v", body)
+	}
+}
+
+func TestApplyRefusesInvalidActiveWorkflow(t *testing.T) {
+	dir := t.TempDir()
+	writeDefinition(t, dir, "orders.yaml", strings.Replace(ordersYAML, ", channelId: chan-1", "", 1))
+
+	repo := newRepository(t)
+	syncer := NewSyncer(repo, bundle.NewService(repo, templates.NewMemoryRepository(), noChannels{}))
+
+	p := plan(t, syncer, dir, false)
+	if len(p.Invalid()) != 1 {
+		t.Fatalf("expected one invalid change, got %+v", p.Changes)
+	}
+	if err := syncer.Apply(context.Background(), p); err == nil {
+		t.Fatalf("expected apply to fail")
+	}
+}
@@ -0,0 +1,112 @@
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"notiair/internal/workflow"
+)
+
+const (
+	Kind          = "notiair.workflow"
+	FormatVersion = 1
+)
+
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+)
+
+// Bundle is a portable snapshot of a workflow. Channels and templates are referenced
+// by name so the bundle can be imported into another environment.
+type Bundle struct {
+	Kind       string         `json:"kind"`
+	Version    int            `json:"version"`
+	ExportedAt time.Time      `json:"exportedAt"`
+	Workflow   WorkflowSpec   `json:"workflow"`
+	Templates  []TemplateSpec `json:"templates,omitempty"`
+	Channels   []ChannelRef   `json:"channels,omitempty"`
+}
+
+type WorkflowSpec struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Nodes       []workflow.Node   `json:"nodes"`
+	Edges       []workflow.Edge   `json:"edges"`
+	Filters     map[string]string `json:"filters,omitempty"`
+	IsActive    bool              `json:"isActive"`
+	CanvasZoom  *float64          `json:"canvasZoom,omitempty"`
+}
+
+type TemplateSpec struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Body        string            `json:"body"`
+	Variables   map[string]string `json:"variables,omitempty"`
+}
+
+type ChannelRef struct {
+	Name          string `json:"name"`
+	DisplayName   string `json:"displayName,omitempty"`
+	ConnectorType string `json:"connectorType,omitempty"`
+}
+
+// ParseFormat maps a query value or content type to a bundle format. JSON is the default.
+func ParseFormat(value string) Format {
+	value = strings.ToLower(value)
+	if strings.Contains(value, "yaml") || strings.Contains(value, "yml") {
+		return FormatYAML
+	}
+	return FormatJSON
+}
+
+// Encode serialises the bundle. YAML output is produced from the JSON form so both
+// formats share the same field names.
+func Encode(b Bundle, format Format) ([]byte, error) {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if format != FormatYAML {
+		return data, nil
+	}
+
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(doc)
+}
+
+func Decode(data []byte, format Format) (Bundle, error) {
+	if format == FormatYAML {
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return Bundle{}, fmt.Errorf("invalid yaml bundle: %w", err)
+		}
+		converted, err := json.Marshal(doc)
+		if err != nil {
+			return Bundle{}, fmt.Errorf("invalid yaml bundle: %w", err)
+		}
+		data = converted
+	}
+
+	var b Bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return Bundle{}, fmt.Errorf("invalid bundle: %w", err)
+	}
+	if b.Kind != Kind {
+		return Bundle{}, fmt.Errorf("unsupported bundle kind %q", b.Kind)
+	}
+	if b.Version < 1 || b.Version > FormatVersion {
+		return Bundle{}, fmt.Errorf("unsupported bundle version %d", b.Version)
+	}
+	return b, nil
+}
@@ -0,0 +1,458 @@
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"notiair/internal/persistence/channel"
+	"notiair/internal/templates"
+	"notiair/internal/workflow"
+)
+
+// Node config keys rewritten on export and import.
+const (
+	configChannelID     = "channelId"
+	configChannelName   = "channelName"
+	configChannelRef    = "channelRef"
+	configConnectorID   = "connectorId"
+	configConnectorType = "connectorType"
+	configTemplateBody  = "templateBody"
+	configTemplateRef   = "templateRef"
+)
+
+// filterChannelRef prefixes filter keys that name a channel instead of its ID.
+const filterChannelRef = "channelRef:"
+
+type WorkflowStore interface {
+	FindByID(ctx context.Context, id string) (workflow.Workflow, error)
+	Save(ctx context.Context, wf workflow.Workflow) (workflow.Workflow, error)
+}
+
+type TemplateStore interface {
+	FindByID(ctx context.Context, id string) (templates.Template, error)
+	List(ctx context.Context) ([]templates.Template, error)
+	Save(ctx context.Context, tpl templates.Template) (templates.Template, error)
+}
+
+type ChannelStore interface {
+	FindByID(ctx context.Context, id string) (channel.Channel, error)
+	FindByName(ctx context.Context, name string) ([]channel.Channel, error)
+}
+
+// ConflictMode decides what happens when the bundle's workflow ID already exists.
+type ConflictMode string
+
+const (
+	ConflictFail      ConflictMode = "fail"
+	ConflictOverwrite ConflictMode = "overwrite"
+	ConflictCopy      ConflictMode = "copy"
+)
+
+func ParseConflictMode(value string) (ConflictMode, error) {
+	switch ConflictMode(value) {
+	case "", ConflictFail:
+		return ConflictFail, nil
+	case ConflictOverwrite, ConflictCopy:
+		return ConflictMode(value), nil
+	}
+	return "", fmt.Errorf("unknown conflict mode %q", value)
+}
+
+var ErrConflict = errors.New("workflow already exists")
+
+// UnresolvedError lists references that could not be mapped to objects in this environment.
+type UnresolvedError struct {
+	Refs []string
+}
+
+func (e *UnresolvedError) Error() string {
+	return "unresolved references: " + strings.Join(e.Refs, "; ")
+}
+
+type ImportOptions struct {
+	OnConflict ConflictMode
+	// Channels maps channel names from the bundle to channel IDs in this environment.
+	// Names without an entry are looked up by name.
+	Channels map[string]string
+}
+
+type ImportResult struct {
+	Workflow         workflow.Workflow `json:"workflow"`
+	Created          bool              `json:"created"`
+	Channels         map[string]string `json:"channels"`
+	TemplatesCreated []string          `json:"templatesCreated"`
+}
+
+type Service struct {
+	workflows WorkflowStore
+	templates TemplateStore
+	channels  ChannelStore
+}
+
+func NewService(workflows WorkflowStore, templates TemplateStore, channels ChannelStore) *Service {
+	return &Service{workflows: workflows, templates: templates, channels: channels}
+}
+
+// Export builds a bundle from the workflow draft. Channel IDs in node configs and filter
+// keys are replaced by name references, and template bodies that match a stored
+// template by a reference to it; unknown IDs and other bodies are left untouched.
+// Webhook tokens and secrets are redacted: importing over the same workflow keeps the
+// stored values, importing elsewhere generates a new token.
+func (s *Service) Export(ctx context.Context, workflowID string) (Bundle, error) {
+	wf, err := s.workflows.FindByID(ctx, workflowID)
+	if err != nil {
+		return Bundle{}, err
+	}
+	wf = workflow.RedactWebhookSecrets(wf)
+
+	b := Bundle{
+		Kind:       Kind,
+		Version:    FormatVersion,
+		ExportedAt: time.Now().UTC(),
+		Workflow: WorkflowSpec{
+			ID:          wf.ID,
+			Name:        wf.Name,
+			Description: wf.Description,
+			Edges:       wf.Edges,
+			IsActive:    wf.IsActive,
+			CanvasZoom:  wf.CanvasZoom,
+		},
+	}
+
+	byBody, err := s.templatesByBody(ctx)
+	if err != nil {
+		return Bundle{}, err
+	}
+	channelNames := make(map[string]string)
+	exportChannel := func(id, connectorType string) (string, bool) {
+		if name, ok := channelNames[id]; ok {
+			return name, true
+		}
+		ch, err := s.channels.FindByID(ctx, id)
+		if err != nil {
+			return "", false
+		}
+		channelNames[id] = ch.Name
+		b.Channels = append(b.Channels, ChannelRef{
+			Name:          ch.Name,
+			DisplayName:   ch.DisplayName,
+			ConnectorType: connectorType,
+		})
+		return ch.Name, true
+	}
+
+	seenTemplates := make(map[string]bool)
+	b.Workflow.Nodes = make([]workflow.Node, len(wf.Nodes))
+	for i, node := range wf.Nodes {
+		cfg, ok := configMap(node.Config)
+		if !ok {
+			b.Workflow.Nodes[i] = node
+			continue
+		}
+
+		if id := stringValue(cfg, configChannelID); id != "" {
+			if name, ok := exportChannel(id, stringValue(cfg, configConnectorType)); ok {
+				delete(cfg, configChannelID)
+				delete(cfg, configConnectorID)
+				cfg[configChannelRef] = name
+			}
+		}
+
+		if body := stringValue(cfg, configTemplateBody); body != "" {
+			if tpl, ok := byBody[body]; ok {
+				delete(cfg, configTemplateBody)
+				cfg[configTemplateRef] = tpl.Name
+				if !seenTemplates[tpl.Name] {
+					seenTemplates[tpl.Name] = true
+					b.Templates = append(b.Templates, TemplateSpec{
+						Name:        tpl.Name,
+						Description: tpl.Description,
+						Body:        tpl.Body,
+						Variables:   tpl.Variables,
+					})
+				}
+			}
+		}
+
+		node.Config = cfg
+		b.Workflow.Nodes[i] = node
+	}
+
+	if len(wf.Filters) > 0 {
+		b.Workflow.Filters = make(map[string]string, len(wf.Filters))
+		for key, value := range wf.Filters {
+			if name, ok := exportChannel(key, ""); ok {
+				key = filterChannelRef + name
+			}
+			b.Workflow.Filters[key] = value
+		}
+	}
+
+	return b, nil
+}
+
+// Resolved is a bundle workflow with its references mapped onto this environment.
+type Resolved struct {
+	Workflow workflow.Workflow
+	Channels map[string]string
+	// MissingTemplates are bundle templates that do not exist here yet. Nodes using them
+	// already carry the body from the bundle; Import creates the templates.
+	MissingTemplates []TemplateSpec
+}
+
+// Resolve maps channel references to local IDs and template references to template
+// bodies without writing anything. A template that exists here by name supplies its
+// body; otherwise the body comes from the bundle. Missing or ambiguous channels fail
+// with an UnresolvedError.
+func (s *Service) Resolve(ctx context.Context, b Bundle, channelMap map[string]string) (Resolved, error) {
+	resolved := Resolved{Channels: map[string]string{}}
+
+	existing, err := s.templatesByName(ctx)
+	if err != nil {
+		return resolved, err
+	}
+	specs := make(map[string]TemplateSpec, len(b.Templates))
+	for _, spec := range b.Templates {
+		specs[spec.Name] = spec
+	}
+
+	channels := make(map[string]channel.Channel)
+	resolveChannel := func(name string) (channel.Channel, error) {
+		if ch, ok := channels[name]; ok {
+			return ch, nil
+		}
+		ch, err := s.resolveChannel(ctx, name, channelMap)
+		if err != nil {
+			return channel.Channel{}, err
+		}
+		channels[name] = ch
+		resolved.Channels[name] = ch.ID
+		return ch, nil
+	}
+
+	nodes := make([]workflow.Node, len(b.Workflow.Nodes))
+	missing := make(map[string]bool)
+	var unresolved []string
+	for i, node := range b.Workflow.Nodes {
+		nodes[i] = node
+		cfg, ok := configMap(node.Config)
+		if !ok {
+			continue
+		}
+
+		if name := stringValue(cfg, configChannelRef); name != "" {
+			ch, err := resolveChannel(name)
+			if err != nil {
+				unresolved = append(unresolved, fmt.Sprintf("node %s: %v", node.ID, err))
+				continue
+			}
+			delete(cfg, configChannelRef)
+			cfg[configChannelID] = ch.ID
+			cfg[configConnectorID] = ch.ConnectorID
+			cfg[configChannelName] = ch.Name
+		}
+
+		if name := stringValue(cfg, configTemplateRef); name != "" {
+			if tpl, ok := existing[name]; ok {
+				delete(cfg, configTemplateRef)
+				cfg[configTemplateBody] = tpl.Body
+			} else if spec, ok := specs[name]; ok {
+				delete(cfg, configTemplateRef)
+				cfg[configTemplateBody] = spec.Body
+				if !missing[name] {
+					missing[name] = true
+					resolved.MissingTemplates = append(resolved.MissingTemplates, spec)
+				}
+			} else {
+				unresolved = append(unresolved, fmt.Sprintf("node %s: template %q is not in the bundle", node.ID, name))
+			}
+		}
+
+		nodes[i].Config = cfg
+	}
+
+	var filters map[string]string
+	if b.Workflow.Filters != nil {
+		filters = make(map[string]string, len(b.Workflow.Filters))
+		for key, value := range b.Workflow.Filters {
+			if name, ok := strings.CutPrefix(key, filterChannelRef); ok {
+				ch, err := resolveChannel(name)
+				if err != nil {
+					unresolved = append(unresolved, fmt.Sprintf("filter %s: %v", key, err))
+					continue
+				}
+				key = ch.ID
+			}
+			filters[key] = value
+		}
+	}
+	if len(unresolved) > 0 {
+		return resolved, &UnresolvedError{Refs: unresolved}
+	}
+
+	resolved.Workflow = workflow.Workflow{
+		ID:          b.Workflow.ID,
+		Name:        b.Workflow.Name,
+		Description: b.Workflow.Description,
+		Nodes:       nodes,
+		Edges:       b.Workflow.Edges,
+		Filters:     filters,
+		IsActive:    b.Workflow.IsActive,
+		CanvasZoom:  b.Workflow.CanvasZoom,
+	}
+	return resolved, nil
+}
+
+// Import resolves the bundle, creates templates it brings along and saves the workflow.
+func (s *Service) Import(ctx context.Context, b Bundle, opts ImportOptions) (ImportResult, error) {
+	result := ImportResult{Channels: map[string]string{}, TemplatesCreated: []string{}}
+
+	id, created, err := s.resolveID(ctx, b.Workflow.ID, opts.OnConflict)
+	if err != nil {
+		return result, err
+	}
+	result.Created = created
+
+	resolved, err := s.Resolve(ctx, b, opts.Channels)
+	if err != nil {
+		return result, err
+	}
+	result.Channels = resolved.Channels
+
+	for _, spec := range resolved.MissingTemplates {
+		_, err := s.templates.Save(ctx, templates.Template{
+			ID:          uuid.NewString(),
+			Name:        spec.Name,
+			Description: spec.Description,
+			Body:        spec.Body,
+			Variables:   spec.Variables,
+		})
+		if err != nil {
+			return result, err
+		}
+		result.TemplatesCreated = append(result.TemplatesCreated, spec.Name)
+	}
+
+	var previous []workflow.Node
+	if !created {
+		if current, err := s.workflows.FindByID(ctx, id); err == nil {
+			previous = current.Nodes
+		}
+	}
+
+	wf := resolved.Workflow
+	wf.ID = id
+
+	saved, err := s.workflows.Save(ctx, wf)
+	if err != nil {
+		return result, err
+	}
+	result.Workflow = workflow.RevealNewWebhookTokens(saved, previous)
+	return result, nil
+}
+
+func (s *Service) resolveID(ctx context.Context, id string, mode ConflictMode) (string, bool, error) {
+	if id == "" {
+		return uuid.NewString(), true, nil
+	}
+	if _, err := s.workflows.FindByID(ctx, id); errors.Is(err, workflow.ErrNotFound) {
+		return id, true, nil
+	} else if err != nil {
+		return "", false, err
+	}
+
+	switch mode {
+	case ConflictOverwrite:
+		return id, false, nil
+	case ConflictCopy:
+		return uuid.NewString(), true, nil
+	default:
+		return "", false, fmt.Errorf("%w: %s", ErrConflict, id)
+	}
+}
+
+func (s *Service) resolveChannel(ctx context.Context, name string, mapping map[string]string) (channel.Channel, error) {
+	if id, ok := mapping[name]; ok {
+		ch, err := s.channels.FindByID(ctx, id)
+		if err != nil {
+			return channel.Channel{}, fmt.Errorf("channel %q mapped to unknown id %s", name, id)
+		}
+		return ch, nil
+	}
+
+	matches, err := s.channels.FindByName(ctx, name)
+	if err != nil {
+		return channel.Channel{}, err
+	}
+	switch len(matches) {
+	case 0:
+		return channel.Channel{}, fmt.Errorf("channel %q not found", name)
+	case 1:
+		return matches[0], nil
+	default:
+		return channel.Channel{}, fmt.Errorf("channel %q is ambiguous (%d matches), map it explicitly", name, len(matches))
+	}
+}
+
+func (s *Service) templatesByName(ctx context.Context) (map[string]templates.Template, error) {
+	byName := make(map[string]templates.Template)
+	if s.templates == nil {
+		return byName, nil
+	}
+	existing, err := s.templates.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, tpl := range existing {
+		byName[tpl.Name] = tpl
+	}
+	return byName, nil
+}
+
+// templatesByBody indexes stored templates by body; when several share a body the
+// first by name wins, so exports are stable.
+func (s *Service) templatesByBody(ctx context.Context) (map[string]templates.Template, error) {
+	byName, err := s.templatesByName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	byBody := make(map[string]templates.Template, len(names))
+	for _, name := range names {
+		tpl := byName[name]
+		if _, ok := byBody[tpl.Body]; !ok && tpl.Body != "" {
+			byBody[tpl.Body] = tpl
+		}
+	}
+	return byBody, nil
+}
+
+// configMap copies node config into a fresh map so callers can rewrite it freely.
+func configMap(cfg any) (map[string]any, bool) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, false
+	}
+	var out map[string]any
+	if err := json.Unmarshal(data, &out); err != nil || out == nil {
+		return nil, false
+	}
+	return out, true
+}
+
+func stringValue(cfg map[string]any, key string) string {
+	s, _ := cfg[key].(string)
+	return s
+}
@@ -0,0 +1,243 @@
+package bundle
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"notiair/internal/persistence/channel"
+	"notiair/internal/templates"
+	"notiair/internal/workflow"
+)
+
+type fakeChannels struct {
+	byID map[string]channel.Channel
+}
+
+func (f *fakeChannels) FindByID(ctx context.Context, id string) (channel.Channel, error) {
+	ch, ok := f.byID[id]
+	if !ok {
+		return channel.Channel{}, errors.New("not found")
+	}
+	return ch, nil
+}
+
+// failingWorkflows fails every lookup with a storage error.
+type failingWorkflows struct {
+	workflow.Repository
+}
+
+func (failingWorkflows) FindByID(ctx context.Context, id string) (workflow.Workflow, error) {
+	return workflow.Workflow{}, errors.New("database is down")
+}
+
+func (f *fakeChannels) FindByName(ctx context.Context, name string) ([]channel.Channel, error) {
+	var out []channel.Channel
+	for _, ch := range f.byID {
+		if ch.Name == name {
+			out = append(out, ch)
+		}
+	}
+	return out, nil
+}
+
+func sourceWorkflow() workflow.Workflow {
+	return workflow.Workflow{
+		ID:   "wf-1",
+		Name: "Orders",
+		Nodes: []workflow.Node{
+			{ID: "tr", Type: workflow.NodeTypeTrigger, Config: map[string]any{"variant": "trigger"}},
+			{ID: "tpl", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "template", "templateBody": "Order {{id}}"}},
+			{ID: "ch", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "channel", "channelId": "ch-staging", "connectorId": "bot-staging", "connectorType": "telegram"}},
+		},
+		Edges:   []workflow.Edge{{From: "tr", To: "tpl"}, {From: "tpl", To: "ch"}},
+		Filters: map[string]string{"ch-staging": "order.*"},
+	}
+}
+
+func exportSource(t *testing.T) Bundle {
+	t.Helper()
+	ctx := context.Background()
+
+	wfRepo := workflow.NewMemoryRepository()
+	if _, err := wfRepo.Save(ctx, sourceWorkflow()); err != nil {
+		t.Fatalf("save workflow: %v", err)
+	}
+	tplRepo := templates.NewMemoryRepository()
+	if _, err := tplRepo.Save(ctx, templates.Template{ID: "tpl-staging", Name: "order-created", Body: "Order {{id}}"}); err != nil {
+		t.Fatalf("save template: %v", err)
+	}
+	channels := &fakeChannels{byID: map[string]channel.Channel{
+		"ch-staging": {ID: "ch-staging", ConnectorID: "bot-staging", Name: "@orders"},
+	}}
+
+	b, err := NewService(wfRepo, tplRepo, channels).Export(ctx, "wf-1")
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	return b
+}
+
+func TestExportReplacesIDsWithNames(t *testing.T) {
+	b := exportSource(t)
+
+	if len(b.Channels) != 1 || b.Channels[0].Name != "@orders" || b.Channels[0].ConnectorType != "telegram" {
+		t.Fatalf("unexpected channels %+v", b.Channels)
+	}
+	if len(b.Templates) != 1 || b.Templates[0].Body != "Order {{id}}" {
+		t.Fatalf("unexpected templates %+v", b.Templates)
+	}
+
+	cfg := b.Workflow.Nodes[2].Config.(map[string]any)
+	if cfg["channelRef"] != "@orders" || cfg["channelId"] != nil || cfg["connectorId"] != nil {
+		t.Fatalf("channel node not rewritten: %v", cfg)
+	}
+	tplCfg := b.Workflow.Nodes[1].Config.(map[string]any)
+	if tplCfg["templateRef"] != "order-created" || tplCfg["templateBody"] != nil {
+		t.Fatalf("template node not rewritten: %v", tplCfg)
+	}
+	if len(b.Workflow.Filters) != 1 || b.Workflow.Filters["channelRef:@orders"] != "order.*" {
+		t.Fatalf("filters not rewritten: %v", b.Workflow.Filters)
+	}
+}
+
+func TestExportRedactsWebhookSecrets(t *testing.T) {
+	ctx := context.Background()
+	wfRepo := workflow.NewMemoryRepository()
+	wf := sourceWorkflow()
+	wf.Nodes[0].Config = map[string]any{"variant": "trigger", "triggerKind": workflow.TriggerKindWebhook, "webhookSecret": "s3cret"}
+	stored, err := wfRepo.Save(ctx, wf)
+	if err != nil {
+		t.Fatalf("save workflow: %v", err)
+	}
+	svc := NewService(wfRepo, templates.NewMemoryRepository(), &fakeChannels{byID: map[string]channel.Channel{}})
+
+	b, err := svc.Export(ctx, "wf-1")
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	cfg := b.Workflow.Nodes[0].Config.(map[string]any)
+	if cfg["webhookToken"] != workflow.RedactedSecret || cfg["webhookSecret"] != workflow.RedactedSecret {
+		t.Fatalf("webhook secrets exported: %v", cfg)
+	}
+
+	// Importing over the same workflow keeps the stored values.
+	if _, err := svc.Import(ctx, b, ImportOptions{OnConflict: ConflictOverwrite}); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	current, _ := wfRepo.FindByID(ctx, "wf-1")
+	if current.Nodes[0].Config.(map[string]any)["webhookToken"] != stored.Nodes[0].Config.(map[string]any)["webhookToken"] ||
+		current.Nodes[0].Config.(map[string]any)["webhookSecret"] != "s3cret" {
+		t.Fatalf("stored secrets lost on import: %v", current.Nodes[0].Config)
+	}
+}
+
+func TestImportRemapsReferences(t *testing.T) {
+	ctx := context.Background()
+	data, err := Encode(exportSource(t), FormatYAML)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	b, err := Decode(data, FormatYAML)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	wfRepo := workflow.NewMemoryRepository()
+	tplRepo := templates.NewMemoryRepository()
+	channels := &fakeChannels{byID: map[string]channel.Channel{
+		"ch-prod": {ID: "ch-prod", ConnectorID: "bot-prod", Name: "@orders"},
+	}}
+	svc := NewService(wfRepo, tplRepo, channels)
+
+	result, err := svc.Import(ctx, b, ImportOptions{})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if !result.Created || result.Workflow.ID != "wf-1" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if len(result.TemplatesCreated) != 1 {
+		t.Fatalf("expected template to be created, got %v", result.TemplatesCreated)
+	}
+
+	cfg := result.Workflow.Nodes[2].Config.(map[string]any)
+	if cfg["channelId"] != "ch-prod" || cfg["connectorId"] != "bot-prod" {
+		t.Fatalf("channel not remapped: %v", cfg)
+	}
+	tplCfg := result.Workflow.Nodes[1].Config.(map[string]any)
+	if tplCfg["templateBody"] != "Order {{id}}" || tplCfg["templateRef"] != nil {
+		t.Fatalf("template not resolved: %v", tplCfg)
+	}
+	if len(result.Workflow.Filters) != 1 || result.Workflow.Filters["ch-prod"] != "order.*" {
+		t.Fatalf("filters not remapped: %v", result.Workflow.Filters)
+	}
+
+	if _, err := svc.Import(ctx, b, ImportOptions{}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	copied, err := svc.Import(ctx, b, ImportOptions{OnConflict: ConflictCopy})
+	if err != nil || copied.Workflow.ID == "wf-1" {
+		t.Fatalf("expected copy with new id, got %+v %v", copied.Workflow.ID, err)
+	}
+	if len(copied.TemplatesCreated) != 0 {
+		t.Fatalf("existing template should be reused, got %v", copied.TemplatesCreated)
+	}
+}
+
+func TestImportReportsUnresolvedChannels(t *testing.T) {
+	b := exportSource(t)
+	svc := NewService(workflow.NewMemoryRepository(), templates.NewMemoryRepository(), &fakeChannels{byID: map[string]channel.Channel{}})
+
+	var unresolved *UnresolvedError
+	if _, err := svc.Import(context.Background(), b, ImportOptions{}); !errors.As(err, &unresolved) {
+		t.Fatalf("expected UnresolvedError, got %v", err)
+	}
+}
+
+func TestImportUsesExistingTemplateBody(t *testing.T) {
+	ctx := context.Background()
+	b := exportSource(t)
+	tplRepo := templates.NewMemoryRepository()
+	if _, err := tplRepo.Save(ctx, templates.Template{Name: "order-created", Body: "Order {{id}} created"}); err != nil {
+		t.Fatalf("save template: %v", err)
+	}
+	channels := &fakeChannels{byID: map[string]channel.Channel{
+		"ch-prod": {ID: "ch-prod", ConnectorID: "bot-prod", Name: "@orders"},
+	}}
+
+	result, err := NewService(workflow.NewMemoryRepository(), tplRepo, channels).Import(ctx, b, ImportOptions{})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(result.TemplatesCreated) != 0 {
+		t.Fatalf("existing template should be reused, got %v", result.TemplatesCreated)
+	}
+	if body := result.Workflow.Nodes[1].Config.(map[string]any)["templateBody"]; body != "Order {{id}} created" {
+		t.Fatalf("expected the local template body, got %v", body)
+	}
+}
+
+func TestImportReportsUnresolvedFilterChannels(t *testing.T) {
+	b := exportSource(t)
+	b.Workflow.Nodes = b.Workflow.Nodes[:2]
+	svc := NewService(workflow.NewMemoryRepository(), templates.NewMemoryRepository(), &fakeChannels{byID: map[string]channel.Channel{}})
+
+	var unresolved *UnresolvedError
+	if _, err := svc.Import(context.Background(), b, ImportOptions{}); !errors.As(err, &unresolved) {
+		t.Fatalf("expected UnresolvedError, got %v", err)
+	}
+}
+
+func TestImportFailsOnWorkflowLookupError(t *testing.T) {
+	b := exportSource(t)
+	channels := &fakeChannels{byID: map[string]channel.Channel{
+		"ch-prod": {ID: "ch-prod", ConnectorID: "bot-prod", Name: "@orders"},
+	}}
+	svc := NewService(failingWorkflows{}, templates.NewMemoryRepository(), channels)
+
+	result, err := svc.Import(context.Background(), b, ImportOptions{})
+	if err == nil || err.Error() != "database is down" {
+		t.Fatalf("expected the lookup error, got %+v %v", result, err)
+	}
+}
@@ -0,0 +1,218 @@
+package cloudevents
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"sort"
+	"strings"
+)
+
+// ContentType marks a structured-mode CloudEvent.
+const ContentType = "application/cloudevents+json"
+
+var ErrInvalid = errors.New("invalid cloudevent")
+
+// Event is a CloudEvent (spec 1.0) independent of the transport it arrived on.
+type Event struct {
+	SpecVersion     string
+	ID              string
+	Type            string
+	Source          string
+	Subject         string
+	Time            string
+	DataContentType string
+	DataSchema      string
+	Data            any
+	Extensions      map[string]any
+}
+
+var contextAttributes = map[string]bool{
+	"specversion":     true,
+	"id":              true,
+	"type":            true,
+	"source":          true,
+	"subject":         true,
+	"time":            true,
+	"datacontenttype": true,
+	"dataschema":      true,
+	"data":            true,
+	"data_base64":     true,
+}
+
+// IsStructured reports whether body is a structured-mode CloudEvent: either the content
+// type says so or the body is a JSON object with a specversion attribute.
+func IsStructured(contentType string, body []byte) bool {
+	if mediaType(contentType) == ContentType {
+		return true
+	}
+	var probe struct {
+		SpecVersion *string `json:"specversion"`
+	}
+	return json.Unmarshal(body, &probe) == nil && probe.SpecVersion != nil
+}
+
+// ParseStructured decodes a structured-mode JSON CloudEvent.
+func ParseStructured(body []byte) (Event, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return Event{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	attrs := make(map[string]string)
+	ev := Event{Extensions: map[string]any{}}
+	for name, value := range raw {
+		if name == "data" || name == "data_base64" {
+			continue
+		}
+		var decoded any
+		if err := json.Unmarshal(value, &decoded); err != nil {
+			return Event{}, fmt.Errorf("%w: attribute %s: %v", ErrInvalid, name, err)
+		}
+		if contextAttributes[name] {
+			s, ok := decoded.(string)
+			if !ok {
+				return Event{}, fmt.Errorf("%w: attribute %s must be a string", ErrInvalid, name)
+			}
+			attrs[name] = s
+			continue
+		}
+		ev.Extensions[name] = decoded
+	}
+	ev.setAttributes(attrs)
+
+	switch {
+	case raw["data_base64"] != nil:
+		var encoded string
+		if err := json.Unmarshal(raw["data_base64"], &encoded); err != nil {
+			return Event{}, fmt.Errorf("%w: data_base64: %v", ErrInvalid, err)
+		}
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return Event{}, fmt.Errorf("%w: data_base64: %v", ErrInvalid, err)
+		}
+		ev.Data = decodeData(ev.DataContentType, data)
+	case raw["data"] != nil:
+		if err := json.Unmarshal(raw["data"], &ev.Data); err != nil {
+			return Event{}, fmt.Errorf("%w: data: %v", ErrInvalid, err)
+		}
+	}
+	return ev, ev.validate()
+}
+
+// FromBinary builds a binary-mode CloudEvent from its attributes, named without the
+// transport prefix ("id", not "ce_id"), and the message body, which is the event data.
+func FromBinary(attrs map[string]string, contentType string, body []byte) (Event, error) {
+	ev := Event{Extensions: map[string]any{}}
+	known := make(map[string]string)
+	for name, value := range attrs {
+		name = strings.ToLower(name)
+		if contextAttributes[name] {
+			known[name] = value
+		} else {
+			ev.Extensions[name] = value
+		}
+	}
+	if known["datacontenttype"] == "" {
+		known["datacontenttype"] = contentType
+	}
+	ev.setAttributes(known)
+	if len(body) > 0 {
+		ev.Data = decodeData(ev.DataContentType, body)
+	}
+	return ev, ev.validate()
+}
+
+// Context returns the data as the object workflows read under "context"; data that
+// is not an object is wrapped as {"data": ...}.
+func (e Event) Context() map[string]any {
+	switch data := e.Data.(type) {
+	case map[string]any:
+		return data
+	case nil:
+		return map[string]any{}
+	default:
+		return map[string]any{"data": data}
+	}
+}
+
+// Metadata returns the remaining attributes and extensions, read under "metadata".
+func (e Event) Metadata() map[string]any {
+	meta := make(map[string]any, len(e.Extensions)+5)
+	for name, value := range e.Extensions {
+		meta[name] = value
+	}
+	meta["specversion"] = e.SpecVersion
+	meta["source"] = e.Source
+	for name, value := range map[string]string{"subject": e.Subject, "datacontenttype": e.DataContentType, "dataschema": e.DataSchema} {
+		if value != "" {
+			meta[name] = value
+		}
+	}
+	return meta
+}
+
+// Fields maps the event onto the payload keys stream triggers use.
+func (e Event) Fields() map[string]any {
+	return map[string]any{
+		"event_id":    e.ID,
+		"event_type":  e.Type,
+		"occurred_at": e.Time,
+		"context":     e.Context(),
+		"metadata":    e.Metadata(),
+	}
+}
+
+func (e *Event) setAttributes(attrs map[string]string) {
+	e.SpecVersion = attrs["specversion"]
+	e.ID = attrs["id"]
+	e.Type = attrs["type"]
+	e.Source = attrs["source"]
+	e.Subject = attrs["subject"]
+	e.Time = attrs["time"]
+	e.DataContentType = attrs["datacontenttype"]
+	e.DataSchema = attrs["dataschema"]
+}
+
+func (e Event) validate() error {
+	var missing []string
+	for name, value := range map[string]string{"specversion": e.SpecVersion, "id": e.ID, "type": e.Type, "source": e.Source} {
+		if value == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%w: missing %s", ErrInvalid, strings.Join(missing, ", "))
+	}
+	if !strings.HasPrefix(e.SpecVersion, "1.") {
+		return fmt.Errorf("%w: unsupported specversion %q", ErrInvalid, e.SpecVersion)
+	}
+	return nil
+}
+
+// decodeData decodes JSON data (including when no content type is given) and passes
+// anything else through as a string.
+func decodeData(contentType string, data []byte) any {
+	mt := mediaType(contentType)
+	if mt == "" || strings.HasSuffix(mt, "json") {
+		var decoded any
+		if err := json.Unmarshal(data, &decoded); err == nil {
+			return decoded
+		}
+	}
+	return string(data)
+}
+
+func mediaType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mt
+}
@@ -0,0 +1,64 @@
+package cloudevents
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseStructured(t *testing.T) {
+	body := []byte(`{"specversion":"1.0","id":"e-1","type":"order.created","source":"/shop","time":"2024-05-01T10:00:00Z",
+		"datacontenttype":"application/json","tenant":"acme","data":{"order":{"id":42}}}`)
+	if !IsStructured("", body) {
+		t.Fatal("body with specversion should be recognised")
+	}
+
+	ev, err := ParseStructured(body)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if ev.ID != "e-1" || ev.Type != "order.created" || ev.Time != "2024-05-01T10:00:00Z" {
+		t.Fatalf("unexpected attributes: %+v", ev)
+	}
+	if want := map[string]any{"order": map[string]any{"id": float64(42)}}; !reflect.DeepEqual(ev.Context(), want) {
+		t.Fatalf("context %v, want %v", ev.Context(), want)
+	}
+	meta := ev.Metadata()
+	if meta["tenant"] != "acme" || meta["source"] != "/shop" || meta["specversion"] != "1.0" {
+		t.Fatalf("unexpected metadata: %v", meta)
+	}
+}
+
+func TestParseStructuredBase64Data(t *testing.T) {
+	ev, err := ParseStructured([]byte(`{"specversion":"1.0","id":"e-1","type":"t","source":"s","datacontenttype":"text/plain","data_base64":"aGVsbG8="}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if want := map[string]any{"data": "hello"}; !reflect.DeepEqual(ev.Context(), want) {
+		t.Fatalf("context %v, want %v", ev.Context(), want)
+	}
+}
+
+func TestFromBinary(t *testing.T) {
+	ev, err := FromBinary(map[string]string{"specversion": "1.0", "id": "e-2", "type": "user.created", "source": "/crm", "Traceparent": "00-abc"},
+		"application/json", []byte(`{"user":{"email":"a@b.c"}}`))
+	if err != nil {
+		t.Fatalf("binary: %v", err)
+	}
+	if ev.DataContentType != "application/json" || ev.Extensions["traceparent"] != "00-abc" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+	if ev.Context()["user"] == nil {
+		t.Fatalf("data should be decoded: %v", ev.Context())
+	}
+}
+
+func TestValidateRequiredAttributes(t *testing.T) {
+	_, err := ParseStructured([]byte(`{"specversion":"1.0","type":"t"}`))
+	if !errors.Is(err, ErrInvalid) || err.Error() != "invalid cloudevent: missing id, source" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := FromBinary(map[string]string{"specversion": "0.3", "id": "1", "type": "t", "source": "s"}, "", nil); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected unsupported specversion error, got %v", err)
+	}
+}
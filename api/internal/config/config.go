@@ -4,6 +4,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type HTTPConfig struct {
@@ -26,21 +27,90 @@ type DatabaseConfig struct {
 }
 
 type StreamConfig struct {
-	Brokers []string
-	Topic   string
-	GroupID string
+	// KafkaEnabled runs the Kafka consumer and dead-letter queue in this process; turn it
+	// off in deployments that read only Redis Streams or NATS.
+	KafkaEnabled bool
+	Brokers      []string
+	Topic        string
+	GroupID      string
+	// DLQTopic receives messages that could not be parsed or processed after retries.
+	DLQTopic string
+	// MaxRetries is how many times a failed event is retried before it goes to DLQTopic.
+	MaxRetries int
+	// RetryBackoff is the first retry delay; it doubles up to RetryMaxBackoff.
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	// DedupRetention is how long processed (event_id, workflow_id) pairs are remembered.
+	DedupRetention time.Duration
+	// RejectionRetention is how long events that failed schema validation are kept.
+	RejectionRetention time.Duration
+	// Workers is how many messages of one partition are processed at once.
+	Workers int
+	// OrderingKey is the event path that keeps events in order, e.g. "context.order.id";
+	// empty means the Kafka message key.
+	OrderingKey string
+	// Redis configures stream triggers with broker "redis", read from Redis Streams.
+	Redis RedisStreamsConfig
+	// NATS configures stream triggers with broker "nats", read from NATS JetStream.
+	NATS NATSStreamsConfig
+}
+
+type RedisStreamsConfig struct {
+	// Enabled runs the Redis Streams consumer in this process (it uses REDIS_URL).
+	Enabled  bool
+	Group    string
+	Consumer string
+	// DefaultStream is read by redis triggers without a topic; empty skips them.
+	DefaultStream string
+	// ClaimIdle is how long an unacknowledged entry stays with a consumer before another takes it.
+	ClaimIdle time.Duration
+}
+
+type NATSStreamsConfig struct {
+	// URL of the NATS server; empty disables the NATS consumer.
+	URL     string
+	Stream  string
+	Durable string
+	// DefaultSubject is read by nats triggers without a topic; empty skips them.
+	DefaultSubject string
+	// AckWait is how long an unacknowledged message waits before redelivery.
+	AckWait time.Duration
+	// DeadLetterPrefix prefixes the subject of messages that failed after retries.
+	DeadLetterPrefix string
 }
 
 type RedisConfig struct {
 	URL string
 }
 
+type WorkflowConfig struct {
+	// MaxVersions caps unpinned versions kept per workflow; 0 disables pruning.
+	MaxVersions int
+}
+
+type ExecutionConfig struct {
+	// RetentionDays is how long execution history is kept; 0 keeps it forever.
+	RetentionDays int
+}
+
+type ScheduleConfig struct {
+	// Enabled runs schedule triggers in this process.
+	Enabled bool
+	// SyncInterval is how often the leader reloads cron entries from published workflows.
+	SyncInterval time.Duration
+	// LeaderTTL is the lease held by the replica that registers cron entries.
+	LeaderTTL time.Duration
+}
+
 type Config struct {
-	HTTP   HTTPConfig
-	Queue  QueueConfig
-	DB     DatabaseConfig
-	Stream StreamConfig
-	Redis  RedisConfig
+	HTTP      HTTPConfig
+	Queue     QueueConfig
+	DB        DatabaseConfig
+	Stream    StreamConfig
+	Redis     RedisConfig
+	Workflow  WorkflowConfig
+	Execution ExecutionConfig
+	Schedule  ScheduleConfig
 }
 
 func Load() (Config, error) {
@@ -62,13 +132,49 @@ func Load() (Config, error) {
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
 		},
 		Stream: StreamConfig{
-			Brokers: parseBrokers(getEnv("STREAM_BROKERS", "localhost:19092")),
-			Topic:   getEnv("STREAM_TOPIC", "test-topic"),
-			GroupID: getEnv("STREAM_GROUP_ID", "notiair-workflow-consumer"),
+			KafkaEnabled: getEnvBool("STREAM_KAFKA_ENABLED", true),
+			Brokers:      parseBrokers(getEnv("STREAM_BROKERS", "localhost:19092")),
+			Topic:        getEnv("STREAM_TOPIC", "test-topic"),
+			GroupID:      getEnv("STREAM_GROUP_ID", "notiair-workflow-consumer"),
+
+			DLQTopic:           getEnv("STREAM_DLQ_TOPIC", "notiair-dlq"),
+			MaxRetries:         getEnvInt("STREAM_MAX_RETRIES", 3),
+			RetryBackoff:       time.Duration(getEnvInt("STREAM_RETRY_BACKOFF_MS", 500)) * time.Millisecond,
+			RetryMaxBackoff:    time.Duration(getEnvInt("STREAM_RETRY_MAX_BACKOFF_MS", 10000)) * time.Millisecond,
+			DedupRetention:     time.Duration(getEnvInt("STREAM_DEDUP_RETENTION_HOURS", 168)) * time.Hour,
+			RejectionRetention: time.Duration(getEnvInt("STREAM_REJECTION_RETENTION_HOURS", 168)) * time.Hour,
+			Workers:            getEnvInt("STREAM_WORKERS", 1),
+			OrderingKey:        getEnv("STREAM_ORDERING_KEY", ""),
+			Redis: RedisStreamsConfig{
+				Enabled:       getEnvBool("STREAM_REDIS_ENABLED", true),
+				Group:         getEnv("STREAM_REDIS_GROUP", "notiair-workflow-consumer"),
+				Consumer:      getEnv("STREAM_REDIS_CONSUMER", hostname()),
+				DefaultStream: getEnv("STREAM_REDIS_DEFAULT_STREAM", ""),
+				ClaimIdle:     time.Duration(getEnvInt("STREAM_REDIS_CLAIM_IDLE_SECONDS", 60)) * time.Second,
+			},
+			NATS: NATSStreamsConfig{
+				URL:              getEnv("STREAM_NATS_URL", ""),
+				Stream:           getEnv("STREAM_NATS_STREAM", "EVENTS"),
+				Durable:          getEnv("STREAM_NATS_DURABLE", "notiair-workflow-consumer"),
+				DefaultSubject:   getEnv("STREAM_NATS_DEFAULT_SUBJECT", ""),
+				AckWait:          time.Duration(getEnvInt("STREAM_NATS_ACK_WAIT_SECONDS", 30)) * time.Second,
+				DeadLetterPrefix: getEnv("STREAM_NATS_DLQ_PREFIX", "notiair.dlq"),
+			},
 		},
 		Redis: RedisConfig{
 			URL: getEnv("REDIS_URL", "localhost:6379"),
 		},
+		Workflow: WorkflowConfig{
+			MaxVersions: getEnvInt("WORKFLOW_MAX_VERSIONS", 100),
+		},
+		Execution: ExecutionConfig{
+			RetentionDays: getEnvInt("EXECUTION_RETENTION_DAYS", 14),
+		},
+		Schedule: ScheduleConfig{
+			Enabled:      getEnvBool("SCHEDULER_ENABLED", true),
+			SyncInterval: time.Duration(getEnvInt("SCHEDULER_SYNC_INTERVAL", 60)) * time.Second,
+			LeaderTTL:    time.Duration(getEnvInt("SCHEDULER_LEADER_TTL", 15)) * time.Second,
+		},
 	}
 
 	return cfg, nil
@@ -90,6 +196,15 @@ func getEnvInt(key string, fallback int) int {
 	return fallback
 }
 
+func getEnvBool(key string, fallback bool) bool {
+	if val := os.Getenv(key); val != "" {
+		if parsed, err := strconv.ParseBool(val); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
 func parseBrokers(brokersStr string) []string {
 	if brokersStr == "" {
 		return []string{"localhost:19092"}
@@ -100,3 +215,12 @@ func parseBrokers(brokersStr string) []string {
 	}
 	return brokers
 }
+
+// hostname names this replica's Redis Streams consumer when STREAM_REDIS_CONSUMER is not set.
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "notiair"
+	}
+	return name
+}
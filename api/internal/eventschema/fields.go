@@ -0,0 +1,132 @@
+package eventschema
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// maxFieldDepth stops recursive schemas from expanding forever.
+const maxFieldDepth = 8
+
+// Field is a placeholder path declared by a schema, e.g. "context.user.email".
+type Field struct {
+	Path        string `json:"path"`
+	Type        string `json:"type,omitempty"`
+	Required    bool   `json:"required"`
+	Description string `json:"description,omitempty"`
+}
+
+// Fields lists the object properties a schema declares, as dotted paths in the form
+// templates use. Properties from allOf branches and local $refs ("#/$defs/...") are
+// included; arrays are listed but not descended into, since templates cannot index them.
+func Fields(schema []byte) []Field {
+	var root map[string]any
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return []Field{}
+	}
+	fields := map[string]Field{}
+	collectFields(root, root, "", true, 0, fields)
+
+	result := make([]Field, 0, len(fields))
+	for _, field := range fields {
+		result = append(result, field)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result
+}
+
+func collectFields(root, node map[string]any, prefix string, required bool, depth int, fields map[string]Field) {
+	if depth > maxFieldDepth {
+		return
+	}
+	node = resolveRef(root, node)
+
+	requiredSet := map[string]bool{}
+	if list, ok := node["required"].([]any); ok {
+		for _, name := range list {
+			if s, ok := name.(string); ok {
+				requiredSet[s] = true
+			}
+		}
+	}
+
+	if properties, ok := node["properties"].(map[string]any); ok {
+		for name, raw := range properties {
+			property, ok := raw.(map[string]any)
+			if !ok {
+				continue
+			}
+			property = resolveRef(root, property)
+			path := name
+			if prefix != "" {
+				path = prefix + "." + name
+			}
+
+			field := fields[path]
+			field.Path = path
+			field.Required = field.Required || (required && requiredSet[name])
+			if t := schemaType(property); t != "" {
+				field.Type = t
+			}
+			if d, ok := property["description"].(string); ok && d != "" {
+				field.Description = d
+			}
+			fields[path] = field
+
+			collectFields(root, property, path, field.Required, depth+1, fields)
+		}
+	}
+
+	if branches, ok := node["allOf"].([]any); ok {
+		for _, raw := range branches {
+			if branch, ok := raw.(map[string]any); ok {
+				collectFields(root, branch, prefix, required, depth+1, fields)
+			}
+		}
+	}
+}
+
+// resolveRef follows local references; anything else is returned as is.
+func resolveRef(root, node map[string]any) map[string]any {
+	for i := 0; i < maxFieldDepth; i++ {
+		ref, ok := node["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return node
+		}
+		var current any = root
+		for _, token := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			m, ok := current.(map[string]any)
+			if !ok {
+				return node
+			}
+			current = m[token]
+		}
+		target, ok := current.(map[string]any)
+		if !ok {
+			return node
+		}
+		node = target
+	}
+	return node
+}
+
+func schemaType(node map[string]any) string {
+	switch t := node["type"].(type) {
+	case string:
+		return t
+	case []any:
+		var names []string
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				names = append(names, s)
+			}
+		}
+		return strings.Join(names, "|")
+	}
+	if _, ok := node["properties"]; ok {
+		return "object"
+	}
+	return ""
+}
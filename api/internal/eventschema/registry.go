@@ -0,0 +1,306 @@
+package eventschema
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"gorm.io/datatypes"
+
+	persist "notiair/internal/persistence/eventschema"
+)
+
+// cacheMaxAge bounds how long a replica keeps using schemas changed on another replica.
+const cacheMaxAge = time.Minute
+
+var printer = message.NewPrinter(language.English)
+
+var (
+	ErrInvalidSchema = errors.New("invalid json schema")
+	ErrNotFound      = persist.ErrNotFound
+)
+
+// Definition is a registered schema together with the placeholder paths it declares.
+type Definition struct {
+	EventType   string          `json:"eventType"`
+	Description string          `json:"description"`
+	Schema      json.RawMessage `json:"schema"`
+	Fields      []Field         `json:"fields"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+// Rejection is a stream event that failed validation.
+type Rejection struct {
+	ID         string         `json:"id"`
+	EventID    string         `json:"eventId"`
+	EventType  string         `json:"eventType"`
+	Topic      string         `json:"topic"`
+	Violations []string       `json:"violations"`
+	Payload    map[string]any `json:"payload"`
+	RejectedAt time.Time      `json:"rejectedAt"`
+}
+
+// Registry validates event payloads against the JSON Schema registered for their
+// event_type. Compiled schemas are cached and reloaded after a local change or once
+// cacheMaxAge has passed.
+type Registry struct {
+	repo persist.Repository
+
+	mu       sync.Mutex
+	compiled map[string]*jsonschema.Schema
+	loadedAt time.Time
+	dirty    atomic.Bool
+}
+
+func NewRegistry(repo persist.Repository) *Registry {
+	r := &Registry{repo: repo}
+	r.dirty.Store(true)
+	return r
+}
+
+// Invalidate drops the cached schemas; the next validation reloads them.
+func (r *Registry) Invalidate() {
+	r.dirty.Store(true)
+}
+
+func (r *Registry) List(ctx context.Context) ([]Definition, error) {
+	schemas, err := r.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	definitions := make([]Definition, 0, len(schemas))
+	for _, schema := range schemas {
+		definitions = append(definitions, definitionOf(schema))
+	}
+	return definitions, nil
+}
+
+func (r *Registry) Get(ctx context.Context, eventType string) (Definition, error) {
+	schema, err := r.repo.Find(ctx, eventType)
+	if err != nil {
+		return Definition{}, err
+	}
+	return definitionOf(schema), nil
+}
+
+// Save compiles the schema and registers it for the event_type, replacing any previous one.
+func (r *Registry) Save(ctx context.Context, eventType, description string, schema json.RawMessage) (Definition, error) {
+	if eventType == "" {
+		return Definition{}, errors.New("event type is required")
+	}
+	if _, err := Compile(schema); err != nil {
+		return Definition{}, err
+	}
+	saved, err := r.repo.Save(ctx, persist.Schema{
+		EventType:   eventType,
+		Schema:      datatypes.JSON(schema),
+		Description: description,
+	})
+	if err != nil {
+		return Definition{}, err
+	}
+	r.Invalidate()
+	return definitionOf(saved), nil
+}
+
+func (r *Registry) Delete(ctx context.Context, eventType string) error {
+	if err := r.repo.Delete(ctx, eventType); err != nil {
+		return err
+	}
+	r.Invalidate()
+	return nil
+}
+
+// Validate checks the payload of an event against the schema of its event_type and
+// returns the violations. Event types without a schema are always valid.
+func (r *Registry) Validate(ctx context.Context, eventType string, payload map[string]any) ([]string, error) {
+	compiled, err := r.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	schema := compiled[eventType]
+	if schema == nil {
+		return nil, nil
+	}
+
+	// The validator only understands the types encoding/json produces.
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encode payload: %w", err)
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("decode payload: %w", err)
+	}
+
+	err = schema.Validate(instance)
+	var verr *jsonschema.ValidationError
+	if errors.As(err, &verr) {
+		return violations(verr), nil
+	}
+	return nil, err
+}
+
+// Reject records an event that failed validation.
+func (r *Registry) Reject(ctx context.Context, topic string, payload map[string]any, found []string) error {
+	encoded, err := json.Marshal(found)
+	if err != nil {
+		return err
+	}
+	eventID, _ := payload["event_id"].(string)
+	eventType, _ := payload["event_type"].(string)
+	return r.repo.Reject(ctx, persist.Rejection{
+		EventID:    eventID,
+		EventType:  eventType,
+		Topic:      topic,
+		Violations: datatypes.JSON(encoded),
+		Payload:    datatypes.JSONMap(payload),
+	})
+}
+
+func (r *Registry) Rejections(ctx context.Context, eventType string, limit int) ([]Rejection, error) {
+	rows, err := r.repo.ListRejections(ctx, eventType, limit)
+	if err != nil {
+		return nil, err
+	}
+	rejections := make([]Rejection, 0, len(rows))
+	for _, row := range rows {
+		var found []string
+		if len(row.Violations) > 0 {
+			if err := json.Unmarshal(row.Violations, &found); err != nil {
+				return nil, fmt.Errorf("decode violations of rejection %s: %w", row.ID, err)
+			}
+		}
+		rejections = append(rejections, Rejection{
+			ID:         row.ID,
+			EventID:    row.EventID,
+			EventType:  row.EventType,
+			Topic:      row.Topic,
+			Violations: found,
+			Payload:    row.Payload,
+			RejectedAt: row.RejectedAt,
+		})
+	}
+	return rejections, nil
+}
+
+func (r *Registry) current(ctx context.Context) (map[string]*jsonschema.Schema, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stale := r.dirty.Swap(false) || time.Since(r.loadedAt) > cacheMaxAge
+	if r.compiled != nil && !stale {
+		return r.compiled, nil
+	}
+
+	schemas, err := r.repo.List(ctx)
+	if err != nil {
+		r.dirty.Store(true)
+		if r.compiled != nil {
+			log.Printf("failed to reload event schemas, using previous ones: %v", err)
+			return r.compiled, nil
+		}
+		return nil, err
+	}
+
+	compiled := make(map[string]*jsonschema.Schema, len(schemas))
+	for _, schema := range schemas {
+		sch, err := Compile(schema.Schema)
+		if err != nil {
+			// Saved schemas are compiled on Save; this only happens after a manual edit.
+			log.Printf("skipping event schema %s: %v", schema.EventType, err)
+			continue
+		}
+		compiled[schema.EventType] = sch
+	}
+	r.compiled = compiled
+	r.loadedAt = time.Now()
+	return compiled, nil
+}
+
+// Compile parses and compiles a JSON Schema document. Only the document itself is
+// available to $ref: remote references are not fetched.
+func Compile(schema []byte) (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.UseLoader(noLoader{})
+	const url = "mem:///event.json"
+	if err := compiler.AddResource(url, doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	compiled, err := compiler.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	return compiled, nil
+}
+
+type noLoader struct{}
+
+func (noLoader) Load(url string) (any, error) {
+	return nil, fmt.Errorf("loading %s is not allowed", url)
+}
+
+// violations flattens a validation error into "instance location: message" lines.
+func violations(verr *jsonschema.ValidationError) []string {
+	var found []string
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			location := "/"
+			if len(e.InstanceLocation) > 0 {
+				location = "/" + joinPointer(e.InstanceLocation)
+			}
+			found = append(found, fmt.Sprintf("%s: %s", location, describe(e)))
+			return
+		}
+		for _, cause := range e.Causes {
+			walk(cause)
+		}
+	}
+	walk(verr)
+	sort.Strings(found)
+	return found
+}
+
+func describe(e *jsonschema.ValidationError) string {
+	if _, ok := e.ErrorKind.(*kind.Schema); ok {
+		return "does not match schema"
+	}
+	return e.ErrorKind.LocalizedString(printer)
+}
+
+func joinPointer(tokens []string) string {
+	var buf bytes.Buffer
+	for i, token := range tokens {
+		if i > 0 {
+			buf.WriteByte('/')
+		}
+		buf.WriteString(token)
+	}
+	return buf.String()
+}
+
+func definitionOf(schema persist.Schema) Definition {
+	return Definition{
+		EventType:   schema.EventType,
+		Description: schema.Description,
+		Schema:      json.RawMessage(schema.Schema),
+		Fields:      Fields(schema.Schema),
+		UpdatedAt:   schema.UpdatedAt,
+	}
+}
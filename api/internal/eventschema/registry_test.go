@@ -0,0 +1,170 @@
+package eventschema
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	persist "notiair/internal/persistence/eventschema"
+)
+
+type fakeRepo struct {
+	schemas    map[string]persist.Schema
+	rejections []persist.Rejection
+	lists      int
+}
+
+func (f *fakeRepo) List(context.Context) ([]persist.Schema, error) {
+	f.lists++
+	var schemas []persist.Schema
+	for _, schema := range f.schemas {
+		schemas = append(schemas, schema)
+	}
+	return schemas, nil
+}
+
+func (f *fakeRepo) Find(_ context.Context, eventType string) (persist.Schema, error) {
+	schema, ok := f.schemas[eventType]
+	if !ok {
+		return persist.Schema{}, persist.ErrNotFound
+	}
+	return schema, nil
+}
+
+func (f *fakeRepo) Save(_ context.Context, schema persist.Schema) (persist.Schema, error) {
+	if f.schemas == nil {
+		f.schemas = map[string]persist.Schema{}
+	}
+	f.schemas[schema.EventType] = schema
+	return schema, nil
+}
+
+func (f *fakeRepo) Delete(_ context.Context, eventType string) error {
+	delete(f.schemas, eventType)
+	return nil
+}
+
+func (f *fakeRepo) Reject(_ context.Context, rejection persist.Rejection) error {
+	f.rejections = append(f.rejections, rejection)
+	return nil
+}
+
+func (f *fakeRepo) ListRejections(context.Context, string, int) ([]persist.Rejection, error) {
+	return f.rejections, nil
+}
+
+func (f *fakeRepo) DeleteRejectionsOlderThan(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+const userCreatedSchema = `{
+	"type": "object",
+	"required": ["context"],
+	"properties": {
+		"context": {
+			"type": "object",
+			"required": ["user"],
+			"properties": {
+				"user": {"$ref": "#/$defs/user"}
+			}
+		},
+		"metadata": {"type": "object"}
+	},
+	"$defs": {
+		"user": {
+			"type": "object",
+			"required": ["email"],
+			"properties": {
+				"email": {"type": "string", "format": "email", "description": "Recipient address"},
+				"age": {"type": ["integer", "null"], "minimum": 0}
+			}
+		}
+	}
+}`
+
+func TestValidateReportsViolations(t *testing.T) {
+	registry := NewRegistry(&fakeRepo{})
+	ctx := context.Background()
+	if _, err := registry.Save(ctx, "user.created", "", json.RawMessage(userCreatedSchema)); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	found, err := registry.Validate(ctx, "user.created", map[string]any{
+		"event_type": "user.created",
+		"context":    map[string]any{"user": map[string]any{"email": "a@b.c", "age": 30}},
+	})
+	if err != nil || len(found) != 0 {
+		t.Fatalf("valid event rejected: %v %v", found, err)
+	}
+
+	found, err = registry.Validate(ctx, "user.created", map[string]any{
+		"context": map[string]any{"user": map[string]any{"age": -1}},
+	})
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	want := []string{
+		"/context/user/age: minimum: got -1, want 0",
+		"/context/user: missing property 'email'",
+	}
+	if !reflect.DeepEqual(found, want) {
+		t.Fatalf("violations %q, want %q", found, want)
+	}
+
+	found, err = registry.Validate(ctx, "order.paid", map[string]any{})
+	if err != nil || found != nil {
+		t.Fatalf("event type without schema must pass, got %v %v", found, err)
+	}
+}
+
+func TestSaveRejectsInvalidSchema(t *testing.T) {
+	registry := NewRegistry(&fakeRepo{})
+	_, err := registry.Save(context.Background(), "user.created", "", json.RawMessage(`{"type": 42}`))
+	if !errors.Is(err, ErrInvalidSchema) {
+		t.Fatalf("expected ErrInvalidSchema, got %v", err)
+	}
+	_, err = registry.Save(context.Background(), "user.created", "", json.RawMessage(`{"$ref": "https://example.com/schema.json"}`))
+	if !errors.Is(err, ErrInvalidSchema) {
+		t.Fatalf("remote $ref must not be loaded, got %v", err)
+	}
+}
+
+func TestSchemasAreCachedUntilInvalidated(t *testing.T) {
+	repo := &fakeRepo{}
+	registry := NewRegistry(repo)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := registry.Validate(ctx, "user.created", map[string]any{}); err != nil {
+			t.Fatalf("validate: %v", err)
+		}
+	}
+	if repo.lists != 1 {
+		t.Fatalf("expected schemas to be loaded once, got %d", repo.lists)
+	}
+
+	// A schema saved through another replica shows up after Invalidate
+	repo.schemas = map[string]persist.Schema{"user.created": {EventType: "user.created", Schema: []byte(userCreatedSchema)}}
+	registry.Invalidate()
+	found, err := registry.Validate(ctx, "user.created", map[string]any{})
+	if err != nil || len(found) == 0 {
+		t.Fatalf("expected violations after reload, got %v %v", found, err)
+	}
+}
+
+func TestFieldsListsPlaceholderPaths(t *testing.T) {
+	fields := Fields([]byte(userCreatedSchema))
+	want := []Field{
+		{Path: "context", Type: "object", Required: true},
+		{Path: "context.user", Type: "object", Required: true},
+		{Path: "context.user.age", Type: "integer|null"},
+		{Path: "context.user.email", Type: "string", Required: true, Description: "Recipient address"},
+		{Path: "metadata", Type: "object"},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Fatalf("fields %+v, want %+v", fields, want)
+	}
+}
@@ -36,6 +36,8 @@ type UpdateInput struct {
 
 type Repository interface {
 	ListByConnector(ctx context.Context, connectorID string) ([]Channel, error)
+	FindByID(ctx context.Context, id string) (Channel, error)
+	FindByName(ctx context.Context, name string) ([]Channel, error)
 	Create(ctx context.Context, input CreateInput) (Channel, error)
 	Update(ctx context.Context, id string, input UpdateInput) (Channel, error)
 	Delete(ctx context.Context, id string) error
@@ -60,6 +62,26 @@ func (r *repository) ListByConnector(ctx context.Context, connectorID string) ([
 	return channels, nil
 }
 
+func (r *repository) FindByID(ctx context.Context, id string) (Channel, error) {
+	var channel Channel
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&channel).Error; err != nil {
+		return Channel{}, err
+	}
+	return channel, nil
+}
+
+// FindByName returns every channel with the given name across all connectors.
+func (r *repository) FindByName(ctx context.Context, name string) ([]Channel, error) {
+	var channels []Channel
+	if err := r.db.WithContext(ctx).
+		Where("name = ?", name).
+		Order("created_at ASC").
+		Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
 func (r *repository) Create(ctx context.Context, input CreateInput) (Channel, error) {
 	channel := Channel{
 		ID:          uuid.NewString(),
@@ -0,0 +1,113 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Claim statuses. A claim is pending while its dispatch runs and done once the dispatch
+// has been committed.
+const (
+	StatusPending = "pending"
+	StatusDone    = "done"
+)
+
+// PendingTimeout is how long a pending claim blocks other claims of the same pair. A
+// pending claim older than that was left by a process that died mid-dispatch and is
+// taken over, so the event is delivered rather than skipped.
+const PendingTimeout = time.Minute
+
+// ErrInProgress is returned by Claim while another dispatch of the pair is pending. The
+// pair belongs to that dispatch: callers skip it instead of retrying.
+var ErrInProgress = errors.New("event is being dispatched")
+
+// ProcessedEvent records that a stream event has started a workflow. The composite
+// primary key is the unique constraint that makes dispatch idempotent.
+type ProcessedEvent struct {
+	EventID    string `gorm:"primaryKey"`
+	WorkflowID string `gorm:"primaryKey"`
+	Status     string `gorm:"type:text;not null;default:done"`
+	// ProcessedAt is when the pair was claimed or, once done, completed.
+	ProcessedAt time.Time `gorm:"autoCreateTime;index"`
+}
+
+type Repository interface {
+	// Claim records the (event, workflow) pair as pending and reports false if it is
+	// already done. It returns ErrInProgress while another claim of the pair is pending.
+	Claim(ctx context.Context, eventID, workflowID string) (bool, error)
+	// Complete marks a claim done after its dispatch has been committed.
+	Complete(ctx context.Context, eventID, workflowID string) error
+	// Release forgets a claim whose dispatch failed so that a retry can claim it again.
+	Release(ctx context.Context, eventID, workflowID string) error
+	DeleteOlderThan(ctx context.Context, before time.Time) (int64, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Claim(ctx context.Context, eventID, workflowID string) (bool, error) {
+	now := time.Now()
+	res := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&ProcessedEvent{EventID: eventID, WorkflowID: workflowID, Status: StatusPending, ProcessedAt: now})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return true, nil
+	}
+
+	// Take over a pending claim abandoned by a process that died before Complete
+	res = r.db.WithContext(ctx).
+		Model(&ProcessedEvent{}).
+		Where("event_id = ? AND workflow_id = ? AND status = ? AND processed_at < ?", eventID, workflowID, StatusPending, now.Add(-PendingTimeout)).
+		UpdateColumn("processed_at", now)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return true, nil
+	}
+
+	var existing ProcessedEvent
+	err := r.db.WithContext(ctx).
+		Where("event_id = ? AND workflow_id = ?", eventID, workflowID).
+		Limit(1).
+		Find(&existing).Error
+	if err != nil {
+		return false, err
+	}
+	if existing.Status == StatusDone {
+		return false, nil
+	}
+	// Pending in another dispatch, or released just now
+	return false, ErrInProgress
+}
+
+func (r *repository) Complete(ctx context.Context, eventID, workflowID string) error {
+	return r.db.WithContext(ctx).
+		Model(&ProcessedEvent{}).
+		Where("event_id = ? AND workflow_id = ?", eventID, workflowID).
+		UpdateColumns(map[string]interface{}{"status": StatusDone, "processed_at": time.Now()}).Error
+}
+
+func (r *repository) Release(ctx context.Context, eventID, workflowID string) error {
+	return r.db.WithContext(ctx).
+		Where("event_id = ? AND workflow_id = ?", eventID, workflowID).
+		Delete(&ProcessedEvent{}).Error
+}
+
+// DeleteOlderThan removes claims recorded before the cutoff.
+func (r *repository) DeleteOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Where("processed_at < ?", before).Delete(&ProcessedEvent{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,79 @@
+package dedup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&ProcessedEvent{}))
+	return db
+}
+
+func TestClaimIsGrantedOncePerEventAndWorkflow(t *testing.T) {
+	repo := NewRepository(setupTestDB(t))
+	ctx := context.Background()
+
+	claimed, err := repo.Claim(ctx, "evt-1", "wf-1")
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	_, err = repo.Claim(ctx, "evt-1", "wf-1")
+	require.ErrorIs(t, err, ErrInProgress)
+
+	require.NoError(t, repo.Complete(ctx, "evt-1", "wf-1"))
+	claimed, err = repo.Claim(ctx, "evt-1", "wf-1")
+	require.NoError(t, err)
+	require.False(t, claimed)
+
+	claimed, err = repo.Claim(ctx, "evt-1", "wf-2")
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	require.NoError(t, repo.Release(ctx, "evt-1", "wf-1"))
+	claimed, err = repo.Claim(ctx, "evt-1", "wf-1")
+	require.NoError(t, err)
+	require.True(t, claimed)
+}
+
+func TestClaimTakesOverAbandonedPendingClaim(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	// The process died after claiming and before its dispatch committed
+	require.NoError(t, db.Create(&ProcessedEvent{EventID: "evt-1", WorkflowID: "wf-1", Status: StatusPending, ProcessedAt: time.Now().Add(-2 * PendingTimeout)}).Error)
+
+	claimed, err := repo.Claim(ctx, "evt-1", "wf-1")
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	_, err = repo.Claim(ctx, "evt-1", "wf-1")
+	require.ErrorIs(t, err, ErrInProgress)
+}
+
+func TestDeleteOlderThan(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	require.NoError(t, db.Create(&ProcessedEvent{EventID: "old", WorkflowID: "wf-1", ProcessedAt: time.Now().Add(-48 * time.Hour)}).Error)
+	_, err := repo.Claim(ctx, "new", "wf-1")
+	require.NoError(t, err)
+
+	deleted, err := repo.DeleteOlderThan(ctx, time.Now().Add(-24*time.Hour))
+	require.NoError(t, err)
+	require.EqualValues(t, 1, deleted)
+
+	claimed, err := repo.Claim(ctx, "old", "wf-1")
+	require.NoError(t, err)
+	require.True(t, claimed)
+}
@@ -0,0 +1,125 @@
+package eventschema
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrNotFound = errors.New("event schema not found")
+
+// Schema is the JSON Schema registered for one event_type.
+type Schema struct {
+	EventType   string         `gorm:"primaryKey"`
+	Schema      datatypes.JSON `gorm:"type:jsonb;not null"`
+	Description string         `gorm:"type:text"`
+	CreatedAt   time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time      `gorm:"autoUpdateTime"`
+}
+
+func (Schema) TableName() string {
+	return "event_schemas"
+}
+
+// Rejection records a stream event that did not match the schema of its event_type.
+type Rejection struct {
+	ID         string            `gorm:"primaryKey"`
+	EventID    string            `gorm:"type:text"`
+	EventType  string            `gorm:"type:text;index"`
+	Topic      string            `gorm:"type:text"`
+	Violations datatypes.JSON    `gorm:"type:jsonb"`
+	Payload    datatypes.JSONMap `gorm:"type:jsonb"`
+	RejectedAt time.Time         `gorm:"autoCreateTime;index"`
+}
+
+func (Rejection) TableName() string {
+	return "event_rejections"
+}
+
+type Repository interface {
+	List(ctx context.Context) ([]Schema, error)
+	Find(ctx context.Context, eventType string) (Schema, error)
+	// Save creates or replaces the schema of an event_type.
+	Save(ctx context.Context, schema Schema) (Schema, error)
+	Delete(ctx context.Context, eventType string) error
+
+	Reject(ctx context.Context, rejection Rejection) error
+	// ListRejections returns the latest rejections, optionally for one event_type.
+	ListRejections(ctx context.Context, eventType string, limit int) ([]Rejection, error)
+	DeleteRejectionsOlderThan(ctx context.Context, before time.Time) (int64, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) List(ctx context.Context) ([]Schema, error) {
+	var schemas []Schema
+	err := r.db.WithContext(ctx).Order("event_type").Find(&schemas).Error
+	return schemas, err
+}
+
+func (r *repository) Find(ctx context.Context, eventType string) (Schema, error) {
+	var schema Schema
+	err := r.db.WithContext(ctx).First(&schema, "event_type = ?", eventType).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Schema{}, ErrNotFound
+	}
+	return schema, err
+}
+
+func (r *repository) Save(ctx context.Context, schema Schema) (Schema, error) {
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "event_type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"schema", "description", "updated_at"}),
+		}).
+		Create(&schema).Error
+	if err != nil {
+		return Schema{}, err
+	}
+	return r.Find(ctx, schema.EventType)
+}
+
+func (r *repository) Delete(ctx context.Context, eventType string) error {
+	res := r.db.WithContext(ctx).Delete(&Schema{}, "event_type = ?", eventType)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *repository) Reject(ctx context.Context, rejection Rejection) error {
+	if rejection.ID == "" {
+		rejection.ID = uuid.NewString()
+	}
+	return r.db.WithContext(ctx).Create(&rejection).Error
+}
+
+func (r *repository) ListRejections(ctx context.Context, eventType string, limit int) ([]Rejection, error) {
+	query := r.db.WithContext(ctx).Order("rejected_at DESC").Limit(limit)
+	if eventType != "" {
+		query = query.Where("event_type = ?", eventType)
+	}
+	var rejections []Rejection
+	err := query.Find(&rejections).Error
+	return rejections, err
+}
+
+// DeleteRejectionsOlderThan removes rejections recorded before the cutoff.
+func (r *repository) DeleteRejectionsOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Where("rejected_at < ?", before).Delete(&Rejection{})
+	return res.RowsAffected, res.Error
+}
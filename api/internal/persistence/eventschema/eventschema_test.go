@@ -0,0 +1,62 @@
+package eventschema
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&Schema{}, &Rejection{}))
+	return db
+}
+
+func TestSaveReplacesSchemaOfEventType(t *testing.T) {
+	repo := NewRepository(setupTestDB(t))
+	ctx := context.Background()
+
+	_, err := repo.Save(ctx, Schema{EventType: "user.created", Schema: datatypes.JSON(`{"type":"object"}`)})
+	require.NoError(t, err)
+	saved, err := repo.Save(ctx, Schema{EventType: "user.created", Schema: datatypes.JSON(`{"required":["context"]}`), Description: "v2"})
+	require.NoError(t, err)
+	require.Equal(t, "v2", saved.Description)
+	require.JSONEq(t, `{"required":["context"]}`, string(saved.Schema))
+
+	schemas, err := repo.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, schemas, 1)
+
+	require.NoError(t, repo.Delete(ctx, "user.created"))
+	_, err = repo.Find(ctx, "user.created")
+	require.ErrorIs(t, err, ErrNotFound)
+	require.ErrorIs(t, repo.Delete(ctx, "user.created"), ErrNotFound)
+}
+
+func TestListRejectionsFiltersByEventType(t *testing.T) {
+	repo := NewRepository(setupTestDB(t))
+	ctx := context.Background()
+
+	require.NoError(t, repo.Reject(ctx, Rejection{EventID: "evt-1", EventType: "user.created", Violations: datatypes.JSON(`["missing context"]`)}))
+	require.NoError(t, repo.Reject(ctx, Rejection{EventID: "evt-2", EventType: "order.paid"}))
+
+	all, err := repo.ListRejections(ctx, "", 10)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+
+	users, err := repo.ListRejections(ctx, "user.created", 10)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	require.Equal(t, "evt-1", users[0].EventID)
+
+	deleted, err := repo.DeleteRejectionsOlderThan(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.EqualValues(t, 2, deleted)
+}
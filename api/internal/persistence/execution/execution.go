@@ -0,0 +1,236 @@
+package execution
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned by FindByID when the execution does not exist.
+var ErrNotFound = errors.New("execution not found")
+
+type Status string
+
+const (
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+type Execution struct {
+	ID            string            `gorm:"primaryKey"`
+	WorkflowID    string            `gorm:"index;not null"`
+	VersionID     string            `gorm:"type:text"`
+	VersionNumber int               `gorm:"not null;default:0"`
+	TriggerSource string            `gorm:"type:text;not null"`
+	Input         datatypes.JSONMap `gorm:"type:jsonb"`
+	Status        Status            `gorm:"type:text;not null"`
+	Error         string            `gorm:"type:text"`
+	TaskCount     int               `gorm:"not null;default:0"`
+	StartedAt     time.Time         `gorm:"not null"`
+	DurationMs    float64           `gorm:"not null;default:0"`
+	CreatedAt     time.Time         `gorm:"autoCreateTime;index"`
+	Steps         []Step            `gorm:"foreignKey:ExecutionID"`
+}
+
+func (Execution) TableName() string {
+	return "workflow_executions"
+}
+
+type Step struct {
+	ID          string            `gorm:"primaryKey"`
+	ExecutionID string            `gorm:"index;not null"`
+	Seq         int               `gorm:"not null"`
+	NodeID      string            `gorm:"type:text"`
+	Variant     string            `gorm:"type:text"`
+	Input       datatypes.JSONMap `gorm:"type:jsonb"`
+	Output      datatypes.JSONMap `gorm:"type:jsonb"`
+	Decision    string            `gorm:"type:text"`
+	Error       string            `gorm:"type:text"`
+	StartedAt   time.Time         `gorm:"not null"`
+	DurationMs  float64           `gorm:"not null;default:0"`
+}
+
+func (Step) TableName() string {
+	return "workflow_execution_steps"
+}
+
+type CreateInput struct {
+	ID            string
+	WorkflowID    string
+	VersionID     string
+	VersionNumber int
+	TriggerSource string
+	Input         map[string]any
+	Status        Status
+	Error         string
+	TaskCount     int
+	StartedAt     time.Time
+	Duration      time.Duration
+	Steps         []StepInput
+}
+
+type StepInput struct {
+	NodeID    string
+	Variant   string
+	Input     map[string]any
+	Output    map[string]any
+	Decision  string
+	Error     string
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+type ListFilter struct {
+	WorkflowID string
+	Limit      int
+	Offset     int
+}
+
+type Repository interface {
+	Create(ctx context.Context, input CreateInput) (Execution, error)
+	ListByWorkflow(ctx context.Context, filter ListFilter) ([]Execution, error)
+	CountByWorkflow(ctx context.Context, workflowID string) (int, error)
+	FindByID(ctx context.Context, id string) (Execution, error)
+	DeleteOlderThan(ctx context.Context, before time.Time) (int64, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func toJSONMap(m map[string]any) datatypes.JSONMap {
+	out := datatypes.JSONMap{}
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func (r *repository) Create(ctx context.Context, input CreateInput) (Execution, error) {
+	id := input.ID
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	exec := Execution{
+		ID:            id,
+		WorkflowID:    input.WorkflowID,
+		VersionID:     input.VersionID,
+		VersionNumber: input.VersionNumber,
+		TriggerSource: input.TriggerSource,
+		Input:         toJSONMap(input.Input),
+		Status:        input.Status,
+		Error:         input.Error,
+		TaskCount:     input.TaskCount,
+		StartedAt:     input.StartedAt,
+		DurationMs:    durationMs(input.Duration),
+	}
+
+	steps := make([]Step, len(input.Steps))
+	for i, s := range input.Steps {
+		steps[i] = Step{
+			ID:          uuid.NewString(),
+			ExecutionID: id,
+			Seq:         i + 1,
+			NodeID:      s.NodeID,
+			Variant:     s.Variant,
+			Input:       toJSONMap(s.Input),
+			Output:      toJSONMap(s.Output),
+			Decision:    s.Decision,
+			Error:       s.Error,
+			StartedAt:   s.StartedAt,
+			DurationMs:  durationMs(s.Duration),
+		}
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Steps").Create(&exec).Error; err != nil {
+			return err
+		}
+		if len(steps) == 0 {
+			return nil
+		}
+		return tx.Create(&steps).Error
+	})
+	if err != nil {
+		return Execution{}, err
+	}
+
+	exec.Steps = steps
+	return exec, nil
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func normalizeLimit(limit int) int {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return limit
+}
+
+func (r *repository) ListByWorkflow(ctx context.Context, filter ListFilter) ([]Execution, error) {
+	var executions []Execution
+	if err := r.db.WithContext(ctx).
+		Where("workflow_id = ?", filter.WorkflowID).
+		Order("started_at DESC").
+		Limit(normalizeLimit(filter.Limit)).
+		Offset(filter.Offset).
+		Find(&executions).Error; err != nil {
+		return nil, err
+	}
+	return executions, nil
+}
+
+func (r *repository) CountByWorkflow(ctx context.Context, workflowID string) (int, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&Execution{}).
+		Where("workflow_id = ?", workflowID).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+func (r *repository) FindByID(ctx context.Context, id string) (Execution, error) {
+	var exec Execution
+	err := r.db.WithContext(ctx).
+		Preload("Steps", func(db *gorm.DB) *gorm.DB {
+			return db.Order("seq ASC")
+		}).
+		Where("id = ?", id).
+		First(&exec).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Execution{}, ErrNotFound
+	}
+	return exec, err
+}
+
+// DeleteOlderThan removes executions started before the cutoff together with their steps.
+func (r *repository) DeleteOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		expired := tx.Model(&Execution{}).Select("id").Where("started_at < ?", before)
+		if err := tx.Where("execution_id IN (?)", expired).Delete(&Step{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("started_at < ?", before).Delete(&Execution{})
+		deleted = res.RowsAffected
+		return res.Error
+	})
+	return deleted, err
+}
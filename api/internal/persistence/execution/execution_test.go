@@ -0,0 +1,102 @@
+package execution
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&Execution{}, &Step{}))
+	return db
+}
+
+func TestCreateAndFindWithSteps(t *testing.T) {
+	repo := NewRepository(setupTestDB(t))
+	ctx := context.Background()
+
+	created, err := repo.Create(ctx, CreateInput{
+		WorkflowID:    "wf-1",
+		VersionNumber: 3,
+		TriggerSource: "manual",
+		Input:         map[string]any{"name": "Ann"},
+		Status:        StatusSucceeded,
+		TaskCount:     1,
+		StartedAt:     time.Now(),
+		Duration:      1500 * time.Microsecond,
+		Steps: []StepInput{
+			{NodeID: "tr", Variant: "trigger", Decision: "started", StartedAt: time.Now()},
+			{NodeID: "ch", Variant: "channel", Decision: "notify channel c1", StartedAt: time.Now()},
+		},
+	})
+	require.NoError(t, err)
+
+	found, err := repo.FindByID(ctx, created.ID)
+	require.NoError(t, err)
+	require.Equal(t, 3, found.VersionNumber)
+	require.Equal(t, 1.5, found.DurationMs)
+	require.Len(t, found.Steps, 2)
+	require.Equal(t, "tr", found.Steps[0].NodeID)
+	require.Equal(t, "ch", found.Steps[1].NodeID)
+
+	list, err := repo.ListByWorkflow(ctx, ListFilter{WorkflowID: "wf-1"})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+}
+
+func TestFindByIDReturnsErrNotFound(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	_, err := repo.FindByID(ctx, "missing")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// Other database errors are not reported as a missing execution
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+	_, err = repo.FindByID(ctx, "missing")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrNotFound)
+}
+
+func TestDeleteOlderThanRemovesSteps(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	old, err := repo.Create(ctx, CreateInput{
+		WorkflowID: "wf-1",
+		Status:     StatusSucceeded,
+		StartedAt:  time.Now().Add(-48 * time.Hour),
+		Steps:      []StepInput{{NodeID: "tr", StartedAt: time.Now()}},
+	})
+	require.NoError(t, err)
+	_, err = repo.Create(ctx, CreateInput{
+		WorkflowID: "wf-1",
+		Status:     StatusFailed,
+		StartedAt:  time.Now(),
+		Steps:      []StepInput{{NodeID: "tr", StartedAt: time.Now()}},
+	})
+	require.NoError(t, err)
+
+	deleted, err := repo.DeleteOlderThan(ctx, time.Now().Add(-24*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	count, err := repo.CountByWorkflow(ctx, "wf-1")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	var steps int64
+	require.NoError(t, db.Model(&Step{}).Where("execution_id = ?", old.ID).Count(&steps).Error)
+	require.Equal(t, int64(0), steps)
+}
@@ -0,0 +1,53 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Applied marks a data migration that has completed, so it is not repeated on the next start.
+type Applied struct {
+	Name      string    `gorm:"primaryKey"`
+	AppliedAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (Applied) TableName() string {
+	return "data_migrations"
+}
+
+type Repository interface {
+	// RunOnce calls migrate unless name is already marked as applied and marks it after
+	// migrate succeeds. It reports whether migrate ran. Replicas starting together may
+	// both run migrate, so it must be idempotent.
+	RunOnce(ctx context.Context, name string, migrate func(ctx context.Context) error) (bool, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) RunOnce(ctx context.Context, name string, migrate func(ctx context.Context) error) (bool, error) {
+	var applied Applied
+	err := r.db.WithContext(ctx).Where("name = ?", name).First(&applied).Error
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+
+	if err := migrate(ctx); err != nil {
+		return true, err
+	}
+	return true, r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&Applied{Name: name}).Error
+}
@@ -0,0 +1,51 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&Applied{}))
+	return db
+}
+
+func TestRunOnceSkipsAppliedMigration(t *testing.T) {
+	repo := NewRepository(setupTestDB(t))
+	ctx := context.Background()
+
+	calls := 0
+	migrate := func(context.Context) error {
+		calls++
+		return nil
+	}
+
+	ran, err := repo.RunOnce(ctx, "trigger-kinds", migrate)
+	require.NoError(t, err)
+	require.True(t, ran)
+
+	ran, err = repo.RunOnce(ctx, "trigger-kinds", migrate)
+	require.NoError(t, err)
+	require.False(t, ran)
+	require.Equal(t, 1, calls)
+}
+
+func TestRunOnceRetriesFailedMigration(t *testing.T) {
+	repo := NewRepository(setupTestDB(t))
+	ctx := context.Background()
+
+	_, err := repo.RunOnce(ctx, "trigger-kinds", func(context.Context) error { return errors.New("db is down") })
+	require.Error(t, err)
+
+	ran, err := repo.RunOnce(ctx, "trigger-kinds", func(context.Context) error { return nil })
+	require.NoError(t, err)
+	require.True(t, ran)
+}
@@ -0,0 +1,144 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// rewriteBatchSize bounds how many drafts RewriteDraftNodes loads at once.
+const rewriteBatchSize = 100
+
+// PublishedWorkflow pairs a workflow with the version snapshot it currently executes.
+type PublishedWorkflow struct {
+	Workflow WorkflowEntity
+	Version  WorkflowVersionEntity
+}
+
+func (r *repository) PublishVersion(ctx context.Context, workflowID, versionID string) (WorkflowEntity, error) {
+	if _, err := r.FindVersionByID(ctx, workflowID, versionID); err != nil {
+		return WorkflowEntity{}, err
+	}
+
+	if err := r.db.WithContext(ctx).
+		Model(&WorkflowEntity{}).
+		Where("id = ?", workflowID).
+		UpdateColumn("published_version_id", versionID).Error; err != nil {
+		return WorkflowEntity{}, err
+	}
+
+	return r.FindByID(ctx, workflowID)
+}
+
+func (r *repository) FindPublished(ctx context.Context, workflowID string) (WorkflowEntity, WorkflowVersionEntity, error) {
+	entity, err := r.FindByID(ctx, workflowID)
+	if err != nil {
+		return WorkflowEntity{}, WorkflowVersionEntity{}, err
+	}
+	if entity.PublishedVersionID == nil {
+		return WorkflowEntity{}, WorkflowVersionEntity{}, ErrNotPublished
+	}
+
+	version, err := r.FindVersionByID(ctx, workflowID, *entity.PublishedVersionID)
+	if err != nil {
+		return WorkflowEntity{}, WorkflowVersionEntity{}, err
+	}
+	return entity, version, nil
+}
+
+func (r *repository) ListPublished(ctx context.Context) ([]PublishedWorkflow, error) {
+	var entities []WorkflowEntity
+	if err := r.db.WithContext(ctx).
+		Where("published_version_id IS NOT NULL").
+		Order("created_at DESC").
+		Find(&entities).Error; err != nil {
+		return nil, err
+	}
+	if len(entities) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, len(entities))
+	for i, e := range entities {
+		ids[i] = *e.PublishedVersionID
+	}
+
+	var versions []WorkflowVersionEntity
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[string]WorkflowVersionEntity, len(versions))
+	for _, v := range versions {
+		byID[v.ID] = v
+	}
+
+	out := make([]PublishedWorkflow, 0, len(entities))
+	for _, e := range entities {
+		if v, ok := byID[*e.PublishedVersionID]; ok {
+			out = append(out, PublishedWorkflow{Workflow: e, Version: v})
+		}
+	}
+	return out, nil
+}
+
+// BackfillPublishedVersions publishes the latest version of every workflow that has
+// never been published, so workflows created before the draft/published split keep
+// running after an upgrade.
+func (r *repository) BackfillPublishedVersions(ctx context.Context) (int64, error) {
+	var affected int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var entities []WorkflowEntity
+		if err := tx.Where("published_version_id IS NULL").Find(&entities).Error; err != nil {
+			return err
+		}
+
+		for _, e := range entities {
+			var latest WorkflowVersionEntity
+			err := tx.Where("workflow_id = ?", e.ID).Order("version_number DESC").Limit(1).Find(&latest).Error
+			if err != nil {
+				return err
+			}
+			if latest.ID == "" {
+				continue
+			}
+			if err := tx.Model(&WorkflowEntity{}).
+				Where("id = ?", e.ID).
+				UpdateColumn("published_version_id", latest.ID).Error; err != nil {
+				return err
+			}
+			affected++
+		}
+		return nil
+	})
+	return affected, err
+}
+
+// RewriteDraftNodes passes the nodes of every workflow draft through rewrite and stores
+// the ones it reports as changed, a batch of drafts at a time. Version snapshots and
+// revisions are left alone: published graphs stay exactly as they were published.
+// It returns the number of drafts updated.
+func (r *repository) RewriteDraftNodes(ctx context.Context, rewrite func(nodes []byte) ([]byte, bool, error)) (int64, error) {
+	var affected int64
+	var entities []WorkflowEntity
+	res := r.db.WithContext(ctx).FindInBatches(&entities, rewriteBatchSize, func(tx *gorm.DB, _ int) error {
+		for _, e := range entities {
+			nodes, changed, err := rewrite(e.Nodes)
+			if err != nil {
+				return fmt.Errorf("workflow %s: %w", e.ID, err)
+			}
+			if !changed {
+				continue
+			}
+			if err := r.db.WithContext(ctx).Model(&WorkflowEntity{}).
+				Where("id = ?", e.ID).
+				UpdateColumn("nodes", datatypes.JSON(nodes)).Error; err != nil {
+				return err
+			}
+			affected++
+		}
+		return nil
+	})
+	return affected, res.Error
+}
@@ -14,10 +14,12 @@ import (
 	"gorm.io/gorm"
 )
 
+var ErrNotPublished = errors.New("workflow has no published version")
+
 const (
-	MaxVersionsPerWorkflow = 100
-	VersionSourceSave      = "save"
-	VersionSourceRestore   = "restore"
+	DefaultMaxVersionsPerWorkflow = 100
+	VersionSourceSave             = "save"
+	VersionSourceRestore          = "restore"
 )
 
 type WorkflowVersionEntity struct {
@@ -34,6 +36,9 @@ type WorkflowVersionEntity struct {
 	Source                string         `gorm:"type:text;not null"`
 	RestoredFromVersionID *string        `gorm:"type:text"`
 	ContentHash           string         `gorm:"type:text;not null"`
+	Label                 string         `gorm:"type:text"`
+	Comment               string         `gorm:"type:text"`
+	Pinned                bool           `gorm:"not null;default:false"`
 	CreatedAt             time.Time      `gorm:"autoCreateTime"`
 }
 
@@ -45,6 +50,17 @@ type VersionMeta struct {
 	CreatedAt     time.Time `json:"createdAt"`
 	IsActive      bool      `json:"isActive"`
 	Name          string    `json:"name"`
+	IsPublished   bool      `json:"isPublished"`
+	Label         string    `json:"label"`
+	Comment       string    `json:"comment"`
+	Pinned        bool      `json:"pinned"`
+}
+
+// VersionUpdateInput changes version annotations; nil fields are left untouched.
+type VersionUpdateInput struct {
+	Label   *string
+	Comment *string
+	Pinned  *bool
 }
 
 type VersionSnapshot struct {
@@ -82,7 +98,9 @@ func ComputeContentHash(nodes, edges []byte, filters map[string]string) string {
 	return hex.EncodeToString(sum[:])
 }
 
-func (r *repository) createVersionSnapshot(ctx context.Context, tx *gorm.DB, entity WorkflowEntity, source string, restoredFrom *string) error {
+// createVersionSnapshot stores entity as a new version unless its content matches the
+// latest one, and keeps entity.VersionNumber in sync with the latest version.
+func (r *repository) createVersionSnapshot(ctx context.Context, tx *gorm.DB, entity *WorkflowEntity, source string, restoredFrom *string) error {
 	hash := ComputeContentHash([]byte(entity.Nodes), []byte(entity.Edges), jsonMapToStringMap(entity.Filters))
 
 	var latest WorkflowVersionEntity
@@ -91,7 +109,7 @@ func (r *repository) createVersionSnapshot(ctx context.Context, tx *gorm.DB, ent
 		Order("version_number DESC").
 		First(&latest).Error
 	if err == nil && latest.ContentHash == hash {
-		return nil
+		return setCurrentVersion(ctx, tx, entity, latest.VersionNumber)
 	}
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return err
@@ -125,24 +143,60 @@ func (r *repository) createVersionSnapshot(ctx context.Context, tx *gorm.DB, ent
 		return err
 	}
 
-	return pruneOldVersions(ctx, tx, entity.ID)
+	if err := setCurrentVersion(ctx, tx, entity, nextNumber); err != nil {
+		return err
+	}
+
+	return r.pruneOldVersions(ctx, tx, entity.ID)
+}
+
+func setCurrentVersion(ctx context.Context, tx *gorm.DB, entity *WorkflowEntity, number int) error {
+	if entity.VersionNumber == number {
+		return nil
+	}
+	if err := tx.WithContext(ctx).
+		Model(&WorkflowEntity{}).
+		Where("id = ?", entity.ID).
+		UpdateColumn("version_number", number).Error; err != nil {
+		return err
+	}
+	entity.VersionNumber = number
+	return nil
 }
 
-func pruneOldVersions(ctx context.Context, tx *gorm.DB, workflowID string) error {
-	var ids []string
+// pruneOldVersions keeps the newest maxVersions unpinned versions. Pinned versions and
+// the published one are never deleted and do not count towards the cap.
+func (r *repository) pruneOldVersions(ctx context.Context, tx *gorm.DB, workflowID string) error {
+	if r.maxVersions <= 0 {
+		return nil
+	}
+
+	var published []string
 	if err := tx.WithContext(ctx).
+		Model(&WorkflowEntity{}).
+		Where("id = ? AND published_version_id IS NOT NULL", workflowID).
+		Pluck("published_version_id", &published).Error; err != nil {
+		return err
+	}
+
+	query := tx.WithContext(ctx).
 		Model(&WorkflowVersionEntity{}).
 		Select("id").
-		Where("workflow_id = ?", workflowID).
+		Where("workflow_id = ? AND pinned = ?", workflowID, false)
+	if len(published) > 0 {
+		query = query.Where("id NOT IN ?", published)
+	}
+	var expired []string
+	if err := query.
 		Order("version_number DESC").
-		Offset(MaxVersionsPerWorkflow).
-		Pluck("id", &ids).Error; err != nil {
+		Offset(r.maxVersions).
+		Pluck("id", &expired).Error; err != nil {
 		return err
 	}
-	if len(ids) == 0 {
+	if len(expired) == 0 {
 		return nil
 	}
-	return tx.WithContext(ctx).Where("id IN ?", ids).Delete(&WorkflowVersionEntity{}).Error
+	return tx.WithContext(ctx).Where("id IN ?", expired).Delete(&WorkflowVersionEntity{}).Error
 }
 
 func (r *repository) ListVersions(ctx context.Context, workflowID string) ([]VersionMeta, error) {
@@ -154,9 +208,15 @@ func (r *repository) ListVersions(ctx context.Context, workflowID string) ([]Ver
 		return nil, err
 	}
 
+	var workflow WorkflowEntity
+	if err := r.db.WithContext(ctx).Where("id = ?", workflowID).Limit(1).Find(&workflow).Error; err != nil {
+		return nil, err
+	}
+
 	out := make([]VersionMeta, len(entities))
 	for i, e := range entities {
 		out[i] = versionEntityToMeta(e)
+		out[i].IsPublished = workflow.PublishedVersionID != nil && *workflow.PublishedVersionID == e.ID
 	}
 	return out, nil
 }
@@ -166,7 +226,7 @@ func (r *repository) FindVersionByID(ctx context.Context, workflowID, versionID
 	if err := r.db.WithContext(ctx).
 		Where("id = ? AND workflow_id = ?", versionID, workflowID).
 		First(&entity).Error; err != nil {
-		return WorkflowVersionEntity{}, err
+		return WorkflowVersionEntity{}, notFound(err)
 	}
 	return entity, nil
 }
@@ -180,7 +240,7 @@ func (r *repository) RestoreVersion(ctx context.Context, workflowID, versionID s
 	var entity WorkflowEntity
 	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Where("id = ?", workflowID).First(&entity).Error; err != nil {
-			return err
+			return notFound(err)
 		}
 
 		entity.Name = version.Name
@@ -194,7 +254,7 @@ func (r *repository) RestoreVersion(ctx context.Context, workflowID, versionID s
 			entity.Filters = datatypes.JSONMap{}
 		}
 
-		if err := tx.Model(&entity).Updates(map[string]interface{}{
+		if err := updateAtRevision(tx, &entity, map[string]interface{}{
 			"name":         entity.Name,
 			"description":  entity.Description,
 			"nodes":        entity.Nodes,
@@ -202,12 +262,12 @@ func (r *repository) RestoreVersion(ctx context.Context, workflowID, versionID s
 			"filters":      entity.Filters,
 			"is_active":    entity.IsActive,
 			"canvas_zoom":  entity.CanvasZoom,
-		}).Error; err != nil {
+		}); err != nil {
 			return err
 		}
 
 		restoredFrom := version.ID
-		return r.createVersionSnapshot(ctx, tx, entity, VersionSourceRestore, &restoredFrom)
+		return r.createVersionSnapshot(ctx, tx, &entity, VersionSourceRestore, &restoredFrom)
 	})
 	if err != nil {
 		return WorkflowEntity{}, err
@@ -216,6 +276,40 @@ func (r *repository) RestoreVersion(ctx context.Context, workflowID, versionID s
 	return entity, nil
 }
 
+func (r *repository) UpdateVersion(ctx context.Context, workflowID, versionID string, input VersionUpdateInput) (VersionMeta, error) {
+	version, err := r.FindVersionByID(ctx, workflowID, versionID)
+	if err != nil {
+		return VersionMeta{}, err
+	}
+
+	updates := map[string]interface{}{}
+	if input.Label != nil {
+		version.Label = *input.Label
+		updates["label"] = version.Label
+	}
+	if input.Comment != nil {
+		version.Comment = *input.Comment
+		updates["comment"] = version.Comment
+	}
+	if input.Pinned != nil {
+		version.Pinned = *input.Pinned
+		updates["pinned"] = version.Pinned
+	}
+	if len(updates) > 0 {
+		if err := r.db.WithContext(ctx).Model(&version).Updates(updates).Error; err != nil {
+			return VersionMeta{}, err
+		}
+	}
+
+	meta := versionEntityToMeta(version)
+	var workflow WorkflowEntity
+	if err := r.db.WithContext(ctx).Where("id = ?", workflowID).Limit(1).Find(&workflow).Error; err != nil {
+		return VersionMeta{}, err
+	}
+	meta.IsPublished = workflow.PublishedVersionID != nil && *workflow.PublishedVersionID == version.ID
+	return meta, nil
+}
+
 func versionEntityToMeta(e WorkflowVersionEntity) VersionMeta {
 	return VersionMeta{
 		ID:            e.ID,
@@ -225,6 +319,9 @@ func versionEntityToMeta(e WorkflowVersionEntity) VersionMeta {
 		CreatedAt:     e.CreatedAt,
 		IsActive:      e.IsActive,
 		Name:          e.Name,
+		Label:         e.Label,
+		Comment:       e.Comment,
+		Pinned:        e.Pinned,
 	}
 }
 
@@ -11,16 +11,19 @@ import (
 )
 
 type WorkflowEntity struct {
-	ID          string            `gorm:"primaryKey"`
-	Name        string            `gorm:"type:text;not null"`
-	Description string            `gorm:"type:text"`
-	Nodes       datatypes.JSON    `gorm:"type:jsonb"`
-	Edges       datatypes.JSON    `gorm:"type:jsonb"`
-	Filters     datatypes.JSONMap `gorm:"type:jsonb"`
-	IsActive    bool              `gorm:"not null;default:false"`
-	CanvasZoom  *float64          `gorm:"type:double precision"`
-	CreatedAt   time.Time         `gorm:"autoCreateTime"`
-	UpdatedAt   time.Time         `gorm:"autoUpdateTime"`
+	ID                 string            `gorm:"primaryKey"`
+	Name               string            `gorm:"type:text;not null"`
+	Description        string            `gorm:"type:text"`
+	Nodes              datatypes.JSON    `gorm:"type:jsonb"`
+	Edges              datatypes.JSON    `gorm:"type:jsonb"`
+	Filters            datatypes.JSONMap `gorm:"type:jsonb"`
+	IsActive           bool              `gorm:"not null;default:false"`
+	CanvasZoom         *float64          `gorm:"type:double precision"`
+	VersionNumber      int               `gorm:"not null;default:0"`
+	PublishedVersionID *string           `gorm:"type:text"`
+	Revision           int               `gorm:"not null;default:1"`
+	CreatedAt          time.Time         `gorm:"autoCreateTime"`
+	UpdatedAt          time.Time         `gorm:"autoUpdateTime"`
 }
 
 type Repository interface {
@@ -31,6 +34,27 @@ type Repository interface {
 	ListVersions(ctx context.Context, workflowID string) ([]VersionMeta, error)
 	FindVersionByID(ctx context.Context, workflowID, versionID string) (WorkflowVersionEntity, error)
 	RestoreVersion(ctx context.Context, workflowID, versionID string) (WorkflowEntity, error)
+	PublishVersion(ctx context.Context, workflowID, versionID string) (WorkflowEntity, error)
+	FindPublished(ctx context.Context, workflowID string) (WorkflowEntity, WorkflowVersionEntity, error)
+	ListPublished(ctx context.Context) ([]PublishedWorkflow, error)
+	BackfillPublishedVersions(ctx context.Context) (int64, error)
+	BackfillRevisions(ctx context.Context) (int64, error)
+	RewriteDraftNodes(ctx context.Context, rewrite func(nodes []byte) ([]byte, bool, error)) (int64, error)
+	UpdateVersion(ctx context.Context, workflowID, versionID string, input VersionUpdateInput) (VersionMeta, error)
+}
+
+// ErrRevisionConflict is returned when a save is based on a stale revision.
+var ErrRevisionConflict = errors.New("workflow was modified by someone else")
+
+// ErrNotFound is returned when the requested workflow or version does not exist.
+var ErrNotFound = errors.New("workflow not found")
+
+// notFound maps gorm's missing-record error to ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
 }
 
 type SaveInput struct {
@@ -42,14 +66,24 @@ type SaveInput struct {
 	Filters     map[string]string
 	IsActive    bool
 	CanvasZoom  *float64
+	// ExpectedRevision, when set, makes the save fail with ErrRevisionConflict unless
+	// the stored workflow is still at this revision.
+	ExpectedRevision *int
 }
 
 type repository struct {
-	db *gorm.DB
+	db          *gorm.DB
+	maxVersions int
 }
 
 func NewRepository(db *gorm.DB) Repository {
-	return &repository{db: db}
+	return NewRepositoryWithLimit(db, DefaultMaxVersionsPerWorkflow)
+}
+
+// NewRepositoryWithLimit keeps at most maxVersions unpinned versions per workflow;
+// zero or less disables pruning.
+func NewRepositoryWithLimit(db *gorm.DB, maxVersions int) Repository {
+	return &repository{db: db, maxVersions: maxVersions}
 }
 
 func (r *repository) Save(ctx context.Context, input SaveInput) (WorkflowEntity, error) {
@@ -73,6 +107,9 @@ func (r *repository) Save(ctx context.Context, input SaveInput) (WorkflowEntity,
 		}
 
 		if isNew {
+			if input.ExpectedRevision != nil && *input.ExpectedRevision != 0 {
+				return ErrRevisionConflict
+			}
 			entity = WorkflowEntity{
 				ID:          workflowID,
 				Name:        input.Name,
@@ -82,11 +119,15 @@ func (r *repository) Save(ctx context.Context, input SaveInput) (WorkflowEntity,
 				Filters:     filters,
 				IsActive:    input.IsActive,
 				CanvasZoom:  input.CanvasZoom,
+				Revision:    1,
 			}
 			if err := tx.Create(&entity).Error; err != nil {
 				return err
 			}
 		} else {
+			if input.ExpectedRevision != nil && *input.ExpectedRevision != entity.Revision {
+				return ErrRevisionConflict
+			}
 			entity.Name = input.Name
 			entity.Description = input.Description
 			entity.Nodes = datatypes.JSON(input.Nodes)
@@ -95,7 +136,7 @@ func (r *repository) Save(ctx context.Context, input SaveInput) (WorkflowEntity,
 			entity.CanvasZoom = input.CanvasZoom
 			entity.Filters = filters
 
-			if err := tx.Model(&entity).Updates(map[string]interface{}{
+			if err := updateAtRevision(tx, &entity, map[string]interface{}{
 				"name":        entity.Name,
 				"description": entity.Description,
 				"nodes":       entity.Nodes,
@@ -103,12 +144,12 @@ func (r *repository) Save(ctx context.Context, input SaveInput) (WorkflowEntity,
 				"filters":     entity.Filters,
 				"is_active":   entity.IsActive,
 				"canvas_zoom": entity.CanvasZoom,
-			}).Error; err != nil {
+			}); err != nil {
 				return err
 			}
 		}
 
-		return r.createVersionSnapshot(ctx, tx, entity, VersionSourceSave, nil)
+		return r.createVersionSnapshot(ctx, tx, &entity, VersionSourceSave, nil)
 	})
 	if err != nil {
 		return WorkflowEntity{}, err
@@ -118,10 +159,41 @@ func (r *repository) Save(ctx context.Context, input SaveInput) (WorkflowEntity,
 	return r.FindByID(ctx, entity.ID)
 }
 
+// BackfillRevisions moves workflows saved before revisions existed from revision 0 to 1.
+// Revision 0 is reserved for "must not exist yet", so a create request with If-Match: 0
+// could otherwise overwrite such a workflow.
+func (r *repository) BackfillRevisions(ctx context.Context) (int64, error) {
+	res := r.db.WithContext(ctx).
+		Model(&WorkflowEntity{}).
+		Where("revision < ?", 1).
+		UpdateColumn("revision", 1)
+	return res.RowsAffected, res.Error
+}
+
+// updateAtRevision applies updates only if the row still has entity.Revision and bumps
+// the revision, so concurrent writers cannot silently overwrite each other.
+func updateAtRevision(tx *gorm.DB, entity *WorkflowEntity, updates map[string]interface{}) error {
+	now := time.Now()
+	updates["revision"] = entity.Revision + 1
+	updates["updated_at"] = now
+	res := tx.Model(&WorkflowEntity{}).
+		Where("id = ? AND revision = ?", entity.ID, entity.Revision).
+		Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrRevisionConflict
+	}
+	entity.Revision++
+	entity.UpdatedAt = now
+	return nil
+}
+
 func (r *repository) FindByID(ctx context.Context, id string) (WorkflowEntity, error) {
 	var entity WorkflowEntity
 	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&entity).Error; err != nil {
-		return WorkflowEntity{}, err
+		return WorkflowEntity{}, notFound(err)
 	}
 	return entity, nil
 }
@@ -170,3 +170,278 @@ func TestComputeContentHashStable(t *testing.T) {
 	h2 := ComputeContentHash(nodes, edges, map[string]string{"a": "1", "b": "2"})
 	require.Equal(t, h1, h2)
 }
+
+func TestSaveTracksCurrentVersionNumber(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	nodes, edges := testNodesEdges()
+	saved, err := repo.Save(ctx, SaveInput{Name: "Test", Nodes: nodes, Edges: edges})
+	require.NoError(t, err)
+	require.Equal(t, 1, saved.VersionNumber)
+
+	nodes2, _ := json.Marshal([]map[string]string{{"id": "n2"}})
+	saved, err = repo.Save(ctx, SaveInput{ID: saved.ID, Name: "Test", Nodes: nodes2, Edges: edges})
+	require.NoError(t, err)
+	require.Equal(t, 2, saved.VersionNumber)
+
+	versions, err := repo.ListVersions(ctx, saved.ID)
+	require.NoError(t, err)
+	restored, err := repo.RestoreVersion(ctx, saved.ID, versions[1].ID)
+	require.NoError(t, err)
+	require.Equal(t, 3, restored.VersionNumber)
+}
+
+func TestPublishVersionPinsExecutedGraph(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	nodes, edges := testNodesEdges()
+	saved, err := repo.Save(ctx, SaveInput{Name: "V1", Nodes: nodes, Edges: edges})
+	require.NoError(t, err)
+
+	_, _, err = repo.FindPublished(ctx, saved.ID)
+	require.ErrorIs(t, err, ErrNotPublished)
+
+	versions, err := repo.ListVersions(ctx, saved.ID)
+	require.NoError(t, err)
+	_, err = repo.PublishVersion(ctx, saved.ID, versions[0].ID)
+	require.NoError(t, err)
+
+	nodes2, _ := json.Marshal([]map[string]string{{"id": "n2"}})
+	_, err = repo.Save(ctx, SaveInput{ID: saved.ID, Name: "V2", Nodes: nodes2, Edges: edges})
+	require.NoError(t, err)
+
+	entity, version, err := repo.FindPublished(ctx, saved.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, version.VersionNumber)
+	require.Equal(t, "V1", version.Name)
+	require.Equal(t, 2, entity.VersionNumber)
+
+	published, err := repo.ListPublished(ctx)
+	require.NoError(t, err)
+	require.Len(t, published, 1)
+	require.Equal(t, versions[0].ID, published[0].Version.ID)
+
+	versions, err = repo.ListVersions(ctx, saved.ID)
+	require.NoError(t, err)
+	require.False(t, versions[0].IsPublished)
+	require.True(t, versions[1].IsPublished)
+}
+
+func TestMissingWorkflowAndVersionReturnErrNotFound(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	nodes, edges := testNodesEdges()
+	saved, err := repo.Save(ctx, SaveInput{Name: "V1", Nodes: nodes, Edges: edges})
+	require.NoError(t, err)
+
+	_, err = repo.FindByID(ctx, "missing")
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = repo.FindVersionByID(ctx, saved.ID, "missing")
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = repo.PublishVersion(ctx, saved.ID, "missing")
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = repo.RestoreVersion(ctx, saved.ID, "missing")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+	_, err = repo.FindVersionByID(ctx, saved.ID, "missing")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrNotFound)
+}
+
+func TestBackfillPublishedVersions(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	nodes, edges := testNodesEdges()
+	saved, err := repo.Save(ctx, SaveInput{Name: "Legacy", Nodes: nodes, Edges: edges})
+	require.NoError(t, err)
+
+	affected, err := repo.BackfillPublishedVersions(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), affected)
+
+	_, version, err := repo.FindPublished(ctx, saved.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, version.VersionNumber)
+}
+
+func TestPruneKeepsPinnedVersions(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRepositoryWithLimit(db, 2)
+	ctx := context.Background()
+
+	nodes, edges := testNodesEdges()
+	saved, err := repo.Save(ctx, SaveInput{Name: "v1", Nodes: nodes, Edges: edges})
+	require.NoError(t, err)
+
+	versions, err := repo.ListVersions(ctx, saved.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	first := versions[0].ID
+
+	pinned := true
+	label := "baseline"
+	meta, err := repo.UpdateVersion(ctx, saved.ID, first, VersionUpdateInput{Label: &label, Pinned: &pinned})
+	require.NoError(t, err)
+	require.True(t, meta.Pinned)
+	require.Equal(t, "baseline", meta.Label)
+
+	for _, id := range []string{"n2", "n3", "n4"} {
+		changed, _ := json.Marshal([]map[string]string{{"id": id, "type": "action"}})
+		_, err = repo.Save(ctx, SaveInput{ID: saved.ID, Name: "v1", Nodes: changed, Edges: edges})
+		require.NoError(t, err)
+	}
+
+	versions, err = repo.ListVersions(ctx, saved.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+
+	ids := make([]string, len(versions))
+	for i, v := range versions {
+		ids[i] = v.ID
+	}
+	require.Contains(t, ids, first)
+	require.Equal(t, 4, versions[0].VersionNumber)
+}
+
+func TestPruneDoesNotCountPublishedVersion(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRepositoryWithLimit(db, 2)
+	ctx := context.Background()
+
+	_, edges := testNodesEdges()
+	save := func(id, nodeID string) string {
+		nodes, _ := json.Marshal([]map[string]string{{"id": nodeID, "type": "trigger"}})
+		saved, err := repo.Save(ctx, SaveInput{ID: id, Name: "wf", Nodes: nodes, Edges: edges})
+		require.NoError(t, err)
+		return saved.ID
+	}
+	versionIDs := func(id string) map[int]string {
+		versions, err := repo.ListVersions(ctx, id)
+		require.NoError(t, err)
+		out := make(map[int]string, len(versions))
+		for _, v := range versions {
+			out[v.VersionNumber] = v.ID
+		}
+		return out
+	}
+
+	id := save("", "n1")
+	_, err := repo.PublishVersion(ctx, id, versionIDs(id)[1])
+	require.NoError(t, err)
+	save(id, "n2")
+	save(id, "n3")
+
+	// The old published version stays on top of the two newest unpublished ones
+	versions := versionIDs(id)
+	require.Len(t, versions, 3)
+	require.Contains(t, versions, 1)
+
+	// Publishing a version inside the cap does not push an unpublished one out
+	_, err = repo.PublishVersion(ctx, id, versions[3])
+	require.NoError(t, err)
+	save(id, "n4")
+
+	versions = versionIDs(id)
+	require.Len(t, versions, 3)
+	require.Contains(t, versions, 4)
+	require.Contains(t, versions, 3)
+	require.Contains(t, versions, 2)
+}
+
+func TestBackfillRevisionsProtectsLegacyWorkflowsFromCreate(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	nodes, edges := testNodesEdges()
+	saved, err := repo.Save(ctx, SaveInput{Name: "Legacy", Nodes: nodes, Edges: edges})
+	require.NoError(t, err)
+	require.NoError(t, db.Model(&WorkflowEntity{}).Where("id = ?", saved.ID).UpdateColumn("revision", 0).Error)
+
+	affected, err := repo.BackfillRevisions(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), affected)
+
+	mustNotExist := 0
+	_, err = repo.Save(ctx, SaveInput{ID: saved.ID, Name: "Overwrite", Nodes: nodes, Edges: edges, ExpectedRevision: &mustNotExist})
+	require.ErrorIs(t, err, ErrRevisionConflict)
+
+	entity, err := repo.FindByID(ctx, saved.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Legacy", entity.Name)
+	require.Equal(t, 1, entity.Revision)
+}
+
+func TestSaveRejectsStaleRevision(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	nodes, edges := testNodesEdges()
+	saved, err := repo.Save(ctx, SaveInput{Name: "Test", Nodes: nodes, Edges: edges})
+	require.NoError(t, err)
+	require.Equal(t, 1, saved.Revision)
+
+	stale := saved.Revision
+	updated, err := repo.Save(ctx, SaveInput{ID: saved.ID, Name: "First", Nodes: nodes, Edges: edges, ExpectedRevision: &stale})
+	require.NoError(t, err)
+	require.Equal(t, 2, updated.Revision)
+
+	_, err = repo.Save(ctx, SaveInput{ID: saved.ID, Name: "Second", Nodes: nodes, Edges: edges, ExpectedRevision: &stale})
+	require.ErrorIs(t, err, ErrRevisionConflict)
+
+	current, err := repo.FindByID(ctx, saved.ID)
+	require.NoError(t, err)
+	require.Equal(t, "First", current.Name)
+
+	versions, err := repo.ListVersions(ctx, saved.ID)
+	require.NoError(t, err)
+	restored, err := repo.RestoreVersion(ctx, saved.ID, versions[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, 3, restored.Revision)
+}
+
+func TestRewriteDraftNodesLeavesVersionsAlone(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	nodes, edges := testNodesEdges()
+	saved, err := repo.Save(ctx, SaveInput{Name: "Legacy", Nodes: nodes, Edges: edges})
+	require.NoError(t, err)
+	versions, err := repo.ListVersions(ctx, saved.ID)
+	require.NoError(t, err)
+	before, err := repo.FindVersionByID(ctx, saved.ID, versions[0].ID)
+	require.NoError(t, err)
+
+	rewritten := []byte(`[{"id":"rewritten","type":"trigger"}]`)
+	affected, err := repo.RewriteDraftNodes(ctx, func(data []byte) ([]byte, bool, error) {
+		if string(data) == string(rewritten) {
+			return data, false, nil
+		}
+		return rewritten, true, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), affected)
+
+	entity, err := repo.FindByID(ctx, saved.ID)
+	require.NoError(t, err)
+	require.JSONEq(t, string(rewritten), string(entity.Nodes))
+	require.Equal(t, saved.Revision, entity.Revision)
+
+	after, err := repo.FindVersionByID(ctx, saved.ID, versions[0].ID)
+	require.NoError(t, err)
+	require.JSONEq(t, string(before.Nodes), string(after.Nodes))
+	require.Equal(t, before.ContentHash, after.ContentHash)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	persiststorage "notiair/internal/persistence/storage"
 	"notiair/internal/storage"
@@ -17,8 +19,14 @@ type nodeConfig struct {
 	StorageMode     string         `json:"storageMode"`
 	TemplateBody    string         `json:"templateBody"`
 	TemplatePayload map[string]any `json:"templatePayload"`
+	JoinMode        string         `json:"joinMode"`
 }
 
+const (
+	joinModeAll = "all"
+	joinModeAny = "any"
+)
+
 // flowData is the output passed along edges (from the block on the left).
 type flowData struct {
 	Data        []byte
@@ -49,16 +57,56 @@ func buildAdjacency(edges []workflow.Edge) map[string][]string {
 	return adj
 }
 
-func findTriggerIDs(nodes []workflow.Node) []string {
+func buildParents(edges []workflow.Edge) map[string][]string {
+	parents := make(map[string][]string)
+	for _, e := range edges {
+		if !containsString(parents[e.To], e.From) {
+			parents[e.To] = append(parents[e.To], e.From)
+		}
+	}
+	return parents
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// findTriggerIDs returns the trigger nodes to start from; a non-empty only limits the
+// run to those triggers. Triggers of kinds missing from the registry never start.
+func findTriggerIDs(nodes []workflow.Node, only []string) []string {
 	var ids []string
 	for _, n := range nodes {
-		if n.Type == workflow.NodeTypeTrigger {
+		if n.Type != workflow.NodeTypeTrigger || (len(only) > 0 && !containsString(only, n.ID)) {
+			continue
+		}
+		if _, known := workflow.LookupTriggerType(workflow.TriggerKindOf(n)); known {
 			ids = append(ids, n.ID)
 		}
 	}
 	return ids
 }
 
+// reachableFrom returns the nodes reachable from the start nodes, the start nodes included.
+func reachableFrom(start []string, adj map[string][]string) map[string]bool {
+	seen := make(map[string]bool)
+	queue := append([]string(nil), start...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		queue = append(queue, adj[id]...)
+	}
+	return seen
+}
+
 func nodeByID(nodes []workflow.Node) map[string]workflow.Node {
 	m := make(map[string]workflow.Node, len(nodes))
 	for _, n := range nodes {
@@ -113,36 +161,122 @@ func payloadForChannel(in flowData) map[string]any {
 	return map[string]any{}
 }
 
-// executeGraph walks from triggers; each node receives the left block's output.
+// joinState collects the outputs delivered to a join node by each parent.
+type joinState struct {
+	arrivals map[string]flowData
+	fired    bool
+}
+
+// mergeFlows combines parent outputs in parent order: rendered bodies are joined line
+// by line, anything else is merged into a single JSON object (later parents win).
+func mergeFlows(parentIDs []string, arrivals map[string]flowData) (flowData, error) {
+	allRendered := true
+	for _, id := range parentIDs {
+		if in, ok := arrivals[id]; ok && in.Mode != persiststorage.ModeRendered {
+			allRendered = false
+		}
+	}
+
+	if allRendered {
+		var bodies []string
+		for _, id := range parentIDs {
+			if in, ok := arrivals[id]; ok {
+				bodies = append(bodies, renderedBody(in))
+			}
+		}
+		rendered := strings.Join(bodies, "\n")
+		return flowData{
+			Data:        []byte(rendered),
+			ContentType: "text/plain; charset=utf-8",
+			Mode:        persiststorage.ModeRendered,
+			Payload:     map[string]any{"body": rendered},
+		}, nil
+	}
+
+	merged := map[string]any{}
+	for _, id := range parentIDs {
+		in, ok := arrivals[id]
+		if !ok {
+			continue
+		}
+		for k, v := range payloadForChannel(in) {
+			merged[k] = v
+		}
+	}
+	return initialFlow(merged)
+}
+
+// Step records what a single node did during one execution.
+type Step struct {
+	NodeID    string
+	Variant   string
+	Input     map[string]any
+	Output    map[string]any
+	Decision  string
+	Error     string
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+// executeGraph walks from triggers (all of them unless startFrom names some); each node receives the left block's output.
+// A node reached by several paths runs once per path, except join nodes, which wait
+// for all (or any) of their parents reachable from the started triggers and run once
+// with the merged output; a join still waiting when the walk ends fails the run.
+// Every node visit is recorded as a Step, including visits that fail.
 func executeGraph(
 	ctx context.Context,
 	wf workflow.Workflow,
 	workflowID string,
 	payload map[string]any,
 	storageSvc StorageSaver,
-) ([]Task, error) {
+	startFrom []string,
+) ([]Task, []Step, error) {
 	adj := buildAdjacency(wf.Edges)
+	parents := buildParents(wf.Edges)
 	nodes := nodeByID(wf.Nodes)
-	triggerIDs := findTriggerIDs(wf.Nodes)
+	triggerIDs := findTriggerIDs(wf.Nodes, startFrom)
 
 	if len(triggerIDs) == 0 {
-		return nil, fmt.Errorf("workflow %s has no trigger nodes", workflowID)
+		if len(startFrom) > 0 {
+			return nil, nil, fmt.Errorf("workflow %s has none of the trigger nodes %v", workflowID, startFrom)
+		}
+		return nil, nil, fmt.Errorf("workflow %s has no trigger nodes", workflowID)
 	}
 
 	start, err := initialFlow(payload)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	// A join waits only for parents this run can reach: branches of triggers that were
+	// not started never deliver.
+	reachable := reachableFrom(triggerIDs, adj)
+	joinParents := func(nodeID string) []string {
+		var ids []string
+		for _, id := range parents[nodeID] {
+			if reachable[id] {
+				ids = append(ids, id)
+			}
+		}
+		return ids
 	}
 
-	visited := make(map[string]bool)
+	joins := make(map[string]*joinState)
+	onPath := make(map[string]bool)
 	var tasks []Task
+	var steps []Step
 
-	var walk func(nodeID string, in flowData) error
-	walk = func(nodeID string, in flowData) error {
-		if visited[nodeID] {
+	var walk func(nodeID, fromID string, in flowData) error
+	walk = func(nodeID, fromID string, in flowData) error {
+		// Cycles are rejected on activation; drafts are cut here instead of looping.
+		if onPath[nodeID] {
+			steps = append(steps, Step{
+				NodeID:    nodeID,
+				Decision:  fmt.Sprintf("skipped: cycle via %s", fromID),
+				StartedAt: time.Now(),
+			})
 			return nil
 		}
-		visited[nodeID] = true
 
 		node, ok := nodes[nodeID]
 		if !ok {
@@ -150,41 +284,103 @@ func executeGraph(
 		}
 
 		cfg := parseNodeConfig(node)
+		step := Step{
+			NodeID:    nodeID,
+			Variant:   cfg.Variant,
+			Input:     in.Payload,
+			StartedAt: time.Now(),
+		}
+		record := func(out flowData, decision string) {
+			step.Output = out.Payload
+			step.Decision = decision
+			step.Duration = time.Since(step.StartedAt)
+			steps = append(steps, step)
+		}
+
 		out := in
+		decision := "passed through"
 
 		switch cfg.Variant {
+		case "join":
+			state := joins[nodeID]
+			if state == nil {
+				state = &joinState{arrivals: make(map[string]flowData)}
+				joins[nodeID] = state
+			}
+			if state.fired {
+				record(flowData{}, fmt.Sprintf("ignored input from %s: join already fired", fromID))
+				return nil
+			}
+			if _, seen := state.arrivals[fromID]; !seen {
+				state.arrivals[fromID] = in
+			}
+			expected := joinParents(nodeID)
+			if cfg.JoinMode != joinModeAny && len(state.arrivals) < len(expected) {
+				record(flowData{}, fmt.Sprintf("waiting for parents (%d/%d)", len(state.arrivals), len(expected)))
+				return nil
+			}
+			state.fired = true
+			merged, err := mergeFlows(expected, state.arrivals)
+			if err != nil {
+				err = fmt.Errorf("join node %s: %w", nodeID, err)
+				step.Error = err.Error()
+				record(flowData{}, "failed")
+				return err
+			}
+			out = merged
+			decision = fmt.Sprintf("merged %d parent(s)", len(state.arrivals))
+
 		case "template":
 			out = templateOutput(in, cfg.TemplateBody)
+			decision = "rendered template"
 
 		case "storage":
 			saveMode := in.Mode
 			if cfg.StorageMode == "raw" && in.Mode != persiststorage.ModeRendered {
 				saveMode = persiststorage.ModeRaw
 			}
-			if _, err := storageSvc.Save(ctx, storage.SaveInput{
+			rec, err := storageSvc.Save(ctx, storage.SaveInput{
 				WorkflowID:  workflowID,
 				NodeID:      nodeID,
 				Mode:        saveMode,
 				Payload:     in.Payload,
 				Data:        in.Data,
 				ContentType: in.ContentType,
-			}); err != nil {
-				return fmt.Errorf("storage save node %s: %w", nodeID, err)
+			})
+			if err != nil {
+				err = fmt.Errorf("storage save node %s: %w", nodeID, err)
+				step.Error = err.Error()
+				record(flowData{}, "failed")
+				return err
 			}
 			out = in
+			decision = fmt.Sprintf("stored %s record %s", saveMode, rec.ID)
 
 		case "channel":
 			if cfg.ChannelID != "" {
-				tasks = append(tasks, Task{
+				task := Task{
 					WorkflowID: workflowID,
 					ChannelID:  cfg.ChannelID,
 					Payload:    payloadForChannel(in),
-				})
+				}
+				tasks = append(tasks, task)
+				out = flowData{Payload: task.Payload}
+				decision = fmt.Sprintf("notify channel %s", cfg.ChannelID)
+			} else {
+				decision = "skipped: no channel selected"
 			}
+
+		case "trigger":
+			decision = "started"
 		}
 
+		record(out, decision)
+
+		onPath[nodeID] = true
+		defer delete(onPath, nodeID)
+
 		for _, nextID := range adj[nodeID] {
-			if err := walk(nextID, out); err != nil {
+			if err := walk(nextID, nodeID, out); err != nil {
 				return err
 			}
 		}
@@ -192,15 +388,31 @@ func executeGraph(
 	}
 
 	for _, tid := range triggerIDs {
-		if err := walk(tid, start); err != nil {
-			return nil, err
+		if err := walk(tid, "", start); err != nil {
+			return nil, steps, err
+		}
+	}
+
+	// A reachable parent may still never deliver (it was cut as a cycle or is itself a
+	// join that never fired); fail instead of silently dropping the join's branch.
+	for _, node := range wf.Nodes {
+		if state := joins[node.ID]; state != nil && !state.fired {
+			err := fmt.Errorf("join node %s received %d of %d parent(s)", node.ID, len(state.arrivals), len(joinParents(node.ID)))
+			steps = append(steps, Step{
+				NodeID:    node.ID,
+				Variant:   "join",
+				Decision:  "failed",
+				Error:     err.Error(),
+				StartedAt: time.Now(),
+			})
+			return nil, steps, err
 		}
 	}
 
 	// Storage-only (no channel downstream): success with no delivery tasks.
 	if len(tasks) == 0 {
-		return []Task{}, nil
+		return []Task{}, steps, nil
 	}
 
-	return tasks, nil
+	return tasks, steps, nil
 }
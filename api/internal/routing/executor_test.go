@@ -40,7 +40,7 @@ func TestExecuteGraph_StorageAfterTemplateStoresRendered(t *testing.T) {
 		},
 	}
 
-	tasks, err := executeGraph(context.Background(), wf, "wf-1", map[string]any{"name": "World"}, mock)
+	tasks, _, err := executeGraph(context.Background(), wf, "wf-1", map[string]any{"name": "World"}, mock, nil)
 	if err != nil {
 		t.Fatalf("executeGraph: %v", err)
 	}
@@ -73,7 +73,7 @@ func TestExecuteGraph_StorageAfterTriggerStoresJSON(t *testing.T) {
 		Edges: []workflow.Edge{{From: "tr", To: "st"}},
 	}
 
-	_, err := executeGraph(context.Background(), wf, "wf-1", map[string]any{"x": 1}, mock)
+	_, _, err := executeGraph(context.Background(), wf, "wf-1", map[string]any{"x": 1}, mock, nil)
 	if err != nil {
 		t.Fatalf("executeGraph: %v", err)
 	}
@@ -112,7 +112,7 @@ func TestExecuteGraph_TemplateStorageChannelPayloadRendered(t *testing.T) {
 		},
 	}
 
-	tasks, err := executeGraph(context.Background(), wf, "wf-1", map[string]any{"name": "Ann"}, mock)
+	tasks, _, err := executeGraph(context.Background(), wf, "wf-1", map[string]any{"name": "Ann"}, mock, nil)
 	if err != nil {
 		t.Fatalf("executeGraph: %v", err)
 	}
@@ -127,3 +127,189 @@ func TestExecuteGraph_TemplateStorageChannelPayloadRendered(t *testing.T) {
 		t.Fatalf("expected only rendered body in payload, got %v", tasks[0].Payload)
 	}
 }
+
+func diamondWorkflow(joinMode string) workflow.Workflow {
+	wf := workflow.Workflow{
+		ID: "wf-1",
+		Nodes: []workflow.Node{
+			{ID: "tr", Type: workflow.NodeTypeTrigger, Config: map[string]any{"variant": "trigger"}},
+			{ID: "a", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "template", "templateBody": "A {{name}}"}},
+			{ID: "b", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "template", "templateBody": "B {{name}}"}},
+			{ID: "ch", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "channel", "channelId": "chan-1"}},
+		},
+		Edges: []workflow.Edge{
+			{From: "tr", To: "a"},
+			{From: "tr", To: "b"},
+		},
+	}
+	if joinMode == "" {
+		wf.Edges = append(wf.Edges, workflow.Edge{From: "a", To: "ch"}, workflow.Edge{From: "b", To: "ch"})
+		return wf
+	}
+	wf.Nodes = append(wf.Nodes, workflow.Node{ID: "j", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "join", "joinMode": joinMode}})
+	wf.Edges = append(wf.Edges,
+		workflow.Edge{From: "a", To: "j"},
+		workflow.Edge{From: "b", To: "j"},
+		workflow.Edge{From: "j", To: "ch"},
+	)
+	return wf
+}
+
+func TestExecuteGraph_DiamondRunsChannelPerPath(t *testing.T) {
+	tasks, _, err := executeGraph(context.Background(), diamondWorkflow(""), "wf-1", map[string]any{"name": "Ann"}, &mockStorage{}, nil)
+	if err != nil {
+		t.Fatalf("executeGraph: %v", err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("expected 2 tasks, got %d", len(tasks))
+	}
+	if tasks[0].Payload["body"] != "A Ann" || tasks[1].Payload["body"] != "B Ann" {
+		t.Fatalf("unexpected payloads %v, %v", tasks[0].Payload, tasks[1].Payload)
+	}
+}
+
+func TestExecuteGraph_DiamondJoinAllMergesParents(t *testing.T) {
+	tasks, _, err := executeGraph(context.Background(), diamondWorkflow("all"), "wf-1", map[string]any{"name": "Ann"}, &mockStorage{}, nil)
+	if err != nil {
+		t.Fatalf("executeGraph: %v", err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("expected 1 task, got %d", len(tasks))
+	}
+	if body := tasks[0].Payload["body"]; body != "A Ann\nB Ann" {
+		t.Fatalf("body %q, want merged bodies", body)
+	}
+}
+
+func TestExecuteGraph_DiamondJoinAnyTakesFirstParent(t *testing.T) {
+	tasks, _, err := executeGraph(context.Background(), diamondWorkflow("any"), "wf-1", map[string]any{"name": "Ann"}, &mockStorage{}, nil)
+	if err != nil {
+		t.Fatalf("executeGraph: %v", err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("expected 1 task, got %d", len(tasks))
+	}
+	if body := tasks[0].Payload["body"]; body != "A Ann" {
+		t.Fatalf("body %q, want first parent", body)
+	}
+}
+
+func TestExecuteGraph_JoinAllMergesRawPayloads(t *testing.T) {
+	wf := workflow.Workflow{
+		ID: "wf-1",
+		Nodes: []workflow.Node{
+			{ID: "tr", Type: workflow.NodeTypeTrigger, Config: map[string]any{"variant": "trigger"}},
+			{ID: "s1", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "storage"}},
+			{ID: "s2", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "storage"}},
+			{ID: "j", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "join"}},
+			{ID: "ch", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "channel", "channelId": "chan-1"}},
+		},
+		Edges: []workflow.Edge{
+			{From: "tr", To: "s1"},
+			{From: "tr", To: "s2"},
+			{From: "s1", To: "j"},
+			{From: "s2", To: "j"},
+			{From: "j", To: "ch"},
+		},
+	}
+
+	mock := &mockStorage{}
+	tasks, _, err := executeGraph(context.Background(), wf, "wf-1", map[string]any{"x": 1}, mock, nil)
+	if err != nil {
+		t.Fatalf("executeGraph: %v", err)
+	}
+	if len(mock.saved) != 2 {
+		t.Fatalf("expected 2 saves, got %d", len(mock.saved))
+	}
+	if len(tasks) != 1 || tasks[0].Payload["x"] != 1 {
+		t.Fatalf("unexpected tasks %v", tasks)
+	}
+}
+
+func TestExecuteGraph_JoinAllWaitsOnlyForStartedTriggers(t *testing.T) {
+	wf := workflow.Workflow{
+		ID: "wf-1",
+		Nodes: []workflow.Node{
+			{ID: "orders", Type: workflow.NodeTypeTrigger, Config: map[string]any{"triggerKind": "stream"}},
+			{ID: "refunds", Type: workflow.NodeTypeTrigger, Config: map[string]any{"triggerKind": "stream"}},
+			{ID: "a", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "template", "templateBody": "A {{name}}"}},
+			{ID: "b", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "template", "templateBody": "B {{name}}"}},
+			{ID: "j", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "join", "joinMode": "all"}},
+			{ID: "ch", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "channel", "channelId": "chan-1"}},
+		},
+		Edges: []workflow.Edge{
+			{From: "orders", To: "a"},
+			{From: "refunds", To: "b"},
+			{From: "a", To: "j"},
+			{From: "b", To: "j"},
+			{From: "j", To: "ch"},
+		},
+	}
+
+	tasks, _, err := executeGraph(context.Background(), wf, "wf-1", map[string]any{"name": "Ann"}, &mockStorage{}, []string{"orders"})
+	if err != nil {
+		t.Fatalf("executeGraph: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].Payload["body"] != "A Ann" {
+		t.Fatalf("expected the join to fire with the started branch only, got %v", tasks)
+	}
+}
+
+func TestExecuteGraph_RecordsStepPerNodeVisit(t *testing.T) {
+	_, steps, err := executeGraph(context.Background(), diamondWorkflow("all"), "wf-1", map[string]any{"name": "Ann"}, &mockStorage{}, nil)
+	if err != nil {
+		t.Fatalf("executeGraph: %v", err)
+	}
+
+	var got []string
+	for _, st := range steps {
+		got = append(got, st.NodeID+": "+st.Decision)
+	}
+	want := []string{
+		"tr: started",
+		"a: rendered template",
+		"j: waiting for parents (1/2)",
+		"b: rendered template",
+		"j: merged 2 parent(s)",
+		"ch: notify channel chan-1",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("steps %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("step %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+	if steps[1].Output["body"] != "A Ann" {
+		t.Fatalf("template step output %v", steps[1].Output)
+	}
+}
+
+func TestExecuteGraph_StartsOnlyFromGivenTriggers(t *testing.T) {
+	wf := workflow.Workflow{
+		ID: "wf-1",
+		Nodes: []workflow.Node{
+			{ID: "orders", Type: workflow.NodeTypeTrigger, Config: map[string]any{"variant": "trigger"}},
+			{ID: "refunds", Type: workflow.NodeTypeTrigger, Config: map[string]any{"variant": "trigger"}},
+			{ID: "ch-orders", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "channel", "channelId": "chan-orders"}},
+			{ID: "ch-refunds", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "channel", "channelId": "chan-refunds"}},
+		},
+		Edges: []workflow.Edge{
+			{From: "orders", To: "ch-orders"},
+			{From: "refunds", To: "ch-refunds"},
+		},
+	}
+
+	tasks, _, err := executeGraph(context.Background(), wf, "wf-1", map[string]any{}, &mockStorage{}, []string{"refunds"})
+	if err != nil {
+		t.Fatalf("executeGraph: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].ChannelID != "chan-refunds" {
+		t.Fatalf("unexpected tasks %v", tasks)
+	}
+
+	if _, _, err := executeGraph(context.Background(), wf, "wf-1", map[string]any{}, &mockStorage{}, []string{"ghost"}); err == nil {
+		t.Fatal("expected error for unknown trigger")
+	}
+}
@@ -3,12 +3,15 @@ package routing
 import (
 	"context"
 	"fmt"
+	"time"
 
+	persiststorage "notiair/internal/persistence/storage"
+	"notiair/internal/storage"
 	"notiair/internal/workflow"
 )
 
 type WorkflowRepository interface {
-	FindByID(ctx context.Context, id string) (workflow.Workflow, error)
+	FindPublished(ctx context.Context, id string) (workflow.Workflow, error)
 }
 
 type Task struct {
@@ -30,25 +33,91 @@ func NewService(repo WorkflowRepository, storageSvc StorageSaver) *Service {
 	return &Service{wfRepo: repo, storageSvc: storageSvc}
 }
 
-func (s *Service) ResolveTargets(ctx context.Context, workflowID string, payload map[string]any) ([]Task, error) {
-	wf, err := s.wfRepo.FindByID(ctx, workflowID)
+// Result is the outcome of running a workflow graph for one payload.
+type Result struct {
+	WorkflowID    string
+	VersionID     string
+	VersionNumber int
+	Tasks         []Task
+	Steps         []Step
+}
+
+// ResolveTargets runs the published graph of the workflow from the given triggers, or
+// from every trigger when none are given. The returned Result carries the steps
+// executed so far even when an error is returned.
+func (s *Service) ResolveTargets(ctx context.Context, workflowID string, payload map[string]any, triggerIDs ...string) (Result, error) {
+	wf, err := s.wfRepo.FindPublished(ctx, workflowID)
 	if err != nil {
-		return nil, err
+		return Result{WorkflowID: workflowID}, err
 	}
 
 	if s.storageSvc == nil {
-		return nil, fmt.Errorf("storage service not configured")
+		return resultFor(wf), fmt.Errorf("storage service not configured")
+	}
+
+	return s.run(ctx, wf, payload, s.storageSvc, triggerIDs)
+}
+
+// DryRunResult is a Result plus the storage writes that were skipped.
+type DryRunResult struct {
+	Result
+	Storage []storage.SaveInput
+}
+
+// DryRun executes wf without side effects: storage nodes are recorded instead of
+// written and no tasks are handed to the caller for delivery.
+func (s *Service) DryRun(ctx context.Context, wf workflow.Workflow, payload map[string]any) (DryRunResult, error) {
+	saver := &dryRunStorage{}
+	result, err := s.run(ctx, wf, payload, saver, nil)
+	return DryRunResult{Result: result, Storage: saver.saved}, err
+}
+
+func resultFor(wf workflow.Workflow) Result {
+	result := Result{WorkflowID: wf.ID, VersionNumber: wf.VersionNumber}
+	if wf.PublishedVersionID != nil {
+		result.VersionID = *wf.PublishedVersionID
 	}
+	return result
+}
+
+func (s *Service) run(ctx context.Context, wf workflow.Workflow, payload map[string]any, saver StorageSaver, triggerIDs []string) (Result, error) {
+	result := resultFor(wf)
 
-	tasks, err := executeGraph(ctx, wf, workflowID, payload, s.storageSvc)
+	tasks, steps, err := executeGraph(ctx, wf, wf.ID, payload, saver, triggerIDs)
+	result.Steps = steps
 	if err != nil {
 		if len(wf.Filters) > 0 {
-			return s.resolveFromFilters(workflowID, payload, wf), nil
+			result.Tasks = s.resolveFromFilters(wf.ID, payload, wf)
+			result.Steps = append(result.Steps, Step{
+				Variant:   "filters",
+				Decision:  fmt.Sprintf("graph failed, routed to %d filter channel(s)", len(result.Tasks)),
+				Error:     err.Error(),
+				StartedAt: time.Now(),
+			})
+			return result, nil
 		}
-		return nil, err
+		return result, err
 	}
 
-	return tasks, nil
+	result.Tasks = tasks
+	return result, nil
+}
+
+// dryRunStorage collects storage writes instead of persisting them.
+type dryRunStorage struct {
+	saved []storage.SaveInput
+}
+
+func (d *dryRunStorage) Save(ctx context.Context, input storage.SaveInput) (persiststorage.Record, error) {
+	d.saved = append(d.saved, input)
+	return persiststorage.Record{
+		ID:          fmt.Sprintf("dry-run-%d", len(d.saved)),
+		WorkflowID:  input.WorkflowID,
+		NodeID:      input.NodeID,
+		Mode:        input.Mode,
+		ContentType: input.ContentType,
+		Data:        input.Data,
+	}, nil
 }
 
 func (s *Service) resolveFromFilters(workflowID string, payload map[string]any, wf workflow.Workflow) []Task {
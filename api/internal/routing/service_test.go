@@ -0,0 +1,135 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	workflowpersist "notiair/internal/persistence/workflow"
+	"notiair/internal/workflow"
+)
+
+func TestService_DryRunSkipsStorageWrites(t *testing.T) {
+	mock := &mockStorage{}
+	svc := NewService(nil, mock)
+	wf := workflow.Workflow{
+		ID: "wf-1",
+		Nodes: []workflow.Node{
+			{ID: "tr", Type: workflow.NodeTypeTrigger, Config: map[string]any{"variant": "trigger"}},
+			{ID: "tpl", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "template", "templateBody": "Hi {{name}}"}},
+			{ID: "st", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "storage"}},
+			{ID: "ch", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "channel", "channelId": "chan-1"}},
+		},
+		Edges: []workflow.Edge{
+			{From: "tr", To: "tpl"},
+			{From: "tpl", To: "st"},
+			{From: "st", To: "ch"},
+		},
+	}
+
+	result, err := svc.DryRun(context.Background(), wf, map[string]any{"name": "Ann"})
+	if err != nil {
+		t.Fatalf("DryRun: %v", err)
+	}
+	if len(mock.saved) != 0 {
+		t.Fatalf("dry run wrote %d storage records", len(mock.saved))
+	}
+	if len(result.Storage) != 1 || string(result.Storage[0].Data) != "Hi Ann" {
+		t.Fatalf("unexpected storage preview %v", result.Storage)
+	}
+	if len(result.Tasks) != 1 || result.Tasks[0].Payload["body"] != "Hi Ann" {
+		t.Fatalf("unexpected tasks %v", result.Tasks)
+	}
+	if len(result.Steps) != 4 {
+		t.Fatalf("expected 4 steps, got %d", len(result.Steps))
+	}
+}
+
+type publishedRepo struct {
+	published workflow.Workflow
+}
+
+func (r *publishedRepo) FindPublished(ctx context.Context, id string) (workflow.Workflow, error) {
+	return r.published, nil
+}
+
+func TestService_ResolveTargetsRecordsPublishedVersion(t *testing.T) {
+	versionID := "ver-3"
+	repo := &publishedRepo{published: workflow.Workflow{
+		ID:                 "wf-1",
+		VersionNumber:      3,
+		PublishedVersionID: &versionID,
+		Nodes: []workflow.Node{
+			{ID: "tr", Type: workflow.NodeTypeTrigger, Config: map[string]any{"variant": "trigger"}},
+			{ID: "ch", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "channel", "channelId": "chan-1"}},
+		},
+		Edges: []workflow.Edge{{From: "tr", To: "ch"}},
+	}}
+
+	result, err := NewService(repo, &mockStorage{}).ResolveTargets(context.Background(), "wf-1", map[string]any{})
+	if err != nil {
+		t.Fatalf("ResolveTargets: %v", err)
+	}
+	if result.VersionID != "ver-3" || result.VersionNumber != 3 {
+		t.Fatalf("result version %s/%d, want ver-3/3", result.VersionID, result.VersionNumber)
+	}
+	if len(result.Tasks) != 1 {
+		t.Fatalf("expected 1 task, got %d", len(result.Tasks))
+	}
+}
+
+func TestService_NewWorkflowRunsOnlyOncePublished(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&workflowpersist.WorkflowEntity{}, &workflowpersist.WorkflowVersionEntity{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	repo := workflow.NewDBRepository(workflowpersist.NewRepository(db))
+
+	saved, err := repo.Save(ctx, workflow.Workflow{
+		ID:       "wf-new",
+		Name:     "New",
+		IsActive: true,
+		Nodes: []workflow.Node{
+			{ID: "tr", Type: workflow.NodeTypeTrigger, Config: map[string]any{"variant": "trigger"}},
+			{ID: "ch", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "channel", "channelId": "chan-1"}},
+		},
+		Edges: []workflow.Edge{{From: "tr", To: "ch"}},
+	})
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if publication, _ := workflow.PublicationOf(ctx, repo, saved); publication != workflow.PublicationUnpublished {
+		t.Fatalf("publication after create = %q, want unpublished", publication)
+	}
+
+	svc := NewService(repo, &mockStorage{})
+	if _, err := svc.ResolveTargets(ctx, "wf-new", map[string]any{}); !errors.Is(err, workflow.ErrNotPublished) {
+		t.Fatalf("dispatch before publish: got %v, want ErrNotPublished", err)
+	}
+
+	versions, err := repo.ListVersions(ctx, "wf-new")
+	if err != nil || len(versions) != 1 {
+		t.Fatalf("versions %v %v", versions, err)
+	}
+	if _, err := repo.PublishVersion(ctx, "wf-new", versions[0].ID); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if publication, _ := workflow.PublicationOf(ctx, repo, saved); publication != workflow.PublicationPublished {
+		t.Fatalf("publication after publish = %q, want published", publication)
+	}
+
+	result, err := svc.ResolveTargets(ctx, "wf-new", map[string]any{})
+	if err != nil {
+		t.Fatalf("dispatch after publish: %v", err)
+	}
+	if len(result.Tasks) != 1 || result.VersionID != versions[0].ID {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
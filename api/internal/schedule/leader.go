@@ -0,0 +1,108 @@
+package schedule
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	leaderKey = "notiair:scheduler:leader"
+
+	defaultLeaderTTL = 15 * time.Second
+)
+
+var (
+	renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// Leader is a Redis lease that lets exactly one replica run the scheduler. The lease
+// expires after ttl unless the holder renews it, so a crashed leader is replaced.
+type Leader struct {
+	client redis.UniversalClient
+	id     string
+	ttl    time.Duration
+}
+
+func NewLeader(client redis.UniversalClient, ttl time.Duration) *Leader {
+	if ttl < 3*time.Second {
+		ttl = defaultLeaderTTL
+	}
+	return &Leader{client: client, id: uuid.NewString(), ttl: ttl}
+}
+
+// Run blocks until ctx is done. While this replica holds the lease lead runs with a
+// context that is cancelled as soon as the lease is lost.
+func (l *Leader) Run(ctx context.Context, lead func(ctx context.Context)) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	var stop context.CancelFunc
+	var done chan struct{}
+	stepDown := func() {
+		if stop == nil {
+			return
+		}
+		stop()
+		<-done
+		stop, done = nil, nil
+	}
+	defer func() {
+		stepDown()
+		releaseCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		releaseScript.Run(releaseCtx, l.client, []string{leaderKey}, l.id)
+	}()
+
+	for {
+		leading, err := l.acquire(ctx, stop != nil)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("scheduler leader election: %v", err)
+		}
+		switch {
+		case leading && stop == nil:
+			log.Printf("scheduler leadership acquired (%s)", l.id)
+			stop, done = startLead(ctx, lead)
+		case !leading && stop != nil:
+			log.Printf("scheduler leadership lost (%s)", l.id)
+			stepDown()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func startLead(ctx context.Context, lead func(ctx context.Context)) (context.CancelFunc, chan struct{}) {
+	leadCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lead(leadCtx)
+	}()
+	return cancel, done
+}
+
+// acquire takes the lease when it is free or renews it when this replica holds it.
+func (l *Leader) acquire(ctx context.Context, holding bool) (bool, error) {
+	if holding {
+		renewed, err := renewScript.Run(ctx, l.client, []string{leaderKey}, l.id, l.ttl.Milliseconds()).Int()
+		return err == nil && renewed == 1, err
+	}
+	return l.client.SetNX(ctx, leaderKey, l.id, l.ttl).Result()
+}
@@ -0,0 +1,234 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+
+	"notiair/internal/workflow"
+	"notiair/services"
+)
+
+// TaskType is the asynq task enqueued on every fire of a schedule trigger.
+const TaskType = "workflow:schedule"
+
+// maxUniqueTTL caps how long a fire stays deduplicated for rare schedules.
+const maxUniqueTTL = time.Hour
+
+// maxFireLookback bounds the search for the fire a delayed task belongs to; yearly
+// schedules still find theirs.
+const maxFireLookback = 366 * 24 * time.Hour
+
+type TaskPayload struct {
+	WorkflowID string `json:"workflowId"`
+	NodeID     string `json:"nodeId"`
+	Cron       string `json:"cron"`
+	Timezone   string `json:"timezone,omitempty"`
+}
+
+type WorkflowLister interface {
+	FindPublished(ctx context.Context, id string) (workflow.Workflow, error)
+	ListPublished(ctx context.Context) ([]workflow.Workflow, error)
+}
+
+type Dispatcher interface {
+	Dispatch(ctx context.Context, input services.DispatchInput) (string, error)
+}
+
+// RunCounter hands out increasing run numbers per schedule trigger.
+type RunCounter interface {
+	Next(ctx context.Context, workflowID, nodeID string) (int64, error)
+}
+
+// Provider feeds the schedule triggers of active published workflows to asynq's
+// PeriodicTaskManager.
+type Provider struct {
+	workflows WorkflowLister
+	queue     string
+}
+
+func NewProvider(workflows WorkflowLister, queue string) *Provider {
+	return &Provider{workflows: workflows, queue: queue}
+}
+
+func (p *Provider) GetConfigs() ([]*asynq.PeriodicTaskConfig, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	workflows, err := p.workflows.ListPublished(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []*asynq.PeriodicTaskConfig
+	for _, wf := range workflows {
+		if !wf.IsActive {
+			continue
+		}
+		for _, trigger := range workflow.ScheduleTriggers(wf) {
+			sched, err := trigger.Schedule()
+			if err != nil {
+				log.Printf("schedule: skip workflow %s node %s: %v", wf.ID, trigger.NodeID, err)
+				continue
+			}
+			if _, err := trigger.Location(); err != nil {
+				log.Printf("schedule: skip workflow %s node %s: %v", wf.ID, trigger.NodeID, err)
+				continue
+			}
+
+			payload, err := json.Marshal(TaskPayload{
+				WorkflowID: wf.ID,
+				NodeID:     trigger.NodeID,
+				Cron:       trigger.Cron,
+				Timezone:   trigger.Timezone,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			configs = append(configs, &asynq.PeriodicTaskConfig{
+				Cronspec: trigger.Cronspec(),
+				Task:     asynq.NewTask(TaskType, payload),
+				Opts: []asynq.Option{
+					asynq.Queue(p.queue),
+					asynq.MaxRetry(0),
+					asynq.Unique(uniqueTTL(sched, time.Now())),
+				},
+			})
+		}
+	}
+	return configs, nil
+}
+
+// uniqueTTL keeps a fire deduplicated for half the gap to the next one, so two
+// schedulers overlapping during a leader hand-over enqueue it only once.
+func uniqueTTL(sched cron.Schedule, now time.Time) time.Duration {
+	next := sched.Next(now)
+	ttl := sched.Next(next).Sub(next) / 2
+	if ttl > maxUniqueTTL {
+		ttl = maxUniqueTTL
+	}
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	return ttl
+}
+
+// Handler runs the workflow for a fired schedule task.
+type Handler struct {
+	workflows WorkflowLister
+	runner    Dispatcher
+	runs      RunCounter
+	now       func() time.Time
+}
+
+func NewHandler(workflows WorkflowLister, runner Dispatcher, runs RunCounter) *Handler {
+	return &Handler{workflows: workflows, runner: runner, runs: runs, now: time.Now}
+}
+
+// ProcessTask implements asynq.Handler. Fires for workflows that were deactivated
+// or lost the trigger since the last scheduler sync are dropped.
+func (h *Handler) ProcessTask(ctx context.Context, task *asynq.Task) error {
+	var payload TaskPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return fmt.Errorf("%w: %v", asynq.SkipRetry, err)
+	}
+
+	wf, err := h.workflows.FindPublished(ctx, payload.WorkflowID)
+	if err != nil {
+		log.Printf("schedule: workflow %s is gone: %v", payload.WorkflowID, err)
+		return nil
+	}
+	trigger, ok := findTrigger(wf, payload.NodeID)
+	if !wf.IsActive || !ok {
+		return nil
+	}
+
+	loc, err := trigger.Location()
+	if err != nil {
+		return fmt.Errorf("%w: %v", asynq.SkipRetry, err)
+	}
+	sched, err := trigger.Schedule()
+	if err != nil {
+		return fmt.Errorf("%w: %v", asynq.SkipRetry, err)
+	}
+	// The task carries no fire time, and it may run late after a queue backlog or a
+	// leader hand-over; the slot is the latest fire of the schedule, not the clock.
+	scheduledAt, ok := lastFire(sched, h.now().In(loc))
+	if !ok {
+		scheduledAt = h.now().In(loc).Truncate(time.Minute)
+	}
+	runNumber, err := h.runs.Next(ctx, wf.ID, trigger.NodeID)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.runner.Dispatch(ctx, services.DispatchInput{
+		WorkflowID: wf.ID,
+		Variables:  map[string]string{},
+		Payload: map[string]any{
+			"scheduledAt":   scheduledAt.Format(time.RFC3339),
+			"runNumber":     runNumber,
+			"cron":          trigger.Cron,
+			"timezone":      loc.String(),
+			"triggerNodeId": trigger.NodeID,
+		},
+		Source:     services.SourceSchedule,
+		TriggerIDs: []string{trigger.NodeID},
+	})
+	if err != nil {
+		// The failed run is already in execution history; retrying would double-send
+		// whatever did go out.
+		log.Printf("schedule: run %d of workflow %s failed: %v", runNumber, wf.ID, err)
+	}
+	return nil
+}
+
+// lastFire returns the latest fire of sched at or before now, in now's location. The
+// window searched back doubles until it holds a fire, up to maxFireLookback.
+func lastFire(sched cron.Schedule, now time.Time) (time.Time, bool) {
+	for window := time.Minute; window <= 2*maxFireLookback; window *= 2 {
+		fire := sched.Next(now.Add(-window))
+		if fire.IsZero() || fire.After(now) {
+			continue
+		}
+		for {
+			next := sched.Next(fire)
+			if next.IsZero() || next.After(now) {
+				return fire, true
+			}
+			fire = next
+		}
+	}
+	return time.Time{}, false
+}
+
+func findTrigger(wf workflow.Workflow, nodeID string) (workflow.ScheduleTrigger, bool) {
+	for _, trigger := range workflow.ScheduleTriggers(wf) {
+		if trigger.NodeID == nodeID {
+			return trigger, true
+		}
+	}
+	return workflow.ScheduleTrigger{}, false
+}
+
+const runCounterPrefix = "notiair:schedule:runs:"
+
+type redisRunCounter struct {
+	client redis.UniversalClient
+}
+
+// NewRedisRunCounter keeps run numbers in Redis so every replica sees the same sequence.
+func NewRedisRunCounter(client redis.UniversalClient) RunCounter {
+	return &redisRunCounter{client: client}
+}
+
+func (c *redisRunCounter) Next(ctx context.Context, workflowID, nodeID string) (int64, error) {
+	return c.client.Incr(ctx, runCounterPrefix+workflowID+":"+nodeID).Result()
+}
@@ -0,0 +1,152 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"notiair/internal/workflow"
+	"notiair/services"
+)
+
+type fakeDispatcher struct {
+	inputs []services.DispatchInput
+}
+
+func (f *fakeDispatcher) Dispatch(ctx context.Context, input services.DispatchInput) (string, error) {
+	f.inputs = append(f.inputs, input)
+	return "exec-1", nil
+}
+
+type fakeCounter struct {
+	n int64
+}
+
+func (f *fakeCounter) Next(ctx context.Context, workflowID, nodeID string) (int64, error) {
+	f.n++
+	return f.n, nil
+}
+
+func scheduledWorkflow(active bool) workflow.Workflow {
+	return workflow.Workflow{
+		ID:       "wf-1",
+		IsActive: active,
+		Nodes: []workflow.Node{
+			{ID: "tr", Type: workflow.NodeTypeTrigger, Config: map[string]any{"triggerKind": "schedule", "cron": "0 9 * * *", "timezone": "Europe/Moscow"}},
+			{ID: "ch", Type: workflow.NodeTypeAction, Config: map[string]any{"variant": "channel", "channelId": "chan-1"}},
+		},
+		Edges: []workflow.Edge{{From: "tr", To: "ch"}},
+	}
+}
+
+func TestProviderListsActiveScheduleTriggers(t *testing.T) {
+	ctx := context.Background()
+	repo := workflow.NewMemoryRepository()
+	if _, err := repo.Save(ctx, scheduledWorkflow(true)); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	inactive := scheduledWorkflow(false)
+	inactive.ID = "wf-2"
+	if _, err := repo.Save(ctx, inactive); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	configs, err := NewProvider(repo, "notiair:schedule").GetConfigs()
+	if err != nil {
+		t.Fatalf("get configs: %v", err)
+	}
+	if len(configs) != 1 {
+		t.Fatalf("expected one config, got %d", len(configs))
+	}
+	if configs[0].Cronspec != "CRON_TZ=Europe/Moscow 0 9 * * *" || configs[0].Task.Type() != TaskType {
+		t.Fatalf("unexpected config %+v", configs[0])
+	}
+}
+
+func TestHandlerDispatchesScheduledRun(t *testing.T) {
+	ctx := context.Background()
+	repo := workflow.NewMemoryRepository()
+	if _, err := repo.Save(ctx, scheduledWorkflow(true)); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	runner := &fakeDispatcher{}
+	h := NewHandler(repo, runner, &fakeCounter{})
+	h.now = func() time.Time { return time.Date(2026, 3, 2, 6, 0, 30, 0, time.UTC) }
+
+	payload, _ := json.Marshal(TaskPayload{WorkflowID: "wf-1", NodeID: "tr"})
+	if err := h.ProcessTask(ctx, asynq.NewTask(TaskType, payload)); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if len(runner.inputs) != 1 {
+		t.Fatalf("expected one dispatch, got %d", len(runner.inputs))
+	}
+	got := runner.inputs[0]
+	if got.Source != services.SourceSchedule || got.Payload["runNumber"] != int64(1) {
+		t.Fatalf("unexpected dispatch %+v", got)
+	}
+	if got.Payload["scheduledAt"] != "2026-03-02T09:00:00+03:00" {
+		t.Fatalf("unexpected scheduledAt %v", got.Payload["scheduledAt"])
+	}
+
+	stale, _ := json.Marshal(TaskPayload{WorkflowID: "wf-1", NodeID: "removed"})
+	if err := h.ProcessTask(ctx, asynq.NewTask(TaskType, stale)); err != nil || len(runner.inputs) != 1 {
+		t.Fatalf("stale trigger should be skipped, err=%v dispatches=%d", err, len(runner.inputs))
+	}
+}
+
+func TestHandlerUsesFireTimeForDelayedRun(t *testing.T) {
+	ctx := context.Background()
+	repo := workflow.NewMemoryRepository()
+	if _, err := repo.Save(ctx, scheduledWorkflow(true)); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	runner := &fakeDispatcher{}
+	h := NewHandler(repo, runner, &fakeCounter{})
+	// The 09:00 Moscow fire is processed 47 minutes late
+	h.now = func() time.Time { return time.Date(2026, 3, 2, 6, 47, 12, 0, time.UTC) }
+
+	payload, _ := json.Marshal(TaskPayload{WorkflowID: "wf-1", NodeID: "tr"})
+	if err := h.ProcessTask(ctx, asynq.NewTask(TaskType, payload)); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if len(runner.inputs) != 1 {
+		t.Fatalf("expected one dispatch, got %d", len(runner.inputs))
+	}
+	if got := runner.inputs[0].Payload["scheduledAt"]; got != "2026-03-02T09:00:00+03:00" {
+		t.Fatalf("scheduledAt %v, want the 09:00 fire", got)
+	}
+}
+
+func TestLastFire(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	for spec, want := range map[string]time.Time{
+		"*/5 * * * *": time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC),
+		"* 6 * * *":   time.Date(2026, 3, 2, 6, 59, 0, 0, time.UTC),
+		"0 9 1 1 *":   time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC),
+	} {
+		sched, err := workflow.ScheduleTrigger{Cron: spec}.Schedule()
+		if err != nil {
+			t.Fatalf("parse %s: %v", spec, err)
+		}
+		if got, ok := lastFire(sched, now); !ok || !got.Equal(want) {
+			t.Fatalf("%s: last fire %v (%v), want %v", spec, got, ok, want)
+		}
+	}
+}
+
+func TestUniqueTTL(t *testing.T) {
+	now := time.Date(2026, 3, 2, 6, 0, 0, 0, time.UTC)
+	every5, _ := workflow.ScheduleTrigger{Cron: "*/5 * * * *"}.Schedule()
+	if ttl := uniqueTTL(every5, now); ttl != 150*time.Second {
+		t.Fatalf("expected 2m30s, got %v", ttl)
+	}
+	daily, _ := workflow.ScheduleTrigger{Cron: "@daily"}.Schedule()
+	if ttl := uniqueTTL(daily, now); ttl != maxUniqueTTL {
+		t.Fatalf("expected cap, got %v", ttl)
+	}
+}
@@ -0,0 +1,113 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+
+	"notiair/internal/config"
+)
+
+// Scheduler fires schedule triggers. Every replica processes fired tasks from a
+// dedicated queue; only the elected leader registers the cron entries.
+type Scheduler struct {
+	cfg       config.ScheduleConfig
+	redisOpt  asynq.RedisConnOpt
+	client    redis.UniversalClient
+	provider  *Provider
+	handler   *Handler
+	server    *asynq.Server
+	leader    *Leader
+	cancel    context.CancelFunc
+	done      chan struct{}
+	queueName string
+}
+
+func NewScheduler(cfg config.ScheduleConfig, queueCfg config.QueueConfig, workflows WorkflowLister, runner Dispatcher) (*Scheduler, error) {
+	redisOpt, err := redisConnOpt(queueCfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	client, ok := redisOpt.MakeRedisClient().(redis.UniversalClient)
+	if !ok {
+		return nil, fmt.Errorf("unsupported redis connection for scheduler")
+	}
+
+	queueName := queueCfg.Namespace + ":schedule"
+	return &Scheduler{
+		cfg:       cfg,
+		redisOpt:  redisOpt,
+		client:    client,
+		provider:  NewProvider(workflows, queueName),
+		handler:   NewHandler(workflows, runner, NewRedisRunCounter(client)),
+		leader:    NewLeader(client, cfg.LeaderTTL),
+		queueName: queueName,
+	}, nil
+}
+
+// Start begins processing fired tasks and joins the leader election.
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.server = asynq.NewServer(s.redisOpt, asynq.Config{
+		Concurrency: 4,
+		Queues:      map[string]int{s.queueName: 1},
+	})
+	mux := asynq.NewServeMux()
+	mux.Handle(TaskType, s.handler)
+	if err := s.server.Start(mux); err != nil {
+		return err
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		s.leader.Run(ctx, s.runManager)
+	}()
+
+	log.Printf("scheduler started (queue=%s)", s.queueName)
+	return nil
+}
+
+// runManager registers cron entries until leadership is lost.
+func (s *Scheduler) runManager(ctx context.Context) {
+	manager, err := asynq.NewPeriodicTaskManager(asynq.PeriodicTaskManagerOpts{
+		RedisConnOpt:               s.redisOpt,
+		PeriodicTaskConfigProvider: s.provider,
+		SyncInterval:               s.cfg.SyncInterval,
+	})
+	if err != nil {
+		log.Printf("scheduler: %v", err)
+		return
+	}
+	if err := manager.Start(); err != nil {
+		log.Printf("scheduler: start periodic task manager: %v", err)
+		return
+	}
+	<-ctx.Done()
+	manager.Shutdown()
+}
+
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+		<-s.done
+	}
+	if s.server != nil {
+		s.server.Shutdown()
+	}
+	if err := s.client.Close(); err != nil {
+		log.Printf("scheduler: close redis: %v", err)
+	}
+}
+
+// redisConnOpt accepts both redis:// URIs and plain host:port addresses.
+func redisConnOpt(url string) (asynq.RedisConnOpt, error) {
+	if strings.Contains(url, "://") {
+		return asynq.ParseRedisURI(url)
+	}
+	return asynq.RedisClientOpt{Addr: url}, nil
+}
@@ -0,0 +1,599 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/google/uuid"
+	"notiair/internal/workflow"
+	"notiair/services"
+)
+
+const (
+	// maxReplayEvents ограничивает число событий, которые читает один replay
+	maxReplayEvents = 10000
+	// replayProgressEvery — через сколько событий сохраняется ход replay
+	replayProgressEvery = 100
+	// replayReadIdleTimeout — сколько ждать следующего сообщения партиции при replay.
+	// Последний offset транзакционного топика — control record, который не доставляется,
+	// поэтому тишина дольше этого значит, что партиция прочитана.
+	replayReadIdleTimeout = 10 * time.Second
+	// groupLeaveTimeout — сколько ждать, пока все реплики выйдут из consumer group
+	groupLeaveTimeout = 30 * time.Second
+	// groupStatePollInterval — как часто проверять состояние consumer group
+	groupStatePollInterval = 500 * time.Millisecond
+)
+
+var (
+	// ErrTopicNotSubscribed — consumer не читает этот топик, offset выставить некому
+	ErrTopicNotSubscribed = errors.New("topic is not subscribed by the stream consumer")
+	// ErrNoStreamTrigger — у workflow нет stream-триггера на этот топик
+	ErrNoStreamTrigger = errors.New("workflow has no stream trigger for the topic")
+	// ErrGroupActive — в consumer group остались участники, offset сдвинуть нельзя
+	ErrGroupActive = errors.New("consumer group still has active members")
+)
+
+// pauseGate останавливает раздачу сообщений воркерам, пока consumer на паузе
+type pauseGate struct {
+	mu      sync.Mutex
+	resumed chan struct{} // nil — не на паузе; закрывается при Resume
+}
+
+// pause ставит на паузу и возвращает false, если пауза уже стояла
+func (g *pauseGate) pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed != nil {
+		return false
+	}
+	g.resumed = make(chan struct{})
+	return true
+}
+
+// resume снимает паузу и возвращает false, если паузы не было
+func (g *pauseGate) resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed == nil {
+		return false
+	}
+	close(g.resumed)
+	g.resumed = nil
+	return true
+}
+
+func (g *pauseGate) paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.resumed != nil
+}
+
+// wait блокируется, пока consumer на паузе
+func (g *pauseGate) wait(ctx context.Context) error {
+	g.mu.Lock()
+	resumed := g.resumed
+	g.mu.Unlock()
+	if resumed == nil {
+		return nil
+	}
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ConsumerStatus — состояние consumer для админского API
+type ConsumerStatus struct {
+	GroupID           string   `json:"groupId"`
+	Paused            bool     `json:"paused"`
+	Topics            []string `json:"topics"`
+	SkippedDuplicates int64    `json:"skippedDuplicates"`
+	RejectedEvents    int64    `json:"rejectedEvents"`
+}
+
+// Status возвращает состояние consumer
+func (c *Consumer) Status(ctx context.Context) ConsumerStatus {
+	topics, err := c.index.Topics(ctx)
+	if err != nil {
+		log.Printf("failed to list subscribed topics: %v", err)
+	}
+
+	return ConsumerStatus{
+		GroupID:           c.groupID,
+		Paused:            c.gate.paused(),
+		Topics:            topics,
+		SkippedDuplicates: c.SkippedDuplicates(),
+		RejectedEvents:    c.RejectedEvents(),
+	}
+}
+
+// Pause останавливает чтение и обработку сообщений на всех репликах; незакоммиченные
+// сообщения остаются в топике. Пауза сохраняется в Redis, и реплики, запущенные позже,
+// стартуют на паузе.
+func (c *Consumer) Pause(ctx context.Context) error {
+	if c.redisStore != nil {
+		if err := c.redisStore.client.Set(ctx, consumerPausedKey, "1", 0).Err(); err != nil {
+			return fmt.Errorf("failed to save stream consumer pause: %w", err)
+		}
+	}
+	return c.broadcast(ctx, commandPause)
+}
+
+// Resume возобновляет обработку после Pause на всех репликах
+func (c *Consumer) Resume(ctx context.Context) error {
+	if c.redisStore != nil {
+		if err := c.redisStore.client.Del(ctx, consumerPausedKey).Err(); err != nil {
+			return fmt.Errorf("failed to clear stream consumer pause: %w", err)
+		}
+	}
+	return c.broadcast(ctx, commandResume)
+}
+
+func (c *Consumer) pauseLocal() {
+	if c.gate.pause() {
+		c.group().PauseAll()
+		log.Println("stream consumer paused")
+	}
+}
+
+func (c *Consumer) resumeLocal() {
+	c.group().ResumeAll()
+	if c.gate.resume() {
+		log.Println("stream consumer resumed")
+	}
+}
+
+// OffsetReset — новый offset consumer group для топика: явный Offset или первое
+// сообщение не раньше Timestamp. Partition nil — все партиции топика.
+type OffsetReset struct {
+	Topic     string
+	Partition *int32
+	Offset    *int64
+	Timestamp *time.Time
+}
+
+// ResetOffsets вычисляет offset партиций и коммитит их для всей consumer group: все
+// реплики выходят из группы (команда рассылается через Redis), offset коммитятся
+// координатору группы, пока в ней нет участников, и реплики возвращаются. Возвращает
+// offset по партициям.
+func (c *Consumer) ResetOffsets(ctx context.Context, reset OffsetReset) (map[int32]int64, error) {
+	if (reset.Offset == nil) == (reset.Timestamp == nil) {
+		return nil, errors.New("exactly one of offset and timestamp is required")
+	}
+	topics, err := c.index.Topics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !containsString(topics, reset.Topic) {
+		return nil, ErrTopicNotSubscribed
+	}
+
+	partitions, err := c.partitions(reset.Topic, reset.Partition)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		oldest, newest, err := c.offsetRange(reset.Topic, partition)
+		if err != nil {
+			return nil, err
+		}
+		var offset int64
+		if reset.Offset != nil {
+			offset = clampOffset(*reset.Offset, oldest, newest)
+		} else {
+			offset, err = c.offsetForTime(reset.Topic, partition, *reset.Timestamp, newest)
+			if err != nil {
+				return nil, err
+			}
+		}
+		offsets[partition] = offset
+	}
+
+	if err := c.commitGroupOffsets(ctx, reset.Topic, offsets); err != nil {
+		return nil, err
+	}
+	log.Printf("stream offsets of %s reset to %v", reset.Topic, offsets)
+	return offsets, nil
+}
+
+// commitGroupOffsets выводит все реплики из группы, дожидается, пока в ней не останется
+// участников, коммитит offset и возвращает реплики в группу даже при ошибке
+func (c *Consumer) commitGroupOffsets(ctx context.Context, topic string, offsets map[int32]int64) error {
+	if err := c.broadcast(ctx, commandLeave); err != nil {
+		c.apply(commandRejoin)
+		return fmt.Errorf("failed to stop consumer group: %w", err)
+	}
+	defer func() {
+		if err := c.broadcast(context.WithoutCancel(ctx), commandRejoin); err != nil {
+			log.Printf("failed to resume consumer group %s: %v", c.groupID, err)
+		}
+	}()
+
+	if err := c.waitGroupEmpty(ctx); err != nil {
+		return err
+	}
+	return c.offsets.Commit(topic, offsets)
+}
+
+// waitGroupEmpty ждет, пока из consumer group выйдут все участники
+func (c *Consumer) waitGroupEmpty(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, groupLeaveTimeout)
+	defer cancel()
+	for {
+		members, err := c.offsets.Members()
+		if err != nil {
+			return err
+		}
+		if members == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %d members of %s did not leave in %s", ErrGroupActive, members, c.groupID, groupLeaveTimeout)
+		case <-time.After(groupStatePollInterval):
+		}
+	}
+}
+
+// groupOffsets — операции над consumer group целиком, а не над сессией реплики
+type groupOffsets interface {
+	// Members возвращает число участников группы
+	Members() (int, error)
+	// Commit коммитит offset партиций топика; группа должна быть пустой
+	Commit(topic string, offsets map[int32]int64) error
+}
+
+// kafkaGroupOffsets работает с группой через ClusterAdmin и координатор группы
+type kafkaGroupOffsets struct {
+	client  sarama.Client
+	groupID string
+}
+
+func (k *kafkaGroupOffsets) Members() (int, error) {
+	// Close у ClusterAdmin закрыл бы общий клиент consumer, поэтому не вызываем
+	admin, err := sarama.NewClusterAdminFromClient(k.client)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create cluster admin: %w", err)
+	}
+	groups, err := admin.DescribeConsumerGroups([]string{k.groupID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to describe consumer group %s: %w", k.groupID, err)
+	}
+	for _, group := range groups {
+		if group.GroupId == k.groupID {
+			return len(group.Members), nil
+		}
+	}
+	return 0, nil
+}
+
+func (k *kafkaGroupOffsets) Commit(topic string, offsets map[int32]int64) error {
+	coordinator, err := k.client.Coordinator(k.groupID)
+	if err != nil {
+		return fmt.Errorf("failed to find coordinator of %s: %w", k.groupID, err)
+	}
+	// Коммит вне сессии: без участника и поколения, как у kafka-consumer-groups --reset-offsets
+	req := &sarama.OffsetCommitRequest{
+		Version:                 2,
+		ConsumerGroup:           k.groupID,
+		ConsumerGroupGeneration: sarama.GroupGenerationUndefined,
+		RetentionTime:           -1,
+	}
+	for partition, offset := range offsets {
+		req.AddBlock(topic, partition, offset, 0, "")
+	}
+	resp, err := coordinator.CommitOffset(req)
+	if err != nil {
+		return fmt.Errorf("failed to commit offsets of %s: %w", k.groupID, err)
+	}
+	for _, partitions := range resp.Errors {
+		for partition, kerr := range partitions {
+			if kerr != sarama.ErrNoError {
+				return fmt.Errorf("failed to commit offset of %s/%d: %w", topic, partition, kerr)
+			}
+		}
+	}
+	return nil
+}
+
+// ReplayRequest — повторная обработка событий топика за [From, To) одним workflow
+type ReplayRequest struct {
+	Workflow workflow.Workflow
+	Topic    string
+	From     time.Time
+	To       time.Time
+}
+
+// ReplayResult — итог replay
+type ReplayResult struct {
+	Read         int      `json:"read"`
+	Matched      int      `json:"matched"`
+	Dispatched   int      `json:"dispatched"`
+	Failed       int      `json:"failed"`
+	Rejected     int      `json:"rejected"`
+	Truncated    bool     `json:"truncated"`
+	ExecutionIDs []string `json:"executionIds"`
+}
+
+// StartReplay проверяет запрос и запускает replay в фоне: события топика за интервал
+// со всех партиций запускают только указанный workflow. Учет обработанных событий
+// (EventClaims) не применяется; события, не прошедшие проверку схемы, пропускаются без
+// записи в журнал отклонений. Состояние replay возвращает ReplayJob по ID.
+func (c *Consumer) StartReplay(ctx context.Context, req ReplayRequest) (ReplayJob, error) {
+	if !req.From.Before(req.To) {
+		return ReplayJob{}, errors.New("from must be before to")
+	}
+	var triggers []StreamTrigger
+	for _, trigger := range StreamTriggers(req.Workflow, workflow.BrokerKafka, c.topic) {
+		if trigger.Topic == req.Topic {
+			triggers = append(triggers, trigger)
+		}
+	}
+	if len(triggers) == 0 {
+		return ReplayJob{}, ErrNoStreamTrigger
+	}
+
+	job := ReplayJob{
+		ID:         uuid.NewString(),
+		WorkflowID: req.Workflow.ID,
+		Topic:      req.Topic,
+		From:       req.From,
+		To:         req.To,
+		Status:     ReplayRunning,
+		Result:     ReplayResult{ExecutionIDs: []string{}},
+		Owner:      c.replays.owner,
+		StartedAt:  time.Now().UTC(),
+	}
+	if err := c.replays.save(ctx, job); err != nil {
+		return ReplayJob{}, err
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.runReplay(c.replayCtx, job, req, triggers)
+	}()
+	return job, nil
+}
+
+// ReplayJob возвращает состояние replay, запущенного StartReplay
+func (c *Consumer) ReplayJob(ctx context.Context, id string) (ReplayJob, error) {
+	return c.replays.get(ctx, id)
+}
+
+// runReplay проводит replay и сохраняет его состояние каждые replayProgressEvery событий,
+// а пока идет чтение или запуск — раз в replayHeartbeatInterval, чтобы другие реплики
+// отличали идущий replay от брошенного
+func (c *Consumer) runReplay(ctx context.Context, job ReplayJob, req ReplayRequest, triggers []StreamTrigger) {
+	var mu sync.Mutex
+	save := func(update func(*ReplayJob)) {
+		mu.Lock()
+		defer mu.Unlock()
+		update(&job)
+		if err := c.replays.save(context.WithoutCancel(ctx), job); err != nil {
+			log.Printf("failed to save replay job %s: %v", job.ID, err)
+		}
+	}
+
+	heartbeatDone := make(chan struct{})
+	heartbeatStopped := make(chan struct{})
+	go func() {
+		defer close(heartbeatStopped)
+		ticker := time.NewTicker(replayHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-heartbeatDone:
+				return
+			case <-ticker.C:
+				save(func(*ReplayJob) {})
+			}
+		}
+	}()
+
+	result, err := c.replay(ctx, req, triggers, func(progress ReplayResult) {
+		save(func(job *ReplayJob) { job.Result = progress })
+	})
+	close(heartbeatDone)
+	<-heartbeatStopped
+
+	save(func(job *ReplayJob) {
+		job.Result = result
+		job.Status = ReplayCompleted
+		if err != nil {
+			job.Status = ReplayFailed
+			job.Error = err.Error()
+			log.Printf("replay job %s failed: %v", job.ID, err)
+		}
+		finished := time.Now().UTC()
+		job.FinishedAt = &finished
+	})
+}
+
+// replay читает события топика за интервал и запускает ими workflow; progress
+// вызывается каждые replayProgressEvery прочитанных событий
+func (c *Consumer) replay(ctx context.Context, req ReplayRequest, triggers []StreamTrigger, progress func(ReplayResult)) (ReplayResult, error) {
+	result := ReplayResult{ExecutionIDs: []string{}}
+	messages, truncated, err := c.readTimeRange(ctx, req.Topic, req.From, req.To, maxReplayEvents)
+	if err != nil {
+		return result, err
+	}
+
+	result.Read, result.Truncated = len(messages), truncated
+	for i, message := range messages {
+		if i > 0 && i%replayProgressEvery == 0 {
+			progress(result)
+		}
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		event, err := DecodeMessage(message)
+		if err != nil {
+			continue
+		}
+		var triggerIDs []string
+		for _, trigger := range triggers {
+			if trigger.Matches(req.Topic, event) {
+				triggerIDs = append(triggerIDs, trigger.NodeID)
+			}
+		}
+		if len(triggerIDs) == 0 {
+			continue
+		}
+		result.Matched++
+
+		payload := eventPayload(event, req.Topic)
+		if c.schemas != nil {
+			violations, err := c.schemas.Validate(ctx, event.EventType, payload)
+			if err != nil {
+				return result, fmt.Errorf("failed to validate event %s: %w", event.EventID, err)
+			}
+			if len(violations) > 0 {
+				result.Rejected++
+				continue
+			}
+		}
+		payload["replay"] = true
+		executionID, err := c.notificationSvc.Dispatch(ctx, services.DispatchInput{
+			WorkflowID: req.Workflow.ID,
+			Variables:  make(map[string]string),
+			Payload:    payload,
+			Source:     services.SourceReplay,
+			TriggerIDs: triggerIDs,
+		})
+		if err != nil {
+			log.Printf("failed to replay event %s into workflow %s (execution %s): %v", event.EventID, req.Workflow.ID, executionID, err)
+			result.Failed++
+			continue
+		}
+		result.Dispatched++
+		result.ExecutionIDs = append(result.ExecutionIDs, executionID)
+	}
+
+	log.Printf("replayed %s [%s, %s) into workflow %s: read %d, dispatched %d, failed %d",
+		req.Topic, req.From.Format(time.RFC3339), req.To.Format(time.RFC3339), req.Workflow.ID, result.Read, result.Dispatched, result.Failed)
+	return result, nil
+}
+
+// readTimeRange читает сообщения топика с временем в [from, to) со всех партиций в порядке времени
+func (c *Consumer) readTimeRange(ctx context.Context, topic string, from, to time.Time, limit int) ([]*sarama.ConsumerMessage, bool, error) {
+	partitions, err := c.partitions(topic, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	consumer, err := sarama.NewConsumerFromClient(c.client)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	var messages []*sarama.ConsumerMessage
+	truncated := false
+	for _, partition := range partitions {
+		_, newest, err := c.offsetRange(topic, partition)
+		if err != nil {
+			return nil, false, err
+		}
+		start, err := c.offsetForTime(topic, partition, from, newest)
+		if err != nil {
+			return nil, false, err
+		}
+		end, err := c.offsetForTime(topic, partition, to, newest)
+		if err != nil {
+			return nil, false, err
+		}
+
+		idled, err := readPartitionUntilIdle(ctx, consumer, topic, partition, start, end, replayReadIdleTimeout, func(message *sarama.ConsumerMessage) bool {
+			if message.Timestamp.Before(from) || !message.Timestamp.Before(to) {
+				return true
+			}
+			if len(messages) >= limit {
+				truncated = true
+				return false
+			}
+			messages = append(messages, message)
+			return true
+		})
+		if idled {
+			log.Printf("replay of %s/%d stopped before offset %d: no messages for %s", topic, partition, end, replayReadIdleTimeout)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Timestamp.Before(messages[j].Timestamp)
+	})
+	return messages, truncated, nil
+}
+
+func (c *Consumer) partitions(topic string, only *int32) ([]int32, error) {
+	partitions, err := c.client.Partitions(topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get partitions of %s: %w", topic, err)
+	}
+	if only == nil {
+		return partitions, nil
+	}
+	for _, partition := range partitions {
+		if partition == *only {
+			return []int32{partition}, nil
+		}
+	}
+	return nil, fmt.Errorf("topic %s has no partition %d", topic, *only)
+}
+
+func (c *Consumer) offsetRange(topic string, partition int32) (int64, int64, error) {
+	oldest, err := c.client.GetOffset(topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get offset of %s/%d: %w", topic, partition, err)
+	}
+	newest, err := c.client.GetOffset(topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get offset of %s/%d: %w", topic, partition, err)
+	}
+	return oldest, newest, nil
+}
+
+// offsetForTime возвращает offset первого сообщения не раньше t или newest, если таких нет
+func (c *Consumer) offsetForTime(topic string, partition int32, t time.Time, newest int64) (int64, error) {
+	offset, err := c.client.GetOffset(topic, partition, t.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get offset of %s/%d at %s: %w", topic, partition, t.Format(time.RFC3339), err)
+	}
+	if offset < 0 {
+		return newest, nil
+	}
+	return offset, nil
+}
+
+func clampOffset(offset, oldest, newest int64) int64 {
+	if offset < oldest {
+		return oldest
+	}
+	if offset > newest {
+		return newest
+	}
+	return offset
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
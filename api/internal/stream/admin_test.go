@@ -0,0 +1,41 @@
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPauseGateBlocksUntilResume(t *testing.T) {
+	var gate pauseGate
+	if err := gate.wait(context.Background()); err != nil {
+		t.Fatalf("gate should be open: %v", err)
+	}
+
+	gate.pause()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := gate.wait(ctx); err == nil {
+		t.Fatal("paused gate should block until the context ends")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- gate.wait(context.Background()) }()
+	gate.resume()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("wait after resume: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("resume did not release waiters")
+	}
+}
+
+func TestClampOffset(t *testing.T) {
+	for _, tc := range []struct{ offset, want int64 }{{-3, 10}, {15, 15}, {99, 20}} {
+		if got := clampOffset(tc.offset, 10, 20); got != tc.want {
+			t.Fatalf("clamp(%d) = %d, want %d", tc.offset, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package stream
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// workflowChangesChannel — Redis pub/sub канал изменений workflows между репликами
+const workflowChangesChannel = "notiair:workflows:changed"
+
+// WorkflowChanges рассылает уведомления об изменении workflows подписчикам в процессе
+// и, если задан Redis, другим репликам: API и consumer могут работать в разных процессах.
+type WorkflowChanges struct {
+	redisStore *RedisStore
+
+	mu          sync.RWMutex
+	subscribers []func(workflowID string)
+}
+
+// NewWorkflowChanges создает рассылку; redisStore может быть nil — тогда только в процессе
+func NewWorkflowChanges(redisStore *RedisStore) *WorkflowChanges {
+	return &WorkflowChanges{redisStore: redisStore}
+}
+
+// Subscribe добавляет обработчик изменений
+func (w *WorkflowChanges) Subscribe(fn func(workflowID string)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// Notify сообщает об изменении workflow (совместим с workflow.ChangeFunc)
+func (w *WorkflowChanges) Notify(ctx context.Context, workflowID string) {
+	w.fanOut(workflowID)
+	if w.redisStore == nil {
+		return
+	}
+	// Своя реплика получит сообщение повторно — повторная инвалидация безвредна
+	if err := w.redisStore.client.Publish(ctx, workflowChangesChannel, workflowID).Err(); err != nil {
+		log.Printf("failed to publish workflow change %s: %v", workflowID, err)
+	}
+}
+
+// Run слушает изменения от других реплик до отмены ctx; без Redis сразу возвращается
+func (w *WorkflowChanges) Run(ctx context.Context) {
+	if w.redisStore == nil {
+		return
+	}
+	for {
+		w.listen(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(consumeRetryDelay):
+		}
+		// Пока подписки не было, изменения могли потеряться
+		w.fanOut("")
+	}
+}
+
+func (w *WorkflowChanges) listen(ctx context.Context) {
+	pubsub := w.redisStore.client.Subscribe(ctx, workflowChangesChannel)
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		if ctx.Err() == nil {
+			log.Printf("failed to subscribe to workflow changes: %v", err)
+		}
+		return
+	}
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			w.fanOut(msg.Payload)
+		}
+	}
+}
+
+func (w *WorkflowChanges) fanOut(workflowID string) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for _, fn := range w.subscribers {
+		fn(workflowID)
+	}
+}
@@ -2,10 +2,13 @@ package stream
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/IBM/sarama"
@@ -22,25 +25,38 @@ type Event struct {
 	Metadata   map[string]interface{} `json:"metadata"`
 }
 
-// TriggerConfig представляет конфигурацию Stream broker триггера
-type TriggerConfig struct {
-	Label       string   `json:"label"`
-	Variant     string   `json:"variant"`
-	EventTypes  []string `json:"eventTypes"`
-	Description string   `json:"description"`
-}
+const (
+	// Как часто перечитывать топики триггеров активных workflows
+	topicRefreshInterval = 30 * time.Second
+	// Пауза перед повторным подключением после ошибки consumer group
+	consumeRetryDelay = 5 * time.Second
+)
 
-// Consumer обрабатывает события из stream broker и запускает workflows
+// Consumer обрабатывает события из stream broker и запускает workflows.
+// Подписка — объединение топиков Stream broker триггеров активных workflows и topic по умолчанию.
 type Consumer struct {
-	brokers         []string
-	topic           string
-	groupID         string
-	workflowRepo    workflow.Repository
-	notificationSvc *services.NotificationService
-	consumer        sarama.ConsumerGroup
-	wg              sync.WaitGroup
-	hub             *Hub // WebSocket hub для отправки сообщений в интерфейс
-	redisStore      *RedisStore // Redis store для хранения сообщений
+	*processor  // обработка событий, общая для брокеров
+	brokers     []string
+	topic       string // топик по умолчанию (STREAM_TOPIC)
+	groupID     string
+	client      sarama.Client
+	groupMu     sync.Mutex
+	consumer    sarama.ConsumerGroup // пересоздается, если закрылась не через Stop (см. group)
+	wg          sync.WaitGroup
+	stopping    atomic.Bool
+	health      healthState
+	assigned    assignedPartitions // партиции текущей сессии с offset для отставания
+	deadLetters *DeadLetterQueue   // DLQ для неразобранных и необработанных сообщений
+	concurrency Concurrency
+	gate        pauseGate // пауза обработки (см. Pause/Resume)
+	held        pauseGate // реплика вышла из группы на время сдвига offset (см. ResetOffsets)
+	holdMu      sync.Mutex
+	holdTimer   *time.Timer  // возвращает в группу, если команда rejoin потерялась
+	offsets     groupOffsets // состояние и offset consumer group целиком
+	replays     *replayJobs  // состояние replay, запущенных в фоне
+	// replayCtx отменяется в Stop и прерывает идущие replay
+	replayCtx     context.Context
+	cancelReplays context.CancelFunc
 }
 
 // NewConsumer создает новый consumer для stream broker
@@ -52,6 +68,11 @@ func NewConsumer(
 	notificationSvc *services.NotificationService,
 	hub *Hub,
 	redisStore *RedisStore,
+	retry RetryPolicy,
+	deadLetters *DeadLetterQueue,
+	claims EventClaims,
+	concurrency Concurrency,
+	schemas EventSchemas,
 ) (*Consumer, error) {
 	config := sarama.NewConfig()
 	config.Version = sarama.V2_8_0_0
@@ -59,73 +80,201 @@ func NewConsumer(
 	config.Consumer.Offsets.Initial = sarama.OffsetOldest
 	config.Consumer.Return.Errors = true
 
-	consumerGroup, err := sarama.NewConsumerGroup(brokers, groupID, config)
+	client, err := sarama.NewClient(brokers, config)
 	if err != nil {
+		return nil, fmt.Errorf("failed to create stream client: %w", err)
+	}
+	consumerGroup, err := sarama.NewConsumerGroupFromClient(groupID, client)
+	if err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to create consumer group: %w", err)
 	}
 
+	replayCtx, cancelReplays := context.WithCancel(context.Background())
 	return &Consumer{
-		brokers:         brokers,
-		topic:           topic,
-		groupID:         groupID,
-		workflowRepo:    workflowRepo,
-		notificationSvc: notificationSvc,
-		consumer:        consumerGroup,
-		hub:             hub,
-		redisStore:      redisStore,
+		brokers:     brokers,
+		topic:       topic,
+		groupID:     groupID,
+		processor:   newProcessor(NewIndex(workflowRepo, workflow.BrokerKafka, topic), notificationSvc, hub, redisStore, retry, claims, schemas),
+		client:      client,
+		consumer:    consumerGroup,
+		deadLetters: deadLetters,
+		concurrency: concurrency,
+		offsets:     &kafkaGroupOffsets{client: client, groupID: groupID},
+		replays:     newReplayJobs(redisStore),
+
+		replayCtx:     replayCtx,
+		cancelReplays: cancelReplays,
 	}, nil
 }
 
-// Start запускает consumer в фоновом режиме
+// Start запускает consumer в фоновом режиме. Чтение работает под присмотром: после
+// ошибки сессии, паники обработчика или закрытия consumer group она перезапускается
+// с нарастающей паузой (restartBackoff), пока не вызван Stop или не завершен ctx.
 func (c *Consumer) Start(ctx context.Context) error {
 	c.wg.Add(1)
 	go func() {
 		defer c.wg.Done()
-		for {
-			select {
-			case <-ctx.Done():
+		var topics []string
+		for ctx.Err() == nil && !c.stopping.Load() {
+			// Пока реплика вышла из группы ради сдвига offset, в группу не возвращаемся
+			if err := c.held.wait(ctx); err != nil || c.stopping.Load() {
 				return
-			default:
-				handler := &consumerGroupHandler{
-					consumer:        c,
-					workflowRepo:    c.workflowRepo,
-					notificationSvc: c.notificationSvc,
-				}
+			}
+			topics = c.subscribedTopics(ctx, topics)
+			handler := &consumerGroupHandler{consumer: c}
 
-				if err := c.consumer.Consume(ctx, []string{c.topic}, handler); err != nil {
-					log.Printf("error from consumer: %v", err)
+			// Сессия перезапускается, когда меняется набор топиков
+			sessionCtx, cancel := context.WithCancel(ctx)
+			go c.watchTopics(sessionCtx, cancel, topics)
+
+			log.Printf("stream consumer subscribed to topics: %s", strings.Join(topics, ", "))
+			err := c.consume(sessionCtx, topics, handler)
+			cancel()
+			if ctx.Err() != nil || c.stopping.Load() {
+				return
+			}
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				// Группа закрылась не через Stop или покинута ради сдвига offset — без нового
+				// экземпляра чтение не продолжится
+				if err := c.held.wait(ctx); err != nil || c.stopping.Load() {
+					return
+				}
+				err = c.reopen()
+			}
+			if err != nil {
+				delay := c.health.fail(err)
+				log.Printf("error from consumer, restarting in %s: %v", delay, err)
+				select {
+				case <-ctx.Done():
 					return
+				case <-time.After(delay):
 				}
 			}
 		}
 	}()
 
-	// Обработка ошибок consumer
+	// Обработка ошибок consumer; после пересоздания группы читаем ошибки нового экземпляра
 	c.wg.Add(1)
 	go func() {
 		defer c.wg.Done()
-		for {
+		for ctx.Err() == nil && !c.stopping.Load() {
+			errs := c.group().Errors()
+			for open := true; open; {
+				select {
+				case <-ctx.Done():
+					return
+				case err, ok := <-errs:
+					open = ok
+					if err != nil {
+						c.health.observe(err)
+						log.Printf("consumer error: %v", err)
+					}
+				}
+			}
 			select {
 			case <-ctx.Done():
 				return
-			case err := <-c.consumer.Errors():
-				if err != nil {
-					log.Printf("consumer error: %v", err)
-				}
+			case <-time.After(time.Second):
 			}
 		}
 	}()
 
-	log.Printf("stream consumer started for topic: %s, group: %s", c.topic, c.groupID)
+	// Пауза и другие команды приходят и от других реплик
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.runControl(ctx)
+	}()
+
+	log.Printf("stream consumer started (default topic: %s, group: %s)", c.topic, c.groupID)
+	return nil
+}
+
+// consume проводит одну сессию consumer group; паника обработчика становится ошибкой
+func (c *Consumer) consume(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) (err error) {
+	defer recovered(&err)
+	return c.group().Consume(ctx, topics, handler)
+}
+
+// group возвращает текущий экземпляр consumer group
+func (c *Consumer) group() sarama.ConsumerGroup {
+	c.groupMu.Lock()
+	defer c.groupMu.Unlock()
+	return c.consumer
+}
+
+// reopen создает consumer group заново на том же клиенте
+func (c *Consumer) reopen() error {
+	group, err := sarama.NewConsumerGroupFromClient(c.groupID, c.client)
+	if err != nil {
+		return fmt.Errorf("failed to reopen consumer group: %w", err)
+	}
+	c.groupMu.Lock()
+	c.consumer = group
+	c.groupMu.Unlock()
+	log.Printf("stream consumer group %s reopened", c.groupID)
 	return nil
 }
 
+// Health возвращает состояние чтения, назначенные реплике партиции и их отставание
+func (c *Consumer) Health(context.Context) SourceHealth {
+	health := c.health.report(workflow.BrokerKafka)
+	if health.State == StateRunning && (c.gate.paused() || c.held.paused()) {
+		health.State = StatePaused
+	}
+	health.Partitions, health.Lag = c.assigned.lag()
+	return health
+}
+
+// subscribedTopics собирает топики активных workflows; при ошибке остается на прежней подписке
+func (c *Consumer) subscribedTopics(ctx context.Context, current []string) []string {
+	listCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	topics, err := c.index.Topics(listCtx)
+	if err != nil {
+		log.Printf("failed to list workflows for topic subscription: %v", err)
+		if len(current) > 0 {
+			return current
+		}
+		return []string{c.topic}
+	}
+	return topics
+}
+
+// watchTopics отменяет сессию consumer group, когда набор топиков меняется
+func (c *Consumer) watchTopics(ctx context.Context, cancel context.CancelFunc, topics []string) {
+	ticker := time.NewTicker(topicRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			next := c.subscribedTopics(ctx, topics)
+			if strings.Join(next, ",") != strings.Join(topics, ",") {
+				log.Printf("stream topics changed: %s -> %s", strings.Join(topics, ", "), strings.Join(next, ", "))
+				cancel()
+				return
+			}
+		}
+	}
+}
+
 // Stop останавливает consumer
 func (c *Consumer) Stop() error {
-	if err := c.consumer.Close(); err != nil {
+	c.stopping.Store(true)
+	c.held.resume()
+	c.cancelReplays()
+	defer c.health.set(StateStopped)
+	if err := c.group().Close(); err != nil {
 		return fmt.Errorf("failed to close consumer: %w", err)
 	}
 	c.wg.Wait()
+	if err := c.client.Close(); err != nil {
+		return fmt.Errorf("failed to close stream client: %w", err)
+	}
 	log.Println("stream consumer stopped")
 	return nil
 }
@@ -142,114 +291,107 @@ func GetRecentMessages(redisStore *RedisStore, eventTypes []string, limit int) (
 	return redisStore.GetRecentMessages(ctx, eventTypes, limit)
 }
 
-// GetRecentMessagesFromKafka получает последние N сообщений из топика, отфильтрованных по event_types (старый метод, оставлен для совместимости)
+// GetRecentMessagesFromKafka получает последние N сообщений из топика (со всех партиций),
+// отфильтрованных по event_types (старый метод, оставлен для совместимости)
 func GetRecentMessagesFromKafka(brokers []string, topic string, eventTypes []string, limit int) ([]Event, error) {
 	config := sarama.NewConfig()
 	config.Version = sarama.V2_8_0_0
 	config.Consumer.Return.Errors = true
 
-	consumer, err := sarama.NewConsumer(brokers, config)
+	client, err := sarama.NewClient(brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	defer client.Close()
+
+	consumer, err := sarama.NewConsumerFromClient(client)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create consumer: %w", err)
 	}
 	defer consumer.Close()
 
 	// Получаем партиции топика
-	partitions, err := consumer.Partitions(topic)
+	partitions, err := client.Partitions(topic)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get partitions: %w", err)
 	}
 
-	if len(partitions) == 0 {
-		return []Event{}, nil
-	}
-
-	// Используем первую партицию (обычно 0)
-	partitionID := partitions[0]
-
-	// Получаем последний offset
-	partitionConsumer, err := consumer.ConsumePartition(topic, partitionID, sarama.OffsetNewest)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create partition consumer: %w", err)
-	}
-
-	lastOffset := partitionConsumer.HighWaterMarkOffset()
-	partitionConsumer.Close()
-
-	// Читаем с конца, но ограничиваем количество сообщений для чтения
-	readLimit := limit * 10 // Читаем больше, чтобы отфильтровать
-	startOffset := lastOffset - int64(readLimit)
-	if startOffset < 0 {
-		startOffset = 0
-	}
-
-	// Создаем новый consumer для чтения с нужного offset
-	partitionConsumer, err = consumer.ConsumePartition(topic, partitionID, startOffset)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create partition consumer: %w", err)
-	}
-	defer partitionConsumer.Close()
-
-	var events []Event
-	eventTypeMap := make(map[string]bool)
-	for _, et := range eventTypes {
-		eventTypeMap[et] = true
-	}
-
-	// Читаем сообщения до последнего offset
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	messageChan := partitionConsumer.Messages()
-	for {
-		select {
-		case <-ctx.Done():
-			goto done
-		case message, ok := <-messageChan:
-			if !ok || message == nil {
-				goto done
-			}
+	type timedEvent struct {
+		event Event
+		at    time.Time
+	}
+	var found []timedEvent
+	for _, partition := range partitions {
+		oldest, err := client.GetOffset(topic, partition, sarama.OffsetOldest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get offset: %w", err)
+		}
+		newest, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get offset: %w", err)
+		}
 
-			if message.Offset >= lastOffset {
-				goto done
-			}
+		// Читаем с конца, но больше limit, чтобы было что отфильтровать
+		start := newest - int64(limit*10)
+		if start < oldest {
+			start = oldest
+		}
 
-			var event Event
-			if err := json.Unmarshal(message.Value, &event); err != nil {
-				continue
+		var events []timedEvent
+		err = readPartition(ctx, consumer, topic, partition, start, newest, func(message *sarama.ConsumerMessage) bool {
+			event, err := DecodeMessage(message)
+			if err != nil {
+				return true
 			}
-
 			// Фильтруем по event_types, если они указаны
-			if len(eventTypes) > 0 && !eventTypeMap[event.EventType] {
-				continue
-			}
-
-			events = append(events, event)
-
-			// Ограничиваем количество
-			if len(events) >= limit {
-				goto done
+			if len(eventTypes) > 0 && !matchesAnyEventType(eventTypes, event.EventType) {
+				return true
 			}
+			events = append(events, timedEvent{event: event, at: message.Timestamp})
+			return true
+		})
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
 		}
+		if len(events) > limit {
+			events = events[len(events)-limit:]
+		}
+		found = append(found, events...)
 	}
 
-done:
-	// Возвращаем в обратном порядке (новые первыми)
-	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
-		events[i], events[j] = events[j], events[i]
+	// Возвращаем новые первыми
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].at.After(found[j].at)
+	})
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	result := make([]Event, len(found))
+	for i, item := range found {
+		result[i] = item.event
 	}
+	return result, nil
+}
 
-	return events, nil
+func matchesAnyEventType(patterns []string, eventType string) bool {
+	for _, pattern := range patterns {
+		if workflow.MatchEventType(pattern, eventType) {
+			return true
+		}
+	}
+	return false
 }
 
 // consumerGroupHandler реализует sarama.ConsumerGroupHandler
 type consumerGroupHandler struct {
-	consumer        *Consumer
-	workflowRepo    workflow.Repository
-	notificationSvc *services.NotificationService
+	consumer *Consumer
 }
 
 func (h *consumerGroupHandler) Setup(sarama.ConsumerGroupSession) error {
+	h.consumer.health.set(StateRunning)
 	return nil
 }
 
@@ -257,134 +399,135 @@ func (h *consumerGroupHandler) Cleanup(sarama.ConsumerGroupSession) error {
 	return nil
 }
 
+// ConsumeClaim раздает сообщения партиции воркерам (см. Concurrency) и коммитит offset
+// только непрерывного префикса обработанных сообщений.
 func (h *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	ctx := session.Context()
+	if h.consumer.gate.paused() {
+		// Partition consumer новой сессии создается непоставленным на паузу
+		h.consumer.group().PauseAll()
+	}
+	concurrency := h.consumer.concurrency
+	if concurrency.Workers < 1 {
+		concurrency.Workers = 1
+	}
+
+	partition := h.consumer.assigned.add(claim, h.consumer.resolveOffset(claim))
+	defer h.consumer.assigned.remove(partition)
+
+	tracker := &offsetTracker{mark: func(message *sarama.ConsumerMessage) {
+		session.MarkMessage(message, "")
+		partition.committed.Store(message.Offset + 1)
+	}}
+	picker := &workerPicker{workers: concurrency.Workers}
+	queues := make([]chan claimJob, concurrency.Workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan claimJob, 1)
+		wg.Add(1)
+		go func(jobs <-chan claimJob) {
+			defer wg.Done()
+			for job := range jobs {
+				// После завершения сессии сообщения не обрабатываем и не коммитим
+				if ctx.Err() != nil || !h.handleMessage(ctx, job) {
+					continue
+				}
+				tracker.complete(job.tracked)
+			}
+		}(queues[i])
+	}
+	defer func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+		wg.Wait()
+	}()
+
 	for {
 		select {
-		case <-session.Context().Done():
+		case <-ctx.Done():
 			return nil
-		case message := <-claim.Messages():
-			if message == nil {
-				continue
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
 			}
-
-			var event Event
-			if err := json.Unmarshal(message.Value, &event); err != nil {
-				log.Printf("failed to unmarshal event: %v", err)
-				session.MarkMessage(message, "")
-				continue
+			if err := h.consumer.gate.wait(ctx); err != nil {
+				return nil
 			}
 
-			log.Printf("received event: %s (type: %s)", event.EventID, event.EventType)
-
-			// Сохраняем сообщение в Redis
-			if h.consumer.redisStore != nil {
-				if err := h.consumer.redisStore.SaveMessage(session.Context(), event); err != nil {
-					log.Printf("failed to save message to redis: %v", err)
-				}
+			job := claimJob{tracked: tracker.add(message)}
+			if event, err := DecodeMessage(message); err != nil {
+				job.err = err
+			} else {
+				job.event = &event
 			}
 
-			// Отправляем событие в WebSocket hub для интерфейса
-			if h.consumer.hub != nil {
-				h.consumer.hub.Broadcast(event)
-			}
-
-			// Обрабатываем событие
-			if err := h.processEvent(session.Context(), event); err != nil {
-				log.Printf("failed to process event %s: %v", event.EventID, err)
-				// Не коммитим offset при ошибке, чтобы повторить обработку
-				continue
+			select {
+			case queues[picker.pick(concurrency.orderingKey(message, job.event))] <- job:
+			case <-ctx.Done():
+				return nil
 			}
-
-			session.MarkMessage(message, "")
 		}
 	}
 }
 
-// processEvent обрабатывает событие и запускает соответствующие workflows
-func (h *consumerGroupHandler) processEvent(ctx context.Context, event Event) error {
-	// Получаем все активные workflows
-	workflows, err := h.workflowRepo.List(ctx)
+// resolveOffset возвращает offset, с которого партиция читается в сессии; у группы без
+// коммита InitialOffset — OffsetOldest, и он разрешается в начало партиции
+func (c *Consumer) resolveOffset(claim sarama.ConsumerGroupClaim) int64 {
+	offset := claim.InitialOffset()
+	if offset >= 0 || c.client == nil {
+		return offset
+	}
+	resolved, err := c.client.GetOffset(claim.Topic(), claim.Partition(), offset)
 	if err != nil {
-		return fmt.Errorf("failed to list workflows: %w", err)
+		log.Printf("failed to resolve initial offset of %s/%d: %v", claim.Topic(), claim.Partition(), err)
+		return offset
 	}
+	return resolved
+}
 
-	// Находим workflows с Stream broker триггером, у которых event_type совпадает
-	for _, wf := range workflows {
-		if !wf.IsActive {
-			continue
-		}
-
-		// Ищем Stream broker триггер в workflow
-		var streamBrokerTrigger *workflow.Node
-		var triggerConfig TriggerConfig
-		for i := range wf.Nodes {
-			node := &wf.Nodes[i]
-			if node.Type != workflow.NodeTypeTrigger {
-				continue
-			}
-
-			// Парсим конфигурацию триггера
-			configBytes, err := json.Marshal(node.Config)
-			if err != nil {
-				continue
-			}
-
-			var cfg TriggerConfig
-			if err := json.Unmarshal(configBytes, &cfg); err != nil {
-				continue
-			}
-
-			// Проверяем, что это Stream broker триггер
-			if cfg.Label == "Stream broker" {
-				streamBrokerTrigger = node
-				triggerConfig = cfg
-				break
-			}
-		}
-
-		if streamBrokerTrigger == nil {
-			continue
-		}
-
-		// Проверяем, подходит ли event_type
-		eventTypeMatched := false
-		for _, et := range triggerConfig.EventTypes {
-			if et == event.EventType {
-				eventTypeMatched = true
-				break
-			}
-		}
-
-		if !eventTypeMatched {
-			continue
-		}
+// handleMessage обрабатывает одно сообщение и сообщает, можно ли коммитить его offset
+func (h *consumerGroupHandler) handleMessage(ctx context.Context, job claimJob) bool {
+	message := job.tracked.message
+	if job.err != nil {
+		log.Printf("failed to unmarshal event (topic: %s, partition: %d, offset: %d): %v", message.Topic, message.Partition, message.Offset, job.err)
+		return h.consumer.deadLetter(ctx, message, StageParse, 0, job.err)
+	}
+	event := *job.event
+
+	// Обрабатываем событие с повторами; после последней неудачи оно уходит в DLQ,
+	// чтобы не блокировать партицию
+	done, attempts, err := h.consumer.handle(ctx, message.Topic, event)
+	if !done {
+		// Сессия завершается: offset не коммитим, сообщение будет прочитано снова
+		return false
+	}
+	if err == nil {
+		return true
+	}
+	log.Printf("failed to process event %s after %d attempts: %v", event.EventID, attempts, err)
+	return h.consumer.deadLetter(ctx, message, StageProcess, attempts, err)
+}
 
-		// Преобразуем событие в payload для workflow
-		payload := map[string]interface{}{
-			"event_id":    event.EventID,
-			"event_type":  event.EventType,
-			"occurred_at": event.OccurredAt,
-			"context":     event.Context,
-			"metadata":    event.Metadata,
+// deadLetter отправляет сообщение в DLQ, повторяя публикацию, пока брокер недоступен.
+// Возвращает false, если сессия завершилась раньше: тогда offset коммитить нельзя.
+func (c *Consumer) deadLetter(ctx context.Context, message *sarama.ConsumerMessage, stage string, attempts int, cause error) bool {
+	if c.deadLetters == nil {
+		log.Printf("dropping message %s/%d/%d: dead-letter queue is not configured", message.Topic, message.Partition, message.Offset)
+		return true
+	}
+	for attempt := 1; ; attempt++ {
+		err := c.deadLetters.Publish(message, stage, attempts, cause)
+		if err == nil {
+			log.Printf("message %s/%d/%d sent to dead-letter topic %s (stage: %s)", message.Topic, message.Partition, message.Offset, c.deadLetters.Topic(), stage)
+			return true
 		}
-
-		// Запускаем workflow через NotificationService
-		// TODO: определить TemplateID и Variables из workflow
-		err = h.notificationSvc.Dispatch(ctx, services.DispatchInput{
-			WorkflowID: wf.ID,
-			TemplateID: "", // Будет определено из workflow
-			Variables:  make(map[string]string),
-			Payload:    payload,
-		})
-
-		if err != nil {
-			log.Printf("failed to dispatch workflow %s for event %s: %v", wf.ID, event.EventID, err)
-			continue
+		delay := c.retry.Delay(attempt)
+		log.Printf("failed to publish message %s/%d/%d to dead-letter topic, retrying in %s: %v", message.Topic, message.Partition, message.Offset, delay, err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(delay):
 		}
-
-		log.Printf("dispatched workflow %s for event %s (type: %s)", wf.ID, event.EventID, event.EventType)
 	}
-
-	return nil
 }
-
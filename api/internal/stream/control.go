@@ -0,0 +1,141 @@
+package stream
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	// consumerControlChannel — Redis pub/sub канал команд Kafka consumer между репликами
+	consumerControlChannel = "notiair:stream:consumer"
+	// consumerPausedKey хранит паузу, чтобы ее подхватили реплики, запущенные позже
+	consumerPausedKey = "stream:consumer:paused"
+	// maxGroupHold — дольше реплика вне группы не остается, даже если rejoin не пришел
+	maxGroupHold = 2 * groupLeaveTimeout
+)
+
+// Команды consumer, которые рассылаются всем репликам
+const (
+	commandPause  = "pause"
+	commandResume = "resume"
+	// commandLeave выводит реплику из consumer group на время сдвига offset
+	commandLeave = "leave"
+	// commandRejoin возвращает реплику в группу после commandLeave
+	commandRejoin = "rejoin"
+)
+
+// broadcast выполняет команду на своей реплике и, если задан Redis, рассылает ее
+// остальным. Своя реплика получит команду повторно — команды идемпотентны.
+func (c *Consumer) broadcast(ctx context.Context, command string) error {
+	c.apply(command)
+	if c.redisStore == nil {
+		return nil
+	}
+	return c.redisStore.client.Publish(ctx, consumerControlChannel, command).Err()
+}
+
+// apply выполняет команду на своей реплике
+func (c *Consumer) apply(command string) {
+	switch command {
+	case commandPause:
+		c.pauseLocal()
+	case commandResume:
+		c.resumeLocal()
+	case commandLeave:
+		c.leaveLocal()
+	case commandRejoin:
+		c.rejoinLocal()
+	default:
+		log.Printf("unknown stream consumer command %q", command)
+	}
+}
+
+// runControl слушает команды других реплик до отмены ctx; без Redis сразу возвращается
+func (c *Consumer) runControl(ctx context.Context) {
+	if c.redisStore == nil {
+		return
+	}
+	for {
+		c.listenControl(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(consumeRetryDelay):
+		}
+	}
+}
+
+func (c *Consumer) listenControl(ctx context.Context) {
+	pubsub := c.redisStore.client.Subscribe(ctx, consumerControlChannel)
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		if ctx.Err() == nil {
+			log.Printf("failed to subscribe to stream consumer commands: %v", err)
+		}
+		return
+	}
+
+	// Пока подписки не было, пауза могла смениться — сверяемся с сохраненной
+	c.syncPause(ctx)
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			c.apply(msg.Payload)
+		}
+	}
+}
+
+// syncPause выставляет паузу по сохраненному в Redis состоянию
+func (c *Consumer) syncPause(ctx context.Context) {
+	n, err := c.redisStore.client.Exists(ctx, consumerPausedKey).Result()
+	if err != nil {
+		log.Printf("failed to read stream consumer pause: %v", err)
+		return
+	}
+	if n > 0 {
+		c.pauseLocal()
+	} else {
+		c.resumeLocal()
+	}
+}
+
+// leaveLocal закрывает consumer group реплики: текущая сессия коммитит обработанное и
+// выходит из группы, а новая не начнется до rejoinLocal
+func (c *Consumer) leaveLocal() {
+	if c.stopping.Load() || !c.held.pause() {
+		return
+	}
+	c.holdMu.Lock()
+	c.holdTimer = time.AfterFunc(maxGroupHold, func() {
+		log.Printf("stream consumer did not receive rejoin in %s", maxGroupHold)
+		c.rejoinLocal()
+	})
+	c.holdMu.Unlock()
+
+	if err := c.group().Close(); err != nil {
+		log.Printf("failed to leave consumer group %s: %v", c.groupID, err)
+	}
+	log.Printf("stream consumer left group %s", c.groupID)
+}
+
+func (c *Consumer) rejoinLocal() {
+	c.holdMu.Lock()
+	if c.holdTimer != nil {
+		c.holdTimer.Stop()
+		c.holdTimer = nil
+	}
+	c.holdMu.Unlock()
+
+	if c.held.resume() {
+		log.Printf("stream consumer rejoins group %s", c.groupID)
+	}
+}
@@ -0,0 +1,149 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeGroup — consumer group без брокера для проверки команд
+type fakeGroup struct {
+	sarama.ConsumerGroup
+	closed atomic.Bool
+}
+
+func (g *fakeGroup) PauseAll()  {}
+func (g *fakeGroup) ResumeAll() {}
+func (g *fakeGroup) Close() error {
+	g.closed.Store(true)
+	return nil
+}
+
+// fakeGroupOffsets считает участниками группы реплики, которые из нее не вышли
+type fakeGroupOffsets struct {
+	replicas  []*Consumer
+	mu        sync.Mutex
+	committed map[int32]int64
+}
+
+func (f *fakeGroupOffsets) Members() (int, error) {
+	members := 0
+	for _, replica := range f.replicas {
+		if !replica.group().(*fakeGroup).closed.Load() {
+			members++
+		}
+	}
+	return members, nil
+}
+
+func (f *fakeGroupOffsets) Commit(topic string, offsets map[int32]int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.committed = offsets
+	return nil
+}
+
+func newControlledConsumer(store *RedisStore) *Consumer {
+	return &Consumer{
+		processor: &processor{redisStore: store},
+		consumer:  &fakeGroup{},
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPauseIsBroadcastToOtherReplicas(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := &RedisStore{client: redis.NewClient(&redis.Options{Addr: server.Addr()})}
+	t.Cleanup(func() { store.Close() })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	local, remote := newControlledConsumer(store), newControlledConsumer(store)
+	go remote.runControl(ctx)
+	waitFor(t, "subscription", func() bool { return server.PubSubNumSub(consumerControlChannel)[consumerControlChannel] == 1 })
+
+	if err := local.Pause(ctx); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if !local.gate.paused() {
+		t.Fatal("local replica is not paused")
+	}
+	waitFor(t, "remote pause", remote.gate.paused)
+
+	// Реплика, запущенная во время паузы, стартует на паузе
+	late := newControlledConsumer(store)
+	go late.runControl(ctx)
+	waitFor(t, "late replica pause", late.gate.paused)
+
+	if err := local.Resume(ctx); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	waitFor(t, "remote resume", func() bool { return !remote.gate.paused() && !late.gate.paused() })
+}
+
+func TestCommitGroupOffsetsStopsEveryReplica(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := &RedisStore{client: redis.NewClient(&redis.Options{Addr: server.Addr()})}
+	t.Cleanup(func() { store.Close() })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	local, remote := newControlledConsumer(store), newControlledConsumer(store)
+	offsets := &fakeGroupOffsets{replicas: []*Consumer{local, remote}}
+	local.offsets = offsets
+	go remote.runControl(ctx)
+	waitFor(t, "subscription", func() bool { return server.PubSubNumSub(consumerControlChannel)[consumerControlChannel] == 1 })
+
+	if err := local.commitGroupOffsets(ctx, "orders", map[int32]int64{0: 5, 1: 7}); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if want := map[int32]int64{0: 5, 1: 7}; !reflect.DeepEqual(offsets.committed, want) {
+		t.Fatalf("committed %v, want %v", offsets.committed, want)
+	}
+	if !remote.group().(*fakeGroup).closed.Load() {
+		t.Fatal("remote replica did not leave the group")
+	}
+	if local.held.paused() {
+		t.Fatal("local replica did not rejoin")
+	}
+	waitFor(t, "remote rejoin", func() bool { return !remote.held.paused() })
+}
+
+func TestCommitGroupOffsetsFailsWhileMembersRemain(t *testing.T) {
+	local := newControlledConsumer(nil)
+	// Реплика без Redis команду не получит и из группы не выйдет
+	remote := newControlledConsumer(nil)
+	offsets := &fakeGroupOffsets{replicas: []*Consumer{local, remote}}
+	local.offsets = offsets
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := local.commitGroupOffsets(ctx, "orders", map[int32]int64{0: 5})
+	if !errors.Is(err, ErrGroupActive) {
+		t.Fatalf("expected ErrGroupActive, got %v", err)
+	}
+	if offsets.committed != nil {
+		t.Fatalf("offsets committed while the group was active: %v", offsets.committed)
+	}
+	if local.held.paused() {
+		t.Fatal("local replica did not rejoin after the failure")
+	}
+}
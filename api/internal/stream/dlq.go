@@ -0,0 +1,334 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// Этапы, на которых сообщение попало в DLQ
+const (
+	StageParse   = "parse"   // сообщение не разобрано как Event
+	StageProcess = "process" // workflows не запустились после всех повторов
+)
+
+// Заголовки с метаданными ошибки, которые добавляются к сообщению в DLQ
+const (
+	headerPrefix            = "notiair-"
+	headerError             = headerPrefix + "error"
+	headerStage             = headerPrefix + "stage"
+	headerAttempts          = headerPrefix + "attempts"
+	headerFailedAt          = headerPrefix + "failed-at"
+	headerOriginalTopic     = headerPrefix + "original-topic"
+	headerOriginalPartition = headerPrefix + "original-partition"
+	headerOriginalOffset    = headerPrefix + "original-offset"
+	headerReplayedFrom      = headerPrefix + "replayed-from"
+)
+
+// ErrDeadLetterNotFound — в DLQ нет сообщения с таким partition/offset
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// dlqReadTimeout ограничивает чтение партиции DLQ: контекст HTTP-запроса не отменяется
+// при отключении клиента, а offset, который не доставляется, иначе держал бы запрос вечно
+const dlqReadTimeout = 10 * time.Second
+
+// DeadLetter — сообщение из DLQ: исходные ключ и тело плюс метаданные ошибки
+type DeadLetter struct {
+	ID                string    `json:"id"`
+	Partition         int32     `json:"partition"`
+	Offset            int64     `json:"offset"`
+	Topic             string    `json:"topic"`
+	OriginalPartition int32     `json:"originalPartition"`
+	OriginalOffset    int64     `json:"originalOffset"`
+	Key               string    `json:"key,omitempty"`
+	Payload           string    `json:"payload"`
+	Error             string    `json:"error"`
+	Stage             string    `json:"stage"`
+	Attempts          int       `json:"attempts"`
+	FailedAt          time.Time `json:"failedAt"`
+}
+
+// RetryPolicy — повторы обработки события до отправки в DLQ
+type RetryPolicy struct {
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Delay возвращает паузу перед повтором attempt (с 1): Backoff, удваиваемый до MaxBackoff
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	return delay
+}
+
+// DeadLetterQueue публикует необработанные сообщения в DLQ-топик, читает и переотправляет их
+type DeadLetterQueue struct {
+	topic    string
+	client   sarama.Client
+	producer sarama.SyncProducer
+}
+
+// NewDeadLetterQueue подключается к брокерам для работы с DLQ-топиком
+func NewDeadLetterQueue(brokers []string, topic string) (*DeadLetterQueue, error) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V2_8_0_0
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Return.Successes = true
+	config.Consumer.Return.Errors = true
+
+	client, err := sarama.NewClient(brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dlq client: %w", err)
+	}
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to create dlq producer: %w", err)
+	}
+	return &DeadLetterQueue{topic: topic, client: client, producer: producer}, nil
+}
+
+// Topic возвращает имя DLQ-топика
+func (q *DeadLetterQueue) Topic() string {
+	return q.topic
+}
+
+// Publish отправляет исходное сообщение в DLQ с заголовками об ошибке
+func (q *DeadLetterQueue) Publish(message *sarama.ConsumerMessage, stage string, attempts int, cause error) error {
+	headers := append(copyHeaders(message.Headers), dlqHeaders(message, stage, attempts, cause, time.Now().UTC())...)
+
+	_, _, err := q.producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   q.topic,
+		Key:     bytesEncoder(message.Key),
+		Value:   sarama.ByteEncoder(message.Value),
+		Headers: headers,
+	})
+	return err
+}
+
+// List возвращает последние limit сообщений DLQ со всех партиций, новые первыми
+func (q *DeadLetterQueue) List(ctx context.Context, limit int) ([]DeadLetter, error) {
+	partitions, err := q.client.Partitions(q.topic)
+	if err != nil {
+		if errors.Is(err, sarama.ErrUnknownTopicOrPartition) {
+			return []DeadLetter{}, nil
+		}
+		return nil, fmt.Errorf("failed to get dlq partitions: %w", err)
+	}
+
+	consumer, err := sarama.NewConsumerFromClient(q.client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dlq consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	letters := []DeadLetter{}
+	for _, partition := range partitions {
+		oldest, newest, err := q.offsets(partition)
+		if err != nil {
+			return nil, err
+		}
+		from := newest - int64(limit)
+		if from < oldest {
+			from = oldest
+		}
+		readCtx, cancel := context.WithTimeout(ctx, dlqReadTimeout)
+		err = readPartition(readCtx, consumer, q.topic, partition, from, newest, func(message *sarama.ConsumerMessage) bool {
+			letters = append(letters, deadLetterFromMessage(message))
+			return true
+		})
+		cancel()
+		// Не дочитанная за dlqReadTimeout партиция отдается тем, что успели прочитать
+		if err != nil && !(errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil) {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(letters, func(i, j int) bool {
+		return letters[i].FailedAt.After(letters[j].FailedAt)
+	})
+	if len(letters) > limit {
+		letters = letters[:limit]
+	}
+	return letters, nil
+}
+
+// Replay переотправляет сообщение DLQ в исходный топик, где его снова обработает consumer
+func (q *DeadLetterQueue) Replay(ctx context.Context, partition int32, offset int64) (DeadLetter, error) {
+	oldest, newest, err := q.offsets(partition)
+	if errors.Is(err, sarama.ErrUnknownTopicOrPartition) {
+		return DeadLetter{}, ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return DeadLetter{}, err
+	}
+	if offset < oldest || offset >= newest {
+		return DeadLetter{}, ErrDeadLetterNotFound
+	}
+
+	consumer, err := sarama.NewConsumerFromClient(q.client)
+	if err != nil {
+		return DeadLetter{}, fmt.Errorf("failed to create dlq consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	readCtx, cancel := context.WithTimeout(ctx, dlqReadTimeout)
+	defer cancel()
+	var found *sarama.ConsumerMessage
+	err = readPartition(readCtx, consumer, q.topic, partition, offset, offset+1, func(message *sarama.ConsumerMessage) bool {
+		if message.Offset == offset {
+			found = message
+		}
+		return false
+	})
+	// Offset, который не доставился за dlqReadTimeout, — не сообщение (control record)
+	if err != nil && !(errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil) {
+		return DeadLetter{}, err
+	}
+	if found == nil {
+		return DeadLetter{}, ErrDeadLetterNotFound
+	}
+
+	letter := deadLetterFromMessage(found)
+	if letter.Topic == "" {
+		return DeadLetter{}, fmt.Errorf("dead letter %s has no original topic", letter.ID)
+	}
+	var headers []sarama.RecordHeader
+	for _, h := range copyHeaders(found.Headers) {
+		if !strings.HasPrefix(string(h.Key), headerPrefix) {
+			headers = append(headers, h)
+		}
+	}
+	headers = append(headers, sarama.RecordHeader{Key: []byte(headerReplayedFrom), Value: []byte(letter.ID)})
+
+	_, _, err = q.producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   letter.Topic,
+		Key:     bytesEncoder(found.Key),
+		Value:   sarama.ByteEncoder(found.Value),
+		Headers: headers,
+	})
+	if err != nil {
+		return DeadLetter{}, fmt.Errorf("failed to replay dead letter %s: %w", letter.ID, err)
+	}
+	return letter, nil
+}
+
+// Close закрывает producer и клиент
+func (q *DeadLetterQueue) Close() error {
+	if err := q.producer.Close(); err != nil {
+		return err
+	}
+	return q.client.Close()
+}
+
+func (q *DeadLetterQueue) offsets(partition int32) (int64, int64, error) {
+	oldest, err := q.client.GetOffset(q.topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get dlq offset: %w", err)
+	}
+	newest, err := q.client.GetOffset(q.topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get dlq offset: %w", err)
+	}
+	return oldest, newest, nil
+}
+
+func deadLetterFromMessage(message *sarama.ConsumerMessage) DeadLetter {
+	letter := DeadLetter{
+		ID:        DeadLetterID(message.Partition, message.Offset),
+		Partition: message.Partition,
+		Offset:    message.Offset,
+		Key:       string(message.Key),
+		Payload:   string(message.Value),
+		FailedAt:  message.Timestamp,
+	}
+	for _, h := range message.Headers {
+		if h == nil {
+			continue
+		}
+		value := string(h.Value)
+		switch string(h.Key) {
+		case headerError:
+			letter.Error = value
+		case headerStage:
+			letter.Stage = value
+		case headerAttempts:
+			letter.Attempts, _ = strconv.Atoi(value)
+		case headerFailedAt:
+			if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				letter.FailedAt = t
+			}
+		case headerOriginalTopic:
+			letter.Topic = value
+		case headerOriginalPartition:
+			p, _ := strconv.ParseInt(value, 10, 32)
+			letter.OriginalPartition = int32(p)
+		case headerOriginalOffset:
+			letter.OriginalOffset, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+	return letter
+}
+
+// DeadLetterID — идентификатор сообщения DLQ вида "<partition>-<offset>"
+func DeadLetterID(partition int32, offset int64) string {
+	return fmt.Sprintf("%d-%d", partition, offset)
+}
+
+func dlqHeaders(message *sarama.ConsumerMessage, stage string, attempts int, cause error, failedAt time.Time) []sarama.RecordHeader {
+	return []sarama.RecordHeader{
+		{Key: []byte(headerError), Value: []byte(cause.Error())},
+		{Key: []byte(headerStage), Value: []byte(stage)},
+		{Key: []byte(headerAttempts), Value: []byte(strconv.Itoa(attempts))},
+		{Key: []byte(headerFailedAt), Value: []byte(failedAt.Format(time.RFC3339Nano))},
+		{Key: []byte(headerOriginalTopic), Value: []byte(message.Topic)},
+		{Key: []byte(headerOriginalPartition), Value: []byte(strconv.Itoa(int(message.Partition)))},
+		{Key: []byte(headerOriginalOffset), Value: []byte(strconv.FormatInt(message.Offset, 10))},
+	}
+}
+
+// ParseDeadLetterID разбирает идентификатор, возвращенный DeadLetterID
+func ParseDeadLetterID(id string) (int32, int64, error) {
+	partitionStr, offsetStr, ok := strings.Cut(id, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid dead letter id %q", id)
+	}
+	partition, err := strconv.ParseInt(partitionStr, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid dead letter id %q", id)
+	}
+	offset, err := strconv.ParseInt(offsetStr, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, 0, fmt.Errorf("invalid dead letter id %q", id)
+	}
+	return int32(partition), offset, nil
+}
+
+func copyHeaders(headers []*sarama.RecordHeader) []sarama.RecordHeader {
+	out := make([]sarama.RecordHeader, 0, len(headers)+8)
+	for _, h := range headers {
+		if h != nil {
+			out = append(out, *h)
+		}
+	}
+	return out
+}
+
+func bytesEncoder(b []byte) sarama.Encoder {
+	if b == nil {
+		return nil
+	}
+	return sarama.ByteEncoder(b)
+}
@@ -0,0 +1,53 @@
+package stream
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+func TestRetryPolicyDelayDoublesUpToMax(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 5, Backoff: 100 * time.Millisecond, MaxBackoff: 350 * time.Millisecond}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 350 * time.Millisecond, 350 * time.Millisecond}
+	for i, w := range want {
+		if got := policy.Delay(i + 1); got != w {
+			t.Fatalf("attempt %d: delay %s, want %s", i+1, got, w)
+		}
+	}
+}
+
+func TestParseDeadLetterID(t *testing.T) {
+	partition, offset, err := ParseDeadLetterID(DeadLetterID(3, 1042))
+	if err != nil || partition != 3 || offset != 1042 {
+		t.Fatalf("got %d/%d, %v", partition, offset, err)
+	}
+	for _, id := range []string{"", "3", "x-1", "1-y", "1--2"} {
+		if _, _, err := ParseDeadLetterID(id); err == nil {
+			t.Fatalf("expected error for %q", id)
+		}
+	}
+}
+
+func TestDeadLetterFromMessageReadsHeaders(t *testing.T) {
+	failedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	original := &sarama.ConsumerMessage{Topic: "orders", Partition: 2, Offset: 7, Key: []byte("order-1"), Value: []byte("{bad")}
+
+	headers := []*sarama.RecordHeader{}
+	for _, h := range dlqHeaders(original, StageParse, 0, errors.New("unexpected EOF"), failedAt) {
+		h := h
+		headers = append(headers, &h)
+	}
+	letter := deadLetterFromMessage(&sarama.ConsumerMessage{Partition: 1, Offset: 5, Key: original.Key, Value: original.Value, Headers: headers})
+
+	want := DeadLetter{
+		ID: "1-5", Partition: 1, Offset: 5,
+		Topic: "orders", OriginalPartition: 2, OriginalOffset: 7,
+		Key: "order-1", Payload: "{bad",
+		Error: "unexpected EOF", Stage: StageParse, Attempts: 0, FailedAt: failedAt,
+	}
+	if letter != want {
+		t.Fatalf("got %+v, want %+v", letter, want)
+	}
+}
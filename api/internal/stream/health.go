@@ -0,0 +1,211 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// Состояния чтения consumer
+const (
+	StateStarting   = "starting"   // еще не читал брокер
+	StateRunning    = "running"    // читает брокер
+	StatePaused     = "paused"     // читает, но обработка на паузе (Pause)
+	StateRestarting = "restarting" // чтение упало, ждет перезапуска
+	StateStopped    = "stopped"    // остановлен через Stop
+)
+
+// restartBackoff — пауза перед перезапуском чтения после n-й неудачи подряд
+var restartBackoff = RetryPolicy{Backoff: time.Second, MaxBackoff: time.Minute}
+
+// PartitionLag — отставание обработки партиции, назначенной этой реплике
+type PartitionLag struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	// Committed — offset, с которого группа продолжит чтение (после обработанного префикса)
+	Committed     int64 `json:"committed"`
+	HighWaterMark int64 `json:"highWaterMark"`
+	Lag           int64 `json:"lag"`
+}
+
+// SourceHealth — состояние consumer одного брокера для health и readiness
+type SourceHealth struct {
+	Broker      string         `json:"broker"`
+	State       string         `json:"state"`
+	Since       time.Time      `json:"since"`
+	Restarts    int64          `json:"restarts"`
+	LastError   string         `json:"lastError,omitempty"`
+	LastErrorAt *time.Time     `json:"lastErrorAt,omitempty"`
+	Partitions  []PartitionLag `json:"partitions,omitempty"` // только Kafka
+	Lag         int64          `json:"lag"`                  // сумма по партициям или сообщения в очереди брокера
+}
+
+// Up сообщает, читает ли consumer брокер
+func (h SourceHealth) Up() bool {
+	return h.State == StateRunning || h.State == StatePaused
+}
+
+// Sources — consumers всех брокеров процесса
+type Sources []Source
+
+// Health возвращает состояние каждого consumer
+func (s Sources) Health(ctx context.Context) []SourceHealth {
+	health := make([]SourceHealth, 0, len(s))
+	for _, source := range s {
+		health = append(health, source.Health(ctx))
+	}
+	return health
+}
+
+// Ready сообщает, читают ли брокеры все consumers
+func Ready(health []SourceHealth) bool {
+	for _, h := range health {
+		if !h.Up() {
+			return false
+		}
+	}
+	return true
+}
+
+// recovered превращает панику при чтении в ошибку, чтобы consumer перезапустил чтение;
+// вызывается через defer в функции с именованным результатом err
+func recovered(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("consumer panicked: %v", r)
+	}
+}
+
+// healthState учитывает состояние чтения consumer: смену состояний, ошибки и перезапуски
+type healthState struct {
+	mu        sync.Mutex
+	state     string
+	since     time.Time
+	restarts  int64
+	failures  int // неудач подряд; сбрасывается, когда чтение снова работает
+	lastErr   string
+	lastErrAt time.Time
+}
+
+// set переводит consumer в state; переход в StateRunning сбрасывает паузу перезапуска
+func (h *healthState) set(state string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if state == StateRunning {
+		h.failures = 0
+	}
+	if h.state != state {
+		h.state = state
+		h.since = time.Now()
+	}
+}
+
+// fail отмечает падение чтения и возвращает паузу перед перезапуском
+func (h *healthState) fail(err error) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures++
+	h.restarts++
+	h.lastErr = err.Error()
+	h.lastErrAt = time.Now()
+	if h.state != StateRestarting {
+		h.state = StateRestarting
+		h.since = h.lastErrAt
+	}
+	return restartBackoff.Delay(h.failures)
+}
+
+// observe запоминает ошибку, не останавливающую чтение
+func (h *healthState) observe(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastErr = err.Error()
+	h.lastErrAt = time.Now()
+}
+
+func (h *healthState) report(broker string) SourceHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	health := SourceHealth{Broker: broker, State: h.state, Since: h.since, Restarts: h.restarts, LastError: h.lastErr}
+	if health.State == "" {
+		health.State = StateStarting
+	}
+	if !h.lastErrAt.IsZero() {
+		at := h.lastErrAt
+		health.LastErrorAt = &at
+	}
+	return health
+}
+
+// partitionState — партиция, назначенная реплике в текущей сессии consumer group
+type partitionState struct {
+	claim     sarama.ConsumerGroupClaim
+	committed atomic.Int64
+}
+
+// assignedPartitions — партиции текущей сессии и их закоммиченные offset
+type assignedPartitions struct {
+	mu     sync.Mutex
+	claims map[string]map[int32]*partitionState
+}
+
+func (a *assignedPartitions) add(claim sarama.ConsumerGroupClaim, committed int64) *partitionState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.claims == nil {
+		a.claims = make(map[string]map[int32]*partitionState)
+	}
+	if a.claims[claim.Topic()] == nil {
+		a.claims[claim.Topic()] = make(map[int32]*partitionState)
+	}
+	state := &partitionState{claim: claim}
+	state.committed.Store(committed)
+	a.claims[claim.Topic()][claim.Partition()] = state
+	return state
+}
+
+func (a *assignedPartitions) remove(state *partitionState) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	topic, partition := state.claim.Topic(), state.claim.Partition()
+	if a.claims[topic][partition] == state {
+		delete(a.claims[topic], partition)
+		if len(a.claims[topic]) == 0 {
+			delete(a.claims, topic)
+		}
+	}
+}
+
+// lag возвращает отставание назначенных партиций, отсортированных по топику и номеру
+func (a *assignedPartitions) lag() ([]PartitionLag, int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var partitions []PartitionLag
+	var total int64
+	for topic, states := range a.claims {
+		for partition, state := range states {
+			p := PartitionLag{
+				Topic:         topic,
+				Partition:     partition,
+				Committed:     state.committed.Load(),
+				HighWaterMark: state.claim.HighWaterMarkOffset(),
+			}
+			if p.Committed >= 0 && p.HighWaterMark > p.Committed {
+				p.Lag = p.HighWaterMark - p.Committed
+			}
+			total += p.Lag
+			partitions = append(partitions, p)
+		}
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		if partitions[i].Topic != partitions[j].Topic {
+			return partitions[i].Topic < partitions[j].Topic
+		}
+		return partitions[i].Partition < partitions[j].Partition
+	})
+	return partitions, total
+}
@@ -0,0 +1,94 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"notiair/internal/workflow"
+)
+
+func TestHealthStateBacksOffUntilRunning(t *testing.T) {
+	var h healthState
+	if got := h.report(workflow.BrokerKafka); got.State != StateStarting || got.Up() {
+		t.Fatalf("new consumer should be starting and not up, got %+v", got)
+	}
+
+	if d := h.fail(errors.New("broker down")); d != time.Second {
+		t.Fatalf("first restart delay %s, want 1s", d)
+	}
+	if d := h.fail(errors.New("broker down")); d != 2*time.Second {
+		t.Fatalf("second restart delay %s, want 2s", d)
+	}
+	got := h.report(workflow.BrokerKafka)
+	if got.State != StateRestarting || got.Restarts != 2 || got.LastError != "broker down" || got.LastErrorAt == nil || Ready([]SourceHealth{got}) {
+		t.Fatalf("unexpected health after failures %+v", got)
+	}
+
+	h.set(StateRunning)
+	if !h.report(workflow.BrokerKafka).Up() {
+		t.Fatal("running consumer should be up")
+	}
+	if d := h.fail(errors.New("broker down")); d != time.Second {
+		t.Fatalf("delay after recovery %s, want 1s", d)
+	}
+}
+
+func TestRecoveredTurnsPanicIntoError(t *testing.T) {
+	err := func() (err error) {
+		defer recovered(&err)
+		panic("boom")
+	}()
+	if err == nil || err.Error() != "consumer panicked: boom" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+// lagClaim — партиция с заданным high water mark
+type lagClaim struct {
+	*fakeClaim
+	hwm int64
+}
+
+func (c *lagClaim) HighWaterMarkOffset() int64 { return c.hwm }
+
+func TestConsumeClaimReportsPartitionLag(t *testing.T) {
+	consumer := &Consumer{
+		processor: &processor{index: NewIndex(&fakeLister{}, workflow.BrokerKafka, "events")},
+		topic:     "events",
+	}
+	handler := &consumerGroupHandler{consumer: consumer}
+	session := &fakeSession{ctx: context.Background()}
+	claim := &lagClaim{fakeClaim: &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 3)}, hwm: 10}
+	for i := 0; i < 3; i++ {
+		claim.messages <- &sarama.ConsumerMessage{Topic: "events", Offset: int64(i), Value: []byte(fmt.Sprintf(`{"event_id":"evt-%d","event_type":"user.created"}`, i))}
+	}
+
+	done := make(chan error)
+	go func() { done <- handler.ConsumeClaim(session, claim) }()
+
+	want := []PartitionLag{{Topic: "events", Partition: 0, Committed: 3, HighWaterMark: 10, Lag: 7}}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		health := consumer.Health(context.Background())
+		if reflect.DeepEqual(health.Partitions, want) && health.Lag == 7 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("partitions %+v, want %+v", health.Partitions, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(claim.messages)
+	if err := <-done; err != nil {
+		t.Fatalf("consume claim: %v", err)
+	}
+	if health := consumer.Health(context.Background()); len(health.Partitions) != 0 {
+		t.Fatalf("released partition is still reported: %+v", health.Partitions)
+	}
+}
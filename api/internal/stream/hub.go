@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/gofiber/websocket/v2"
+
+	"notiair/internal/workflow"
 )
 
 // Hub управляет WebSocket соединениями и рассылкой сообщений
@@ -69,7 +71,7 @@ func (h *Hub) Run() {
 			h.mu.RLock()
 			for conn, eventTypes := range h.clients {
 				// Если у клиента нет фильтров или event_type в фильтрах, отправляем сообщение
-				if len(eventTypes) == 0 || eventTypes[event.EventType] {
+				if len(eventTypes) == 0 || matchesEventType(eventTypes, event.EventType) {
 					data, err := json.Marshal(event)
 					if err != nil {
 						log.Printf("failed to marshal event: %v", err)
@@ -116,3 +118,15 @@ func (h *Hub) UnregisterClient(conn *websocket.Conn) {
 	h.unregister <- conn
 }
 
+// matchesEventType проверяет event_type по фильтрам клиента (точные значения и glob-шаблоны)
+func matchesEventType(patterns map[string]bool, eventType string) bool {
+	if patterns[eventType] {
+		return true
+	}
+	for pattern := range patterns {
+		if workflow.MatchEventType(pattern, eventType) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package stream
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"notiair/internal/workflow"
+)
+
+// indexMaxAge — страховка на случай потерянного уведомления об изменении workflow
+const indexMaxAge = 5 * time.Minute
+
+// PublishedLister — источник опубликованных workflows для индекса
+type PublishedLister interface {
+	ListPublished(ctx context.Context) ([]workflow.Workflow, error)
+}
+
+// Match — workflow и его триггеры, которым подходит событие
+type Match struct {
+	WorkflowID string
+	TriggerIDs []string
+}
+
+type indexEntry struct {
+	workflowID string
+	order      int
+	trigger    StreamTrigger
+}
+
+type topicIndex struct {
+	exact map[string][]*indexEntry // event_type -> триггеры с точным совпадением
+	globs []*indexEntry            // триггеры с glob-шаблонами, проверяются на каждое событие
+}
+
+type indexSnapshot struct {
+	topics  []string
+	byTopic map[string]*topicIndex
+}
+
+// Index — кеш stream-триггеров активных workflows: topic -> event_type -> триггеры.
+// Перестраивается лениво после Invalidate (сохранение, удаление, восстановление,
+// публикация workflow) и не реже раза в indexMaxAge.
+type Index struct {
+	workflows    PublishedLister
+	broker       string
+	defaultTopic string
+
+	mu    sync.Mutex
+	snap  *indexSnapshot
+	built time.Time
+	dirty atomic.Bool
+}
+
+// NewIndex создает пустой индекс триггеров broker; первое обращение строит его из workflows
+func NewIndex(workflows PublishedLister, broker, defaultTopic string) *Index {
+	idx := &Index{workflows: workflows, broker: broker, defaultTopic: defaultTopic}
+	idx.dirty.Store(true)
+	return idx
+}
+
+// Invalidate помечает индекс устаревшим
+func (i *Index) Invalidate() {
+	i.dirty.Store(true)
+}
+
+// Topics возвращает объединение топиков активных workflows (см. SubscribedTopics)
+func (i *Index) Topics(ctx context.Context) ([]string, error) {
+	snap, err := i.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return snap.topics, nil
+}
+
+// Lookup возвращает workflows, которые запускает событие из топика, в порядке ListPublished
+func (i *Index) Lookup(ctx context.Context, topic string, event Event) ([]Match, error) {
+	snap, err := i.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []*indexEntry
+	for _, ti := range snap.topicIndexes(i.broker, topic) {
+		candidates = append(candidates, ti.exact[event.EventType]...)
+		candidates = append(candidates, ti.globs...)
+	}
+
+	byOrder := make(map[int]*Match)
+	var orders []int
+	seen := make(map[*indexEntry]bool, len(candidates))
+	for _, entry := range candidates {
+		if seen[entry] || !entry.trigger.Matches(topic, event) {
+			continue
+		}
+		seen[entry] = true
+		m, ok := byOrder[entry.order]
+		if !ok {
+			m = &Match{WorkflowID: entry.workflowID}
+			byOrder[entry.order] = m
+			orders = append(orders, entry.order)
+		}
+		m.TriggerIDs = append(m.TriggerIDs, entry.trigger.NodeID)
+	}
+
+	// Точные совпадения и glob-и идут разными списками — восстанавливаем порядок workflows
+	sort.Ints(orders)
+	matches := make([]Match, 0, len(orders))
+	for _, order := range orders {
+		matches = append(matches, *byOrder[order])
+	}
+	return matches, nil
+}
+
+// topicIndexes возвращает индексы топиков триггеров, читающих topic: у NATS это все
+// subject-шаблоны, под которые подходит subject события
+func (s *indexSnapshot) topicIndexes(broker, topic string) []*topicIndex {
+	if broker != workflow.BrokerNATS {
+		if ti := s.byTopic[topic]; ti != nil {
+			return []*topicIndex{ti}
+		}
+		return nil
+	}
+	var indexes []*topicIndex
+	for pattern, ti := range s.byTopic {
+		if workflow.MatchSubject(pattern, topic) {
+			indexes = append(indexes, ti)
+		}
+	}
+	return indexes
+}
+
+func (i *Index) current(ctx context.Context) (*indexSnapshot, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	stale := i.dirty.Swap(false) || time.Since(i.built) > indexMaxAge
+	if i.snap != nil && !stale {
+		return i.snap, nil
+	}
+
+	workflows, err := i.workflows.ListPublished(ctx)
+	if err != nil {
+		i.dirty.Store(true)
+		if i.snap != nil {
+			log.Printf("failed to rebuild stream trigger index, using previous one: %v", err)
+			return i.snap, nil
+		}
+		return nil, err
+	}
+
+	i.snap = buildIndex(workflows, i.broker, i.defaultTopic)
+	i.built = time.Now()
+	return i.snap, nil
+}
+
+func buildIndex(workflows []workflow.Workflow, broker, defaultTopic string) *indexSnapshot {
+	snap := &indexSnapshot{
+		topics:  SubscribedTopics(workflows, broker, defaultTopic),
+		byTopic: make(map[string]*topicIndex),
+	}
+	for order, wf := range workflows {
+		if !wf.IsActive {
+			continue
+		}
+		for _, trigger := range StreamTriggers(wf, broker, defaultTopic) {
+			ti := snap.byTopic[trigger.Topic]
+			if ti == nil {
+				ti = &topicIndex{exact: make(map[string][]*indexEntry)}
+				snap.byTopic[trigger.Topic] = ti
+			}
+
+			entry := &indexEntry{workflowID: wf.ID, order: order, trigger: trigger}
+			hasGlob := false
+			for _, pattern := range trigger.Config.EventTypes {
+				if strings.ContainsAny(pattern, "*?[\\") {
+					hasGlob = true
+					continue
+				}
+				ti.exact[pattern] = append(ti.exact[pattern], entry)
+			}
+			if hasGlob {
+				ti.globs = append(ti.globs, entry)
+			}
+		}
+	}
+	return snap
+}
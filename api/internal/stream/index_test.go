@@ -0,0 +1,129 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"notiair/internal/workflow"
+)
+
+type fakeLister struct {
+	workflows []workflow.Workflow
+	calls     int
+}
+
+func (f *fakeLister) ListPublished(context.Context) ([]workflow.Workflow, error) {
+	f.calls++
+	return f.workflows, nil
+}
+
+func TestIndexLookupMatchesTriggers(t *testing.T) {
+	glob := streamWorkflow("wf-2", true)
+	glob.Nodes[0].Config = map[string]any{"triggerKind": "stream", "topic": "orders", "eventTypes": []string{"order.*"}}
+	lister := &fakeLister{workflows: []workflow.Workflow{streamWorkflow("wf-1", true), glob, streamWorkflow("wf-3", false)}}
+	idx := NewIndex(lister, workflow.BrokerKafka, "events")
+
+	got, err := idx.Lookup(context.Background(), "orders", Event{EventType: "order.created"})
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	want := []Match{{WorkflowID: "wf-1", TriggerIDs: []string{"orders"}}, {WorkflowID: "wf-2", TriggerIDs: []string{"orders"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("matches %+v, want %+v", got, want)
+	}
+
+	if got, _ := idx.Lookup(context.Background(), "events", Event{EventType: "order.created"}); len(got) != 0 {
+		t.Fatalf("expected no matches on the default topic, got %+v", got)
+	}
+	topics, _ := idx.Topics(context.Background())
+	if want := []string{"events", "orders"}; !reflect.DeepEqual(topics, want) {
+		t.Fatalf("topics %v, want %v", topics, want)
+	}
+	if lister.calls != 1 {
+		t.Fatalf("expected the index to be built once, got %d builds", lister.calls)
+	}
+}
+
+func TestIndexRebuildsAfterInvalidate(t *testing.T) {
+	lister := &fakeLister{workflows: []workflow.Workflow{streamWorkflow("wf-1", true)}}
+	idx := NewIndex(lister, workflow.BrokerKafka, "events")
+	event := Event{EventType: "user.created"}
+
+	if got, _ := idx.Lookup(context.Background(), "events", event); len(got) != 1 {
+		t.Fatalf("expected wf-1 to match, got %+v", got)
+	}
+
+	lister.workflows = []workflow.Workflow{streamWorkflow("wf-1", false)}
+	if got, _ := idx.Lookup(context.Background(), "events", event); len(got) != 1 {
+		t.Fatalf("index should stay cached until invalidated, got %+v", got)
+	}
+
+	idx.Invalidate()
+	if got, _ := idx.Lookup(context.Background(), "events", event); len(got) != 0 {
+		t.Fatalf("expected no matches after invalidate, got %+v", got)
+	}
+}
+
+func TestWorkflowChangesFanOutWithoutRedis(t *testing.T) {
+	changes := NewWorkflowChanges(nil)
+	var got []string
+	changes.Subscribe(func(id string) { got = append(got, id) })
+
+	changes.Notify(context.Background(), "wf-1")
+	if !reflect.DeepEqual(got, []string{"wf-1"}) {
+		t.Fatalf("subscriber got %v", got)
+	}
+}
+
+func benchmarkWorkflows(n int) []workflow.Workflow {
+	workflows := make([]workflow.Workflow, n)
+	for i := range workflows {
+		wf := streamWorkflow(fmt.Sprintf("wf-%d", i), true)
+		wf.Nodes[0].Config = map[string]any{"triggerKind": "stream", "topic": "orders", "eventTypes": []string{fmt.Sprintf("order.type%d", i)}}
+		workflows[i] = wf
+	}
+	return workflows
+}
+
+// BenchmarkMatchByScan — прежний путь: список workflows и разбор триггеров на каждое событие
+func BenchmarkMatchByScan(b *testing.B) {
+	lister := &fakeLister{workflows: benchmarkWorkflows(500)}
+	event := Event{EventType: "order.type250"}
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		workflows, _ := lister.ListPublished(ctx)
+		matched := 0
+		for _, wf := range workflows {
+			for _, trigger := range StreamTriggers(wf, workflow.BrokerKafka, "events") {
+				if trigger.Matches("orders", event) {
+					matched++
+				}
+			}
+		}
+		if matched != 1 {
+			b.Fatalf("expected 1 match, got %d", matched)
+		}
+	}
+}
+
+// BenchmarkMatchByIndex — поиск по кешированному индексу
+func BenchmarkMatchByIndex(b *testing.B) {
+	idx := NewIndex(&fakeLister{workflows: benchmarkWorkflows(500)}, workflow.BrokerKafka, "events")
+	event := Event{EventType: "order.type250"}
+	ctx := context.Background()
+	if _, err := idx.Lookup(ctx, "orders", event); err != nil {
+		b.Fatalf("build index: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matches, _ := idx.Lookup(ctx, "orders", event)
+		if len(matches) != 1 {
+			b.Fatalf("expected 1 match, got %d", len(matches))
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package stream
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/IBM/sarama"
+	"notiair/internal/cloudevents"
+)
+
+// Префикс заголовков CloudEvents в binary-режиме Kafka binding
+const cloudEventsHeaderPrefix = "ce_"
+
+// DecodeMessage разбирает сообщение Kafka в Event. Поддерживаются собственный формат
+// (event_id, event_type, occurred_at, context, metadata) и CloudEvents: binary-режим
+// (атрибуты в заголовках ce_*, тело — data) и structured-режим (JSON со specversion).
+// У CloudEvent id, type и time становятся event_id, event_type и occurred_at, data — context,
+// остальные атрибуты и расширения — metadata.
+func DecodeMessage(message *sarama.ConsumerMessage) (Event, error) {
+	attrs := make(map[string]string)
+	contentType := ""
+	for _, h := range message.Headers {
+		if h == nil {
+			continue
+		}
+		name := strings.ToLower(string(h.Key))
+		switch {
+		case strings.HasPrefix(name, cloudEventsHeaderPrefix):
+			attrs[strings.TrimPrefix(name, cloudEventsHeaderPrefix)] = string(h.Value)
+		case name == "content-type":
+			contentType = string(h.Value)
+		}
+	}
+	return decodeEvent(attrs, contentType, message.Value)
+}
+
+// decodeEvent разбирает тело сообщения с атрибутами CloudEvents binary-режима (без префикса)
+func decodeEvent(attrs map[string]string, contentType string, body []byte) (Event, error) {
+	if _, binary := attrs["specversion"]; binary {
+		ce, err := cloudevents.FromBinary(attrs, contentType, body)
+		if err != nil {
+			return Event{}, err
+		}
+		return eventFromCloudEvent(ce), nil
+	}
+	if cloudevents.IsStructured(contentType, body) {
+		ce, err := cloudevents.ParseStructured(body)
+		if err != nil {
+			return Event{}, err
+		}
+		return eventFromCloudEvent(ce), nil
+	}
+
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		return Event{}, err
+	}
+	return event, nil
+}
+
+func eventFromCloudEvent(ce cloudevents.Event) Event {
+	return Event{
+		EventID:    ce.ID,
+		EventType:  ce.Type,
+		OccurredAt: ce.Time,
+		Context:    ce.Context(),
+		Metadata:   ce.Metadata(),
+	}
+}
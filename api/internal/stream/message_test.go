@@ -0,0 +1,46 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+)
+
+func TestDecodeMessageFormats(t *testing.T) {
+	binary := &sarama.ConsumerMessage{
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte("ce_specversion"), Value: []byte("1.0")},
+			{Key: []byte("ce_id"), Value: []byte("evt-1")},
+			{Key: []byte("ce_type"), Value: []byte("order.created")},
+			{Key: []byte("ce_source"), Value: []byte("/shop")},
+			{Key: []byte("ce_time"), Value: []byte("2024-05-01T10:00:00Z")},
+			{Key: []byte("ce_tenant"), Value: []byte("acme")},
+			{Key: []byte("content-type"), Value: []byte("application/json")},
+		},
+		Value: []byte(`{"order":{"amount":150}}`),
+	}
+	structured := &sarama.ConsumerMessage{
+		Value: []byte(`{"specversion":"1.0","id":"evt-1","type":"order.created","source":"/shop","time":"2024-05-01T10:00:00Z","tenant":"acme","data":{"order":{"amount":150}}}`),
+	}
+	legacy := &sarama.ConsumerMessage{
+		Value: []byte(`{"event_id":"evt-1","event_type":"order.created","occurred_at":"2024-05-01T10:00:00Z","context":{"order":{"amount":150}},"metadata":{"tenant":"acme"}}`),
+	}
+
+	for name, message := range map[string]*sarama.ConsumerMessage{"binary": binary, "structured": structured, "legacy": legacy} {
+		event, err := DecodeMessage(message)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if event.EventID != "evt-1" || event.EventType != "order.created" || event.OccurredAt != "2024-05-01T10:00:00Z" {
+			t.Fatalf("%s: unexpected event %+v", name, event)
+		}
+		order, _ := event.Context["order"].(map[string]any)
+		if order["amount"] != float64(150) || event.Metadata["tenant"] != "acme" {
+			t.Fatalf("%s: unexpected context/metadata %+v", name, event)
+		}
+	}
+
+	if _, err := DecodeMessage(&sarama.ConsumerMessage{Value: []byte(`{"specversion":"1.0","id":"evt-1"}`)}); err == nil {
+		t.Fatal("structured event without type and source should be rejected")
+	}
+}
@@ -0,0 +1,364 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"notiair/internal/workflow"
+	"notiair/services"
+)
+
+const (
+	// Префикс заголовков CloudEvents в binary-режиме NATS binding
+	natsCloudEventsHeaderPrefix = "ce-"
+	// headerOriginalSequence — номер сообщения в JetStream stream, попавшего в dead-letter subject
+	headerOriginalSequence = headerPrefix + "original-sequence"
+)
+
+// NATSConfig — чтение stream-триггеров с broker = "nats" из NATS JetStream
+type NATSConfig struct {
+	Stream  string // JetStream stream, в который попадают subjects триггеров
+	Durable string // durable consumer, общий для реплик
+	// DefaultSubject читают триггеры без topic; пусто — такие триггеры не читаются
+	DefaultSubject string
+	// AckWait — через сколько неподтвержденное сообщение доставляется снова
+	AckWait time.Duration
+	// DeadLetterPrefix — префикс subject необработанных сообщений: <prefix>.<subject>.
+	// Subject должен попадать в какой-либо stream, иначе публикация не подтвердится.
+	DeadLetterPrefix string
+}
+
+// NATSConsumer читает события из NATS JetStream через durable pull consumer. Фильтр
+// consumer — subjects триггеров (с wildcards "*" и ">"); событие без event_type получает
+// тип по своему subject. Обработанное сообщение подтверждается (Ack), неудачное
+// возвращается с паузой по RetryPolicy (NakWithDelay), а после MaxRetries повторов
+// публикуется в dead-letter subject и снимается с доставки (Term).
+type NATSConsumer struct {
+	*processor
+	js         jetstream.JetStream
+	cfg        NATSConfig
+	redelivery RetryPolicy // повторы через повторную доставку брокером
+	wg         sync.WaitGroup
+	cancel     context.CancelFunc
+	health     healthState
+}
+
+// NewNATSConsumer создает consumer NATS JetStream
+func NewNATSConsumer(
+	conn *nats.Conn,
+	cfg NATSConfig,
+	workflowRepo workflow.Repository,
+	notificationSvc *services.NotificationService,
+	hub *Hub,
+	redisStore *RedisStore,
+	retry RetryPolicy,
+	claims EventClaims,
+	schemas EventSchemas,
+) (*NATSConsumer, error) {
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jetstream context: %w", err)
+	}
+	if cfg.AckWait <= 0 {
+		cfg.AckWait = 30 * time.Second
+	}
+	if cfg.DeadLetterPrefix == "" {
+		cfg.DeadLetterPrefix = "notiair.dlq"
+	}
+
+	// Повторяет брокер (NakWithDelay), поэтому внутри обработки событие запускается один раз
+	inProcess := RetryPolicy{Backoff: retry.Backoff, MaxBackoff: retry.MaxBackoff}
+	return &NATSConsumer{
+		processor:  newProcessor(NewIndex(workflowRepo, workflow.BrokerNATS, cfg.DefaultSubject), notificationSvc, hub, redisStore, inProcess, claims, schemas),
+		js:         js,
+		cfg:        cfg,
+		redelivery: retry,
+	}, nil
+}
+
+// Start запускает чтение в фоновом режиме
+func (c *NATSConsumer) Start(ctx context.Context) error {
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for ctx.Err() == nil {
+			topics, err := c.index.Topics(ctx)
+			if err != nil {
+				delay := c.health.fail(err)
+				log.Printf("failed to list nats subjects, retrying in %s: %v", delay, err)
+				c.sleep(ctx, delay)
+				continue
+			}
+			subjects := filterSubjects(topics)
+			if len(subjects) == 0 {
+				// Читать нечего — это не сбой
+				c.health.set(StateRunning)
+				c.sleep(ctx, topicRefreshInterval)
+				continue
+			}
+			if err := c.consume(ctx, subjects); err != nil && ctx.Err() == nil {
+				delay := c.health.fail(err)
+				log.Printf("error from nats consumer, restarting in %s: %v", delay, err)
+				c.sleep(ctx, delay)
+			}
+		}
+	}()
+
+	log.Printf("nats consumer started (stream: %s, durable: %s)", c.cfg.Stream, c.cfg.Durable)
+	return nil
+}
+
+// Stop останавливает чтение и ждет обработки текущего сообщения
+func (c *NATSConsumer) Stop() error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+	c.health.set(StateStopped)
+	log.Println("nats consumer stopped")
+	return nil
+}
+
+// Health возвращает состояние чтения; Lag — сообщения, которые durable consumer
+// еще не доставил или доставил без подтверждения
+func (c *NATSConsumer) Health(ctx context.Context) SourceHealth {
+	health := c.health.report(workflow.BrokerNATS)
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	consumer, err := c.js.Consumer(ctx, c.cfg.Stream, c.cfg.Durable)
+	if err != nil {
+		return health
+	}
+	info, err := consumer.Info(ctx)
+	if err != nil {
+		return health
+	}
+	health.Lag = int64(info.NumPending) + int64(info.NumAckPending)
+	return health
+}
+
+// consume настраивает фильтр durable consumer на subjects и читает сообщения, пока
+// не изменится набор subjects триггеров или не завершится контекст
+func (c *NATSConsumer) consume(ctx context.Context, subjects []string) (err error) {
+	defer recovered(&err)
+	consumer, err := c.js.CreateOrUpdateConsumer(ctx, c.cfg.Stream, jetstream.ConsumerConfig{
+		Durable:        c.cfg.Durable,
+		FilterSubjects: subjects,
+		AckPolicy:      jetstream.AckExplicitPolicy,
+		AckWait:        c.cfg.AckWait,
+		DeliverPolicy:  jetstream.DeliverAllPolicy,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create consumer %s on stream %s: %w", c.cfg.Durable, c.cfg.Stream, err)
+	}
+	messages, err := consumer.Messages()
+	if err != nil {
+		return fmt.Errorf("failed to pull messages: %w", err)
+	}
+	c.health.set(StateRunning)
+	log.Printf("nats consumer reading subjects: %s", strings.Join(subjects, ", "))
+
+	// Останавливаем чтение при изменении subjects триггеров (как сессию Kafka consumer group)
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		ticker := time.NewTicker(topicRefreshInterval)
+		defer ticker.Stop()
+		defer messages.Stop()
+		for {
+			select {
+			case <-watchCtx.Done():
+				return
+			case <-ticker.C:
+				topics, err := c.index.Topics(watchCtx)
+				if err != nil {
+					log.Printf("failed to refresh nats subjects: %v", err)
+					continue
+				}
+				if next := filterSubjects(topics); !reflect.DeepEqual(next, subjects) {
+					log.Printf("nats subjects changed: %v -> %v", subjects, next)
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		msg, err := messages.Next()
+		if errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		c.handleMessage(ctx, msg)
+	}
+}
+
+// handleMessage обрабатывает сообщение и подтверждает его, возвращает на повтор или
+// отправляет в dead-letter subject
+func (c *NATSConsumer) handleMessage(ctx context.Context, msg jetstream.Msg) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		// Без метаданных не известно число доставок, а повторная доставка их не исправит
+		log.Printf("failed to read metadata of nats message %s: %v", msg.Subject(), err)
+		c.terminate(ctx, msg, nil, StageParse, 1, fmt.Errorf("failed to read message metadata: %w", err))
+		return
+	}
+	delivered := int(meta.NumDelivered)
+
+	event, err := DecodeNATSMessage(msg.Subject(), msg.Headers(), msg.Data())
+	if err != nil {
+		log.Printf("failed to parse nats message %s/%d: %v", msg.Subject(), meta.Sequence.Stream, err)
+		c.terminate(ctx, msg, meta, StageParse, delivered, err)
+		return
+	}
+
+	done, _, err := c.handle(ctx, msg.Subject(), event)
+	switch {
+	case !done:
+		// Контекст завершился: вернем сообщение, чтобы его получила другая реплика
+		c.settle(msg, msg.Nak(), "nak")
+	case err == nil:
+		c.settle(msg, msg.Ack(), "ack")
+	case delivered <= c.redelivery.MaxRetries:
+		delay := c.redelivery.Delay(delivered)
+		log.Printf("failed to process event %s (delivery %d), redelivering in %s: %v", event.EventID, delivered, delay, err)
+		c.settle(msg, msg.NakWithDelay(delay), "nak")
+	default:
+		log.Printf("failed to process event %s after %d deliveries: %v", event.EventID, delivered, err)
+		c.terminate(ctx, msg, meta, StageProcess, delivered, err)
+	}
+}
+
+// terminate отправляет сообщение в dead-letter subject и снимает его с доставки. Если
+// публикация не удалась до завершения контекста, сообщение возвращается на повтор.
+func (c *NATSConsumer) terminate(ctx context.Context, msg jetstream.Msg, meta *jetstream.MsgMetadata, stage string, attempts int, cause error) {
+	if !c.deadLetter(ctx, msg, meta, stage, attempts, cause) {
+		c.settle(msg, msg.Nak(), "nak")
+		return
+	}
+	c.settle(msg, msg.Term(), "term")
+}
+
+func (c *NATSConsumer) settle(msg jetstream.Msg, err error, action string) {
+	if err != nil {
+		// Без подтверждения сообщение придет снова через AckWait; дубли отсечет EventClaims
+		log.Printf("failed to %s nats message %s: %v", action, msg.Subject(), err)
+	}
+}
+
+// deadLetter публикует сообщение в <DeadLetterPrefix>.<subject> с заголовками ошибки,
+// повторяя публикацию, пока JetStream недоступен. Возвращает false, если контекст завершился раньше.
+// meta может быть nil, если метаданные сообщения не прочитались.
+func (c *NATSConsumer) deadLetter(ctx context.Context, msg jetstream.Msg, meta *jetstream.MsgMetadata, stage string, attempts int, cause error) bool {
+	header := nats.Header{}
+	for name, values := range msg.Headers() {
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set(headerError, cause.Error())
+	header.Set(headerStage, stage)
+	header.Set(headerAttempts, strconv.Itoa(attempts))
+	header.Set(headerFailedAt, time.Now().UTC().Format(time.RFC3339Nano))
+	header.Set(headerOriginalTopic, msg.Subject())
+	ref := msg.Subject()
+	if meta != nil {
+		header.Set(headerOriginalSequence, strconv.FormatUint(meta.Sequence.Stream, 10))
+		ref += "/" + strconv.FormatUint(meta.Sequence.Stream, 10)
+	}
+
+	out := &nats.Msg{Subject: c.cfg.DeadLetterPrefix + "." + msg.Subject(), Header: header, Data: msg.Data()}
+	for attempt := 1; ; attempt++ {
+		_, err := c.js.PublishMsg(ctx, out)
+		if err == nil {
+			log.Printf("nats message %s sent to dead-letter subject %s (stage: %s)", ref, out.Subject, stage)
+			return true
+		}
+		delay := c.redelivery.Delay(attempt)
+		log.Printf("failed to publish nats message %s to dead-letter subject, retrying in %s: %v", ref, delay, err)
+		if !c.sleep(ctx, delay) {
+			return false
+		}
+	}
+}
+
+// sleep ждет d и возвращает false, если контекст завершился раньше
+func (c *NATSConsumer) sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// filterSubjects убирает subjects, покрытые wildcard другого subject: фильтры durable
+// consumer JetStream не должны пересекаться
+func filterSubjects(topics []string) []string {
+	var subjects []string
+	for _, topic := range topics {
+		covered := false
+		for _, other := range topics {
+			if other != topic && subjectCovers(other, topic) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			subjects = append(subjects, topic)
+		}
+	}
+	sort.Strings(subjects)
+	return subjects
+}
+
+// subjectCovers сообщает, подходит ли под pattern каждый subject, подходящий под sub
+func subjectCovers(pattern, sub string) bool {
+	want := strings.Split(pattern, ".")
+	got := strings.Split(sub, ".")
+	for i, token := range want {
+		if token == ">" {
+			return len(got) > i
+		}
+		if i >= len(got) || got[i] == ">" || (token != "*" && token != got[i]) {
+			return false
+		}
+	}
+	return len(got) == len(want)
+}
+
+// DecodeNATSMessage разбирает сообщение NATS в Event так же, как сообщение Kafka:
+// собственный формат или CloudEvents (атрибуты binary-режима — в заголовках ce-*,
+// тип содержимого — в Content-Type). Событие без event_type получает тип по subject,
+// без event_id — id из заголовка Nats-Msg-Id.
+func DecodeNATSMessage(subject string, header nats.Header, data []byte) (Event, error) {
+	attrs := make(map[string]string)
+	for name, values := range header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, natsCloudEventsHeaderPrefix) && len(values) > 0 {
+			attrs[strings.TrimPrefix(lower, natsCloudEventsHeaderPrefix)] = values[0]
+		}
+	}
+	event, err := decodeEvent(attrs, header.Get("Content-Type"), data)
+	if err != nil {
+		return Event{}, err
+	}
+	if event.EventType == "" {
+		event.EventType = subject
+	}
+	if event.EventID == "" {
+		event.EventID = header.Get(nats.MsgIdHdr)
+	}
+	return event, nil
+}
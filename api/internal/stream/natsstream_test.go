@@ -0,0 +1,245 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"notiair/internal/workflow"
+)
+
+// failingClaims не может закрепить ни одну пару, как при недоступной базе
+type failingClaims struct {
+	calls atomic.Int64
+}
+
+func (f *failingClaims) Claim(context.Context, string, string) (bool, error) {
+	f.calls.Add(1)
+	return false, errors.New("database is down")
+}
+
+func (f *failingClaims) Complete(context.Context, string, string) error {
+	return nil
+}
+
+func (f *failingClaims) Release(context.Context, string, string) error {
+	return nil
+}
+
+func runNATS(t *testing.T) (*nats.Conn, jetstream.JetStream) {
+	t.Helper()
+	srv, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: t.TempDir(), NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatalf("nats server: %v", err)
+	}
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server is not ready")
+	}
+	t.Cleanup(srv.Shutdown)
+
+	conn, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(conn.Close)
+	js, err := jetstream.New(conn)
+	if err != nil {
+		t.Fatalf("jetstream: %v", err)
+	}
+	_, err = js.CreateStream(context.Background(), jetstream.StreamConfig{Name: "EVENTS", Subjects: []string{"orders.>", "notiair.dlq.>"}})
+	if err != nil {
+		t.Fatalf("create stream: %v", err)
+	}
+	return conn, js
+}
+
+func newTestNATSConsumer(t *testing.T, conn *nats.Conn, lister PublishedLister, claims EventClaims, schemas EventSchemas) *NATSConsumer {
+	t.Helper()
+	consumer, err := NewNATSConsumer(conn, NATSConfig{
+		Stream:         "EVENTS",
+		Durable:        "notiair",
+		DefaultSubject: "orders.>",
+	}, nil, nil, nil, nil, RetryPolicy{MaxRetries: 1, Backoff: 10 * time.Millisecond}, claims, schemas)
+	if err != nil {
+		t.Fatalf("new consumer: %v", err)
+	}
+	consumer.index = NewIndex(lister, workflow.BrokerNATS, "orders.>")
+	if err := consumer.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(func() { consumer.Stop() })
+	return consumer
+}
+
+// waitDeadLetters ждет n сообщений в dead-letter subjects
+func waitDeadLetters(t *testing.T, js jetstream.JetStream, n int) []*jetstream.RawStreamMsg {
+	t.Helper()
+	stream, err := js.Stream(context.Background(), "EVENTS")
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var dead []*jetstream.RawStreamMsg
+		for seq := uint64(1); ; seq++ {
+			msg, err := stream.GetMsg(context.Background(), seq)
+			if err != nil {
+				break
+			}
+			if strings.HasPrefix(msg.Subject, "notiair.dlq.") {
+				dead = append(dead, msg)
+			}
+		}
+		if len(dead) >= n || time.Now().After(deadline) {
+			return dead
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestNATSConsumerAcksRejectsAndDeadLettersUnparsable(t *testing.T) {
+	conn, js := runNATS(t)
+	ctx := context.Background()
+	schemas := &fakeSchemas{}
+	newTestNATSConsumer(t, conn, &fakeLister{}, nil, schemas)
+
+	for _, msg := range []*nats.Msg{
+		{Subject: "orders.created", Data: []byte(`{"event_id":"evt-0","event_type":"user.created","context":{"email":"a@b.c"}}`)},
+		{Subject: "orders.created", Data: []byte(`{"event_id":"evt-1","event_type":"user.created","context":{}}`)},
+		{Subject: "orders.created", Data: []byte(`not json`)},
+	} {
+		if _, err := js.PublishMsg(ctx, msg); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	dead := waitDeadLetters(t, js, 1)
+	if len(dead) != 1 || dead[0].Subject != "notiair.dlq.orders.created" || dead[0].Header.Get(headerStage) != StageParse || string(dead[0].Data) != "not json" {
+		t.Fatalf("unexpected dead letters %+v", dead)
+	}
+
+	schemas.mu.Lock()
+	rejected := append([]string(nil), schemas.rejected...)
+	schemas.mu.Unlock()
+	if want := []string{"evt-1"}; !reflect.DeepEqual(rejected, want) {
+		t.Fatalf("rejected %v, want %v", rejected, want)
+	}
+
+	consumer, err := js.Consumer(ctx, "EVENTS", "notiair")
+	if err != nil {
+		t.Fatalf("consumer: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := consumer.Info(ctx)
+		if err != nil {
+			t.Fatalf("consumer info: %v", err)
+		}
+		if info.NumAckPending == 0 && info.NumPending == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("messages left unacknowledged: %+v", info)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestNATSConsumerRedeliversThenDeadLetters(t *testing.T) {
+	conn, js := runNATS(t)
+	ctx := context.Background()
+	wf := streamWorkflow("wf-1", true)
+	wf.Nodes[0].Config = map[string]any{"triggerKind": "stream", "broker": "nats", "topic": "orders.>", "eventTypes": []string{"order.*"}}
+	claims := &failingClaims{}
+	newTestNATSConsumer(t, conn, &fakeLister{workflows: []workflow.Workflow{wf}}, claims, nil)
+
+	if _, err := js.PublishMsg(ctx, &nats.Msg{Subject: "orders.paid", Data: []byte(`{"event_id":"evt-1","event_type":"order.paid"}`)}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	dead := waitDeadLetters(t, js, 1)
+	if len(dead) != 1 || dead[0].Header.Get(headerStage) != StageProcess || dead[0].Header.Get(headerAttempts) != "2" || dead[0].Header.Get(headerOriginalTopic) != "orders.paid" {
+		t.Fatalf("expected the event in dead letters after 2 deliveries, got %+v", dead)
+	}
+	if claims.calls.Load() != 2 {
+		t.Fatalf("expected 2 processing attempts, got %d", claims.calls.Load())
+	}
+}
+
+func TestIndexLookupMatchesNATSSubjects(t *testing.T) {
+	wf := streamWorkflow("wf-1", true)
+	wf.Nodes[0].Config = map[string]any{"triggerKind": "stream", "broker": "nats", "topic": "orders.*", "eventTypes": []string{"orders.*"}}
+	wf.Nodes[1].Config = map[string]any{"triggerKind": "stream", "broker": "nats", "topic": "orders.>", "eventTypes": []string{"*"}}
+	idx := NewIndex(&fakeLister{workflows: []workflow.Workflow{wf}}, workflow.BrokerNATS, "")
+
+	got, err := idx.Lookup(context.Background(), "orders.created", Event{EventType: "orders.created"})
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if len(got) != 1 || len(got[0].TriggerIDs) != 2 {
+		t.Fatalf("expected both triggers of wf-1, got %+v", got)
+	}
+	if got, _ := idx.Lookup(context.Background(), "orders.eu.created", Event{EventType: "orders.eu.created"}); len(got) != 1 || len(got[0].TriggerIDs) != 1 {
+		t.Fatalf("expected only the > trigger, got %+v", got)
+	}
+}
+
+func TestFilterSubjectsDropsCoveredSubjects(t *testing.T) {
+	got := filterSubjects([]string{"orders.created", "orders.>", "refunds.*", "refunds.eu", "payments.*.failed"})
+	if want := []string{"orders.>", "payments.*.failed", "refunds.*"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("subjects %v, want %v", got, want)
+	}
+}
+
+func TestDecodeNATSMessageFallsBackToSubject(t *testing.T) {
+	header := nats.Header{}
+	header.Set(nats.MsgIdHdr, "msg-1")
+	event, err := DecodeNATSMessage("orders.created", header, []byte(`{"context":{"order":{"id":1}}}`))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if event.EventType != "orders.created" || event.EventID != "msg-1" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+}
+
+// brokenMsg — сообщение, метаданные которого не читаются (reply subject не от JetStream)
+type brokenMsg struct {
+	jetstream.Msg
+	terminated bool
+}
+
+func (m *brokenMsg) Metadata() (*jetstream.MsgMetadata, error) {
+	return nil, jetstream.ErrNotJSMessage
+}
+func (m *brokenMsg) Subject() string      { return "orders.created" }
+func (m *brokenMsg) Headers() nats.Header { return nil }
+func (m *brokenMsg) Data() []byte         { return []byte(`{}`) }
+func (m *brokenMsg) Term() error          { m.terminated = true; return nil }
+func (m *brokenMsg) Nak() error           { return errors.New("unexpected nak") }
+
+func TestNATSConsumerTerminatesMessageWithoutMetadata(t *testing.T) {
+	conn, js := runNATS(t)
+	consumer, err := NewNATSConsumer(conn, NATSConfig{Stream: "EVENTS", Durable: "notiair"}, nil, nil, nil, nil, RetryPolicy{}, nil, nil)
+	if err != nil {
+		t.Fatalf("new consumer: %v", err)
+	}
+
+	msg := &brokenMsg{}
+	consumer.handleMessage(context.Background(), msg)
+	if !msg.terminated {
+		t.Fatal("message without metadata should be terminated")
+	}
+	dead := waitDeadLetters(t, js, 1)
+	if len(dead) != 1 || dead[0].Header.Get(headerStage) != StageParse || dead[0].Header.Get(headerOriginalSequence) != "" {
+		t.Fatalf("unexpected dead letters %+v", dead)
+	}
+}
@@ -0,0 +1,115 @@
+package stream
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	"github.com/IBM/sarama"
+)
+
+// Concurrency — параллельная обработка сообщений одной партиции.
+// События с одинаковым ключом обрабатываются по порядку одним воркером.
+type Concurrency struct {
+	// Workers — число воркеров на партицию; 1 — строго последовательная обработка
+	Workers int
+	// KeyPath — путь к ключу в событии ("context.order.id"); пусто — ключ сообщения Kafka
+	KeyPath string
+}
+
+// orderingKey возвращает ключ упорядочивания события; пустой ключ — порядок не важен
+func (c Concurrency) orderingKey(message *sarama.ConsumerMessage, event *Event) string {
+	if c.KeyPath != "" && event != nil {
+		if value, ok := eventField(*event, c.KeyPath); ok && value != nil {
+			return fmt.Sprint(value)
+		}
+	}
+	return string(message.Key)
+}
+
+func eventField(event Event, dotted string) (any, bool) {
+	var current any = map[string]any{
+		"event_id":   event.EventID,
+		"event_type": event.EventType,
+		"context":    event.Context,
+		"metadata":   event.Metadata,
+	}
+	for _, key := range strings.Split(dotted, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// workerPicker распределяет сообщения по воркерам: одинаковый ключ — один воркер,
+// сообщения без ключа — по кругу
+type workerPicker struct {
+	workers int
+	next    int
+}
+
+func (p *workerPicker) pick(key string) int {
+	if p.workers <= 1 {
+		return 0
+	}
+	if key == "" {
+		p.next = (p.next + 1) % p.workers
+		return p.next
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(p.workers))
+}
+
+// offsetTracker помнит сообщения партиции в порядке получения и коммитит последнее
+// сообщение, все предшественники которого уже обработаны: MarkMessage коммитит и все
+// меньшие offset, поэтому коммитить можно только непрерывный префикс.
+type offsetTracker struct {
+	mu      sync.Mutex
+	pending []*trackedMessage
+	mark    func(*sarama.ConsumerMessage)
+}
+
+type trackedMessage struct {
+	message *sarama.ConsumerMessage
+	done    bool
+}
+
+func (t *offsetTracker) add(message *sarama.ConsumerMessage) *trackedMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tm := &trackedMessage{message: message}
+	t.pending = append(t.pending, tm)
+	return tm
+}
+
+// complete отмечает сообщение обработанным и коммитит продвинувшийся префикс.
+// mark вызывается под блокировкой, поэтому offset коммитятся по возрастанию.
+func (t *offsetTracker) complete(tm *trackedMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tm.done = true
+
+	var commit *sarama.ConsumerMessage
+	n := 0
+	for n < len(t.pending) && t.pending[n].done {
+		commit = t.pending[n].message
+		n++
+	}
+	t.pending = t.pending[n:]
+	if commit != nil {
+		t.mark(commit)
+	}
+}
+
+type claimJob struct {
+	tracked *trackedMessage
+	event   *Event // nil, если сообщение не разобрано
+	err     error  // ошибка разбора
+}
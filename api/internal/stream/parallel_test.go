@@ -0,0 +1,193 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"notiair/internal/workflow"
+)
+
+func TestOffsetTrackerCommitsContiguousPrefix(t *testing.T) {
+	var committed []int64
+	tracker := &offsetTracker{mark: func(message *sarama.ConsumerMessage) {
+		committed = append(committed, message.Offset)
+	}}
+	var tracked []*trackedMessage
+	for _, offset := range []int64{10, 11, 13} {
+		tracked = append(tracked, tracker.add(&sarama.ConsumerMessage{Offset: offset}))
+	}
+
+	tracker.complete(tracked[1])
+	if len(committed) != 0 {
+		t.Fatalf("offset 11 must wait for 10, got commits %v", committed)
+	}
+	tracker.complete(tracked[0])
+	tracker.complete(tracked[2])
+	if want := []int64{11, 13}; !reflect.DeepEqual(committed, want) {
+		t.Fatalf("commits %v, want %v", committed, want)
+	}
+	if len(tracker.pending) != 0 {
+		t.Fatalf("expected nothing pending, got %d", len(tracker.pending))
+	}
+}
+
+func TestWorkerPickerKeepsKeyOnOneWorker(t *testing.T) {
+	picker := &workerPicker{workers: 4}
+	first := picker.pick("order-42")
+	for i := 0; i < 10; i++ {
+		if got := picker.pick("order-42"); got != first {
+			t.Fatalf("key moved from worker %d to %d", first, got)
+		}
+	}
+
+	seen := map[int]bool{}
+	for i := 0; i < 4; i++ {
+		seen[picker.pick("")] = true
+	}
+	if len(seen) != 4 {
+		t.Fatalf("messages without key should be spread over workers, got %v", seen)
+	}
+}
+
+func TestOrderingKeyPrefersEventPath(t *testing.T) {
+	message := &sarama.ConsumerMessage{Key: []byte("kafka-key")}
+	event := &Event{Context: map[string]any{"order": map[string]any{"id": float64(42)}}}
+
+	if got := (Concurrency{}).orderingKey(message, event); got != "kafka-key" {
+		t.Fatalf("expected Kafka key, got %q", got)
+	}
+	if got := (Concurrency{KeyPath: "context.order.id"}).orderingKey(message, event); got != "42" {
+		t.Fatalf("expected key from event, got %q", got)
+	}
+	if got := (Concurrency{KeyPath: "context.customer.id"}).orderingKey(message, event); got != "kafka-key" {
+		t.Fatalf("missing path should fall back to Kafka key, got %q", got)
+	}
+	if got := (Concurrency{KeyPath: "context.order.id"}).orderingKey(message, nil); got != "kafka-key" {
+		t.Fatalf("unparsed message should use Kafka key, got %q", got)
+	}
+}
+
+type fakeSession struct {
+	ctx    context.Context
+	claims map[string][]int32
+	mu     sync.Mutex
+	marked []int64
+	resets map[int32]int64
+}
+
+func (s *fakeSession) Claims() map[string][]int32              { return s.claims }
+func (s *fakeSession) MemberID() string                        { return "member" }
+func (s *fakeSession) GenerationID() int32                     { return 1 }
+func (s *fakeSession) MarkOffset(string, int32, int64, string) {}
+func (s *fakeSession) Commit()                                 {}
+func (s *fakeSession) ResetOffset(_ string, partition int32, offset int64, _ string) {
+	if s.resets == nil {
+		s.resets = map[int32]int64{}
+	}
+	s.resets[partition] = offset
+}
+func (s *fakeSession) Context() context.Context { return s.ctx }
+func (s *fakeSession) MarkMessage(message *sarama.ConsumerMessage, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marked = append(s.marked, message.Offset)
+}
+
+type fakeClaim struct {
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Topic() string                            { return "events" }
+func (c *fakeClaim) Partition() int32                         { return 0 }
+func (c *fakeClaim) InitialOffset() int64                     { return 0 }
+func (c *fakeClaim) HighWaterMarkOffset() int64               { return 0 }
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func TestConsumeClaimCommitsEveryMessageInOrder(t *testing.T) {
+	consumer := &Consumer{
+		processor:   &processor{index: NewIndex(&fakeLister{}, workflow.BrokerKafka, "events")},
+		topic:       "events",
+		concurrency: Concurrency{Workers: 4},
+	}
+	handler := &consumerGroupHandler{consumer: consumer}
+	session := &fakeSession{ctx: context.Background()}
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 100)}
+	for i := 0; i < 100; i++ {
+		value := fmt.Sprintf(`{"event_id":"evt-%d","event_type":"user.created"}`, i)
+		if i%10 == 0 {
+			value = "not json"
+		}
+		claim.messages <- &sarama.ConsumerMessage{Topic: "events", Offset: int64(i), Key: []byte(fmt.Sprintf("user-%d", i%7)), Value: []byte(value)}
+	}
+	close(claim.messages)
+
+	if err := handler.ConsumeClaim(session, claim); err != nil {
+		t.Fatalf("consume claim: %v", err)
+	}
+	if len(session.marked) == 0 || session.marked[len(session.marked)-1] != 99 {
+		t.Fatalf("expected the last commit at offset 99, got %v", session.marked)
+	}
+	for i := 1; i < len(session.marked); i++ {
+		if session.marked[i] <= session.marked[i-1] {
+			t.Fatalf("commits went backwards: %v", session.marked)
+		}
+	}
+}
+
+type fakeSchemas struct {
+	mu       sync.Mutex
+	rejected []string
+}
+
+func (f *fakeSchemas) Validate(_ context.Context, eventType string, payload map[string]any) ([]string, error) {
+	if eventType != "user.created" {
+		return nil, nil
+	}
+	if _, ok := payload["context"].(map[string]any)["email"]; !ok {
+		return []string{"/context: missing property 'email'"}, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeSchemas) Reject(_ context.Context, _ string, payload map[string]any, _ []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rejected = append(f.rejected, payload["event_id"].(string))
+	return nil
+}
+
+func TestConsumeClaimRejectsEventsFailingSchema(t *testing.T) {
+	schemas := &fakeSchemas{}
+	consumer := &Consumer{
+		processor: &processor{index: NewIndex(&fakeLister{}, workflow.BrokerKafka, "events"), schemas: schemas},
+		topic:     "events",
+	}
+	handler := &consumerGroupHandler{consumer: consumer}
+	session := &fakeSession{ctx: context.Background()}
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 3)}
+	for i, value := range []string{
+		`{"event_id":"evt-0","event_type":"user.created","context":{"email":"a@b.c"}}`,
+		`{"event_id":"evt-1","event_type":"user.created","context":{}}`,
+		`{"event_id":"evt-2","event_type":"order.paid","context":{}}`,
+	} {
+		claim.messages <- &sarama.ConsumerMessage{Topic: "events", Offset: int64(i), Value: []byte(value)}
+	}
+	close(claim.messages)
+
+	if err := handler.ConsumeClaim(session, claim); err != nil {
+		t.Fatalf("consume claim: %v", err)
+	}
+	if want := []string{"evt-1"}; !reflect.DeepEqual(schemas.rejected, want) {
+		t.Fatalf("rejected %v, want %v", schemas.rejected, want)
+	}
+	if consumer.RejectedEvents() != 1 {
+		t.Fatalf("expected 1 rejected event, got %d", consumer.RejectedEvents())
+	}
+	if want := []int64{0, 1, 2}; !reflect.DeepEqual(session.marked, want) {
+		t.Fatalf("rejected events must be committed, got %v", session.marked)
+	}
+}
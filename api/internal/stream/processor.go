@@ -0,0 +1,285 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"notiair/internal/persistence/dedup"
+	"notiair/services"
+)
+
+// Source — чтение событий stream-триггеров из одного брокера: Consumer (Kafka),
+// RedisStreamConsumer или NATSConsumer. Разбор, подтверждение и DLQ у каждого свои,
+// обработка событий общая (processor).
+type Source interface {
+	// Start запускает чтение в фоновом режиме
+	Start(ctx context.Context) error
+	// Stop останавливает чтение и ждет обработки текущего сообщения
+	Stop() error
+	// InvalidateIndex сбрасывает кеш триггеров; вызывается при изменении workflows
+	InvalidateIndex(workflowID string)
+	// Health возвращает состояние чтения для health и readiness
+	Health(ctx context.Context) SourceHealth
+}
+
+// EventClaims учитывает запущенные пары (event_id, workflow_id): брокеры доставляют
+// сообщения хотя бы один раз, и повторная доставка не должна повторять уведомления.
+// Закрепление остается незавершенным, пока запуск не записан: если процесс упадет
+// между Claim и Complete, повторная доставка запустит workflow, а не пропустит событие.
+type EventClaims interface {
+	// Claim закрепляет пару и возвращает false, если она уже была запущена, или
+	// dedup.ErrInProgress, пока пару запускает другая доставка
+	Claim(ctx context.Context, eventID, workflowID string) (bool, error)
+	// Complete отмечает пару запущенной после успешного запуска
+	Complete(ctx context.Context, eventID, workflowID string) error
+	// Release снимает закрепление, если запуск не удался
+	Release(ctx context.Context, eventID, workflowID string) error
+}
+
+// EventSchemas проверяет события по JSON Schema их event_type. Событие, не прошедшее
+// проверку, не запускает workflows и записывается в журнал отклонений.
+type EventSchemas interface {
+	// Validate возвращает нарушения схемы; пусто — событие корректно или схемы нет
+	Validate(ctx context.Context, eventType string, payload map[string]any) ([]string, error)
+	// Reject записывает отклоненное событие в журнал
+	Reject(ctx context.Context, topic string, payload map[string]any, violations []string) error
+}
+
+// processor — обработка разобранного события, общая для брокеров: сохранение для
+// интерфейса, проверка схемы, поиск триггеров в индексе, дедупликация и запуск
+// workflows с повторами. Чтение, подтверждение и DLQ остаются за consumer брокера.
+type processor struct {
+	index           *Index // кеш триггеров: topic -> event_type -> workflows
+	notificationSvc *services.NotificationService
+	hub             *Hub        // WebSocket hub для отправки сообщений в интерфейс
+	redisStore      *RedisStore // Redis store для хранения сообщений
+	retry           RetryPolicy
+	claims          EventClaims  // уже запущенные пары (event_id, workflow_id)
+	schemas         EventSchemas // JSON Schema по event_type
+	source          string       // источник запуска в истории
+	duplicates      atomic.Int64 // сколько повторных запусков пропущено
+	rejected        atomic.Int64 // сколько событий отклонено по схеме
+}
+
+func newProcessor(index *Index, notificationSvc *services.NotificationService, hub *Hub, redisStore *RedisStore, retry RetryPolicy, claims EventClaims, schemas EventSchemas) *processor {
+	return &processor{
+		index:           index,
+		notificationSvc: notificationSvc,
+		hub:             hub,
+		redisStore:      redisStore,
+		retry:           retry,
+		claims:          claims,
+		schemas:         schemas,
+		source:          services.SourceStream,
+	}
+}
+
+// SkippedDuplicates возвращает число запусков, пропущенных как повторная доставка события
+func (p *processor) SkippedDuplicates() int64 {
+	return p.duplicates.Load()
+}
+
+// RejectedEvents возвращает число событий, отклоненных по JSON Schema
+func (p *processor) RejectedEvents() int64 {
+	return p.rejected.Load()
+}
+
+// InvalidateIndex сбрасывает кеш триггеров; вызывается при изменении workflows
+func (p *processor) InvalidateIndex(string) {
+	p.index.Invalidate()
+}
+
+// handle обрабатывает событие из топика. done = false — контекст завершился и событие
+// нужно прочитать снова; err — событие не обработано за attempts попыток и уходит в DLQ.
+// Событие, отклоненное по схеме, считается обработанным.
+func (p *processor) handle(ctx context.Context, topic string, event Event) (done bool, attempts int, err error) {
+	log.Printf("received event: %s (type: %s, topic: %s)", event.EventID, event.EventType, topic)
+
+	// Сохраняем сообщение в Redis
+	if p.redisStore != nil {
+		if err := p.redisStore.SaveMessage(ctx, event); err != nil {
+			log.Printf("failed to save message to redis: %v", err)
+		}
+	}
+
+	// Отправляем событие в WebSocket hub для интерфейса
+	if p.hub != nil {
+		p.hub.Broadcast(event)
+	}
+
+	// Событие, не подходящее под схему своего event_type, в workflows не попадает
+	accepted, err := p.checkSchema(ctx, topic, event)
+	if err != nil {
+		return false, 0, nil
+	}
+	if !accepted {
+		return true, 0, nil
+	}
+
+	attempts, err = p.processWithRetry(ctx, topic, event)
+	if err != nil && ctx.Err() != nil {
+		return false, attempts, nil
+	}
+	return true, attempts, err
+}
+
+// checkSchema проверяет событие по схеме и записывает отклоненное в журнал. Ошибки
+// хранилища схем повторяются с паузой, пока сессия не завершится.
+func (p *processor) checkSchema(ctx context.Context, topic string, event Event) (bool, error) {
+	if p.schemas == nil {
+		return true, nil
+	}
+	payload := eventPayload(event, topic)
+	for attempt := 1; ; attempt++ {
+		violations, err := p.schemas.Validate(ctx, event.EventType, payload)
+		if err == nil && len(violations) == 0 {
+			return true, nil
+		}
+		if err == nil {
+			if err = p.schemas.Reject(ctx, topic, payload, violations); err == nil {
+				log.Printf("rejected event %s (type: %s, topic: %s, rejected total: %d): %s",
+					event.EventID, event.EventType, topic, p.rejected.Add(1), strings.Join(violations, "; "))
+				return false, nil
+			}
+		}
+
+		delay := p.retry.Delay(attempt)
+		log.Printf("failed to check schema of event %s, retrying in %s: %v", event.EventID, delay, err)
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// complete отмечает пару запущенной. Workflow уже запущен, поэтому ошибка только
+// логируется: незавершенное закрепление перехватит повторная доставка после таймаута.
+func (p *processor) complete(ctx context.Context, eventID, workflowID string) {
+	if p.claims == nil || eventID == "" {
+		return
+	}
+	if err := p.claims.Complete(context.WithoutCancel(ctx), eventID, workflowID); err != nil {
+		log.Printf("failed to complete event %s for workflow %s: %v", eventID, workflowID, err)
+	}
+}
+
+// release снимает закрепление пары после неудачного запуска, чтобы повтор мог ее запустить
+func (p *processor) release(ctx context.Context, eventID, workflowID string) {
+	if p.claims == nil || eventID == "" {
+		return
+	}
+	// Снимаем закрепление и при завершении сессии, иначе событие не запустится при повторе
+	if err := p.claims.Release(context.WithoutCancel(ctx), eventID, workflowID); err != nil {
+		log.Printf("failed to release event %s for workflow %s: %v", eventID, workflowID, err)
+	}
+}
+
+// processWithRetry вызывает processEvent до retry.MaxRetries повторов с нарастающей паузой.
+// Workflows, уже запущенные на предыдущих попытках, повторно не запускаются.
+func (p *processor) processWithRetry(ctx context.Context, topic string, event Event) (int, error) {
+	dispatched := make(map[string]bool)
+	for attempt := 1; ; attempt++ {
+		err := p.processEvent(ctx, topic, event, dispatched)
+		if err == nil || attempt > p.retry.MaxRetries {
+			return attempt, err
+		}
+
+		delay := p.retry.Delay(attempt)
+		log.Printf("failed to process event %s (attempt %d), retrying in %s: %v", event.EventID, attempt, delay, err)
+		select {
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// processEvent обрабатывает событие и запускает соответствующие workflows.
+// Workflow запускается один раз от всех своих триггеров, которым подходит событие;
+// запущенные отмечаются в dispatched. Ошибка возвращается, если не запустился хотя бы один.
+func (p *processor) processEvent(ctx context.Context, topic string, event Event, dispatched map[string]bool) error {
+	// Ищем в индексе stream-триггеры с этим топиком, подходящим event_type и условиями where
+	matches, err := p.index.Lookup(ctx, topic, event)
+	if err != nil {
+		return fmt.Errorf("failed to list workflows: %w", err)
+	}
+
+	var failed []string
+	var errs []error
+	for _, match := range matches {
+		if dispatched[match.WorkflowID] {
+			continue
+		}
+
+		// Событие без event_id дедуплицировать нельзя — запускаем как есть
+		if p.claims != nil && event.EventID != "" {
+			claimed, err := p.claims.Claim(ctx, event.EventID, match.WorkflowID)
+			// Пару уже запускает другая доставка (ребаланс или повтор брокера): запуск и
+			// его повторы — ее забота, а ждать здесь значит увести событие в DLQ раньше,
+			// чем истечет dedup.PendingTimeout
+			if errors.Is(err, dedup.ErrInProgress) {
+				dispatched[match.WorkflowID] = true
+				log.Printf("skipped event %s for workflow %s: dispatched by another delivery (topic: %s, skipped total: %d)", event.EventID, match.WorkflowID, topic, p.duplicates.Add(1))
+				continue
+			}
+			if err != nil {
+				failed = append(failed, match.WorkflowID)
+				errs = append(errs, fmt.Errorf("claim event: %w", err))
+				continue
+			}
+			if !claimed {
+				dispatched[match.WorkflowID] = true
+				log.Printf("skipped duplicate event %s for workflow %s (topic: %s, skipped total: %d)", event.EventID, match.WorkflowID, topic, p.duplicates.Add(1))
+				continue
+			}
+		}
+
+		payload := eventPayload(event, topic)
+
+		// Запускаем workflow через NotificationService
+		// TODO: определить TemplateID и Variables из workflow
+		executionID, err := p.notificationSvc.Dispatch(ctx, services.DispatchInput{
+			WorkflowID: match.WorkflowID,
+			TemplateID: "", // Будет определено из workflow
+			Variables:  make(map[string]string),
+			Payload:    payload,
+			Source:     p.source,
+			TriggerIDs: match.TriggerIDs,
+		})
+
+		if err != nil {
+			log.Printf("failed to dispatch workflow %s for event %s (execution %s): %v", match.WorkflowID, event.EventID, executionID, err)
+			p.release(ctx, event.EventID, match.WorkflowID)
+			failed = append(failed, match.WorkflowID)
+			errs = append(errs, err)
+			continue
+		}
+		dispatched[match.WorkflowID] = true
+		p.complete(ctx, event.EventID, match.WorkflowID)
+
+		log.Printf("dispatched workflow %s for event %s (type: %s, topic: %s, execution %s)", match.WorkflowID, event.EventID, event.EventType, topic, executionID)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to dispatch workflows %s: %w", strings.Join(failed, ", "), errors.Join(errs...))
+	}
+	return nil
+}
+
+// eventPayload преобразует событие в payload для workflow
+func eventPayload(event Event, topic string) map[string]interface{} {
+	return map[string]interface{}{
+		"event_id":    event.EventID,
+		"event_type":  event.EventType,
+		"occurred_at": event.OccurredAt,
+		"context":     event.Context,
+		"metadata":    event.Metadata,
+		"topic":       topic,
+	}
+}
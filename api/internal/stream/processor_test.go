@@ -0,0 +1,122 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"notiair/internal/persistence/dedup"
+	"notiair/internal/routing"
+	"notiair/internal/workflow"
+	"notiair/services"
+)
+
+// recordingClaims записывает вызовы EventClaims
+type recordingClaims struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recordingClaims) record(call string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func (r *recordingClaims) Claim(_ context.Context, eventID, workflowID string) (bool, error) {
+	r.record("claim " + eventID + " " + workflowID)
+	return true, nil
+}
+
+func (r *recordingClaims) Complete(_ context.Context, eventID, workflowID string) error {
+	r.record("complete " + eventID + " " + workflowID)
+	return nil
+}
+
+func (r *recordingClaims) Release(_ context.Context, eventID, workflowID string) error {
+	r.record("release " + eventID + " " + workflowID)
+	return nil
+}
+
+// fakeRouter разрешает workflow без задач или с ошибкой
+type fakeRouter struct {
+	err error
+}
+
+func (f *fakeRouter) ResolveTargets(context.Context, string, map[string]any, ...string) (routing.Result, error) {
+	return routing.Result{}, f.err
+}
+
+func TestProcessEventCompletesClaimOnlyAfterDispatch(t *testing.T) {
+	for name, tc := range map[string]struct {
+		routeErr error
+		want     []string
+	}{
+		"dispatched": {want: []string{"claim evt-1 wf-1", "complete evt-1 wf-1"}},
+		"failed":     {routeErr: errors.New("workflow is broken"), want: []string{"claim evt-1 wf-1", "release evt-1 wf-1"}},
+	} {
+		claims := &recordingClaims{}
+		p := newProcessor(
+			NewIndex(&fakeLister{workflows: []workflow.Workflow{streamWorkflow("wf-1", true)}}, workflow.BrokerKafka, "events"),
+			services.NewNotificationService(&fakeRouter{err: tc.routeErr}, nil, nil, nil),
+			nil, nil, RetryPolicy{}, claims, nil,
+		)
+
+		err := p.processEvent(context.Background(), "orders", Event{EventID: "evt-1", EventType: "order.created"}, map[string]bool{})
+		if (err != nil) != (tc.routeErr != nil) {
+			t.Fatalf("%s: unexpected error %v", name, err)
+		}
+		if !reflect.DeepEqual(claims.calls, tc.want) {
+			t.Fatalf("%s: claims %v, want %v", name, claims.calls, tc.want)
+		}
+	}
+}
+
+// heldClaims — пару держит другая доставка: Claim всегда возвращает ErrInProgress
+type heldClaims struct {
+	recordingClaims
+}
+
+func (h *heldClaims) Claim(_ context.Context, eventID, workflowID string) (bool, error) {
+	h.record("claim " + eventID + " " + workflowID)
+	return false, dedup.ErrInProgress
+}
+
+func TestProcessEventSkipsClaimHeldByAnotherDelivery(t *testing.T) {
+	claims := &heldClaims{}
+	router := &countingRouter{}
+	p := newProcessor(
+		NewIndex(&fakeLister{workflows: []workflow.Workflow{streamWorkflow("wf-1", true)}}, workflow.BrokerKafka, "events"),
+		services.NewNotificationService(router, nil, nil, nil),
+		nil, nil, RetryPolicy{MaxRetries: 3}, claims, nil,
+	)
+
+	done, attempts, err := p.handle(context.Background(), "orders", Event{EventID: "evt-1", EventType: "order.created"})
+	if !done || err != nil {
+		t.Fatalf("event should be handled without DLQ, got done=%v err=%v", done, err)
+	}
+	if attempts != 1 {
+		t.Fatalf("held claim should not be retried, got %d attempts", attempts)
+	}
+	if router.calls != 0 {
+		t.Fatalf("workflow dispatched %d times while another delivery holds the claim", router.calls)
+	}
+	if !reflect.DeepEqual(claims.calls, []string{"claim evt-1 wf-1"}) {
+		t.Fatalf("claims %v", claims.calls)
+	}
+	if p.SkippedDuplicates() != 1 {
+		t.Fatalf("skipped duplicates = %d, want 1", p.SkippedDuplicates())
+	}
+}
+
+// countingRouter считает запуски workflow
+type countingRouter struct {
+	calls int
+}
+
+func (c *countingRouter) ResolveTargets(context.Context, string, map[string]any, ...string) (routing.Result, error) {
+	c.calls++
+	return routing.Result{}, nil
+}
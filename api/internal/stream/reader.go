@@ -0,0 +1,67 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// errPartitionIdle прерывает чтение партиции, в которой не осталось доставляемых сообщений
+var errPartitionIdle = errors.New("partition read is idle")
+
+// readPartition читает сообщения партиции в диапазоне offset [from, to) и передает их в fn.
+// Чтение прекращается, когда fn возвращает false, диапазон исчерпан или отменен ctx.
+func readPartition(ctx context.Context, consumer sarama.Consumer, topic string, partition int32, from, to int64, fn func(*sarama.ConsumerMessage) bool) error {
+	if from >= to {
+		return nil
+	}
+
+	pc, err := consumer.ConsumePartition(topic, partition, from)
+	if err != nil {
+		return fmt.Errorf("failed to consume %s/%d from offset %d: %w", topic, partition, from, err)
+	}
+	defer pc.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-pc.Errors():
+			if err != nil {
+				return err
+			}
+		case message, ok := <-pc.Messages():
+			if !ok || message == nil || message.Offset >= to {
+				return nil
+			}
+			if !fn(message) {
+				return nil
+			}
+			if message.Offset+1 >= to {
+				return nil
+			}
+		}
+	}
+}
+
+// readPartitionUntilIdle — readPartition, который считает партицию прочитанной, если
+// следующего сообщения нет дольше idle: offset из диапазона может не доставляться
+// никогда (control record транзакции). idled сообщает, что чтение прервано так.
+func readPartitionUntilIdle(ctx context.Context, consumer sarama.Consumer, topic string, partition int32, from, to int64, idle time.Duration, fn func(*sarama.ConsumerMessage) bool) (idled bool, err error) {
+	readCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	timer := time.AfterFunc(idle, func() { cancel(errPartitionIdle) })
+	defer timer.Stop()
+
+	err = readPartition(readCtx, consumer, topic, partition, from, to, func(message *sarama.ConsumerMessage) bool {
+		timer.Reset(idle)
+		return fn(message)
+	})
+	if err != nil && ctx.Err() == nil && errors.Is(context.Cause(readCtx), errPartitionIdle) {
+		return true, nil
+	}
+	return false, err
+}
@@ -0,0 +1,42 @@
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+)
+
+func TestReadPartitionUntilIdleStopsAtUndeliveredOffset(t *testing.T) {
+	consumer := mocks.NewConsumer(t, nil)
+	pc := consumer.ExpectConsumePartition("orders", 0, 0)
+	// Offset 2 — control record транзакции: брокер его не доставляет
+	pc.YieldMessage(&sarama.ConsumerMessage{Topic: "orders", Partition: 0, Offset: 0})
+	pc.YieldMessage(&sarama.ConsumerMessage{Topic: "orders", Partition: 0, Offset: 1})
+
+	var read []int64
+	done := make(chan struct{})
+	var idled bool
+	var err error
+	go func() {
+		defer close(done)
+		idled, err = readPartitionUntilIdle(context.Background(), consumer, "orders", 0, 0, 3, 50*time.Millisecond, func(message *sarama.ConsumerMessage) bool {
+			read = append(read, message.Offset)
+			return true
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("read did not stop at the undelivered offset")
+	}
+	if err != nil || !idled {
+		t.Fatalf("expected idle stop without error, got idled=%v err=%v", idled, err)
+	}
+	if len(read) != 2 {
+		t.Fatalf("read offsets %v, want [0 1]", read)
+	}
+}
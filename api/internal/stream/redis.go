@@ -4,17 +4,24 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"notiair/internal/workflow"
 )
 
 const (
 	// Redis key prefix для хранения сообщений по event types
 	redisKeyPrefix = "stream:messages:"
+	// Множество event types, для которых есть сообщения: по нему раскрываются шаблоны
+	// вместо KEYS, который блокирует общий Redis
+	redisTypesKey = "stream:message-types"
 	// Максимальное количество сообщений для каждого event type
 	maxMessagesPerType = 10
+	// Сколько хранятся сообщения без новых событий того же типа
+	messagesTTL = 24 * time.Hour
 )
 
 // RedisStore управляет хранением сообщений в Redis
@@ -66,7 +73,9 @@ func (r *RedisStore) SaveMessage(ctx context.Context, event Event) error {
 	pipe := r.client.Pipeline()
 	pipe.LPush(ctx, key, data)
 	pipe.LTrim(ctx, key, 0, maxMessagesPerType-1) // Оставляем только последние 10
-	pipe.Expire(ctx, key, 24*time.Hour)           // Устанавливаем TTL 24 часа
+	pipe.Expire(ctx, key, messagesTTL)            // Устанавливаем TTL 24 часа
+	pipe.SAdd(ctx, redisTypesKey, event.EventType)
+	pipe.Expire(ctx, redisTypesKey, messagesTTL)
 
 	_, err = pipe.Exec(ctx)
 	if err != nil {
@@ -82,33 +91,40 @@ func (r *RedisStore) GetRecentMessages(ctx context.Context, eventTypes []string,
 		limit = 10
 	}
 
-	var allEvents []Event
+	known, err := r.knownEventTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
 
+	// Без event types читаем все известные; шаблоны (order.*) раскрываем по ним
+	// так же, как триггеры сопоставляют event_type (workflow.MatchEventType)
+	var types []string
 	if len(eventTypes) == 0 {
-		// Если event types не указаны, получаем все ключи с префиксом
-		keys, err := r.client.Keys(ctx, redisKeyPrefix+"*").Result()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get keys: %w", err)
+		types = known
+	}
+	for _, eventType := range eventTypes {
+		if !strings.ContainsAny(eventType, "*?[") {
+			types = append(types, eventType)
+			continue
 		}
-
-		// Собираем сообщения из всех ключей
-		for _, key := range keys {
-			messages, err := r.getMessagesFromKey(ctx, key, limit)
-			if err != nil {
-				continue // Пропускаем ошибки для отдельных ключей
+		for _, t := range known {
+			if workflow.MatchEventType(eventType, t) {
+				types = append(types, t)
 			}
-			allEvents = append(allEvents, messages...)
 		}
-	} else {
-		// Получаем сообщения только для указанных event types
-		for _, eventType := range eventTypes {
-			key := redisKeyPrefix + eventType
-			messages, err := r.getMessagesFromKey(ctx, key, limit)
-			if err != nil {
-				continue // Пропускаем ошибки для отдельных ключей
-			}
-			allEvents = append(allEvents, messages...)
+	}
+
+	var allEvents []Event
+	for _, eventType := range types {
+		messages, err := r.getMessagesFromKey(ctx, redisKeyPrefix+eventType, limit)
+		if err != nil {
+			continue // Пропускаем ошибки для отдельных ключей
 		}
+		if len(messages) == 0 {
+			// Список истек по TTL — убираем тип из множества
+			r.client.SRem(ctx, redisTypesKey, eventType)
+		}
+		allEvents = append(allEvents, messages...)
 	}
 
 	// Сортируем по времени (новые первыми) и ограничиваем количество
@@ -120,6 +136,16 @@ func (r *RedisStore) GetRecentMessages(ctx context.Context, eventTypes []string,
 	return allEvents, nil
 }
 
+// knownEventTypes возвращает event types, для которых сохранялись сообщения
+func (r *RedisStore) knownEventTypes(ctx context.Context) ([]string, error) {
+	types, err := r.client.SMembers(ctx, redisTypesKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event types: %w", err)
+	}
+	sort.Strings(types)
+	return types, nil
+}
+
 // getMessagesFromKey получает сообщения из конкретного ключа Redis
 func (r *RedisStore) getMessagesFromKey(ctx context.Context, key string, limit int) ([]Event, error) {
 	// Получаем последние N сообщений из списка
@@ -140,6 +166,11 @@ func (r *RedisStore) getMessagesFromKey(ctx context.Context, key string, limit i
 	return events, nil
 }
 
+// Client возвращает клиент Redis, например для чтения Redis Streams
+func (r *RedisStore) Client() *redis.Client {
+	return r.client
+}
+
 // Close закрывает соединение с Redis
 func (r *RedisStore) Close() error {
 	return r.client.Close()
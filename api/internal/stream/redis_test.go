@@ -0,0 +1,46 @@
+package stream
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestGetRecentMessagesExpandsPatternsWithoutKeys(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := &RedisStore{client: redis.NewClient(&redis.Options{Addr: server.Addr()})}
+	t.Cleanup(func() { store.Close() })
+	ctx := context.Background()
+
+	for _, event := range []Event{
+		{EventID: "evt-1", EventType: "order.created"},
+		{EventID: "evt-2", EventType: "order.paid"},
+		{EventID: "evt-3", EventType: "user.created"},
+	} {
+		if err := store.SaveMessage(ctx, event); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+
+	got, err := store.GetRecentMessages(ctx, []string{"order.*"}, 10)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if len(got) != 2 || got[0].EventID != "evt-1" || got[1].EventID != "evt-2" {
+		t.Fatalf("unexpected messages %+v", got)
+	}
+
+	server.Del(redisKeyPrefix + "user.created")
+	got, err = store.GetRecentMessages(ctx, nil, 10)
+	if err != nil {
+		t.Fatalf("get all: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected messages of the two remaining types, got %+v", got)
+	}
+	if ok, _ := server.SIsMember(redisTypesKey, "user.created"); ok {
+		t.Fatal("expired event type should be dropped from the known types")
+	}
+}
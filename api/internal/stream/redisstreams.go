@@ -0,0 +1,361 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"notiair/internal/workflow"
+	"notiair/services"
+)
+
+// headerOriginalID — id записи Redis stream, попавшей в dead-letter stream
+const headerOriginalID = headerPrefix + "original-id"
+
+// RedisStreamsConfig — чтение stream-триггеров с broker = "redis" из Redis Streams
+type RedisStreamsConfig struct {
+	Group    string // consumer group; создается при первом чтении stream
+	Consumer string // имя consumer в группе, уникальное для реплики
+	// DefaultStream читают триггеры без topic; пусто — такие триггеры не читаются
+	DefaultStream string
+	BatchSize     int64
+	// Block — сколько XREADGROUP ждет новых записей; заодно период обновления списка streams
+	Block time.Duration
+	// ClaimIdle — через сколько неподтвержденную запись другого consumer можно забрать
+	ClaimIdle time.Duration
+	// DeadLetterSuffix добавляется к имени stream для dead-letter stream
+	DeadLetterSuffix string
+}
+
+// RedisStreamConsumer читает события из Redis Streams через consumer group (XREADGROUP),
+// подтверждает обработанные (XACK) и забирает зависшие записи упавших consumer (XAUTOCLAIM).
+// События обрабатываются тем же processor, что и у Kafka: индекс триггеров, схемы,
+// дедупликация, повторы. Необработанные записи уходят в stream <stream><DeadLetterSuffix>.
+type RedisStreamConsumer struct {
+	*processor
+	client *redis.Client
+	cfg    RedisStreamsConfig
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+	groups map[string]bool // streams, для которых группа уже создана
+	health healthState
+}
+
+// NewRedisStreamConsumer создает consumer Redis Streams
+func NewRedisStreamConsumer(
+	client *redis.Client,
+	cfg RedisStreamsConfig,
+	workflowRepo workflow.Repository,
+	notificationSvc *services.NotificationService,
+	hub *Hub,
+	redisStore *RedisStore,
+	retry RetryPolicy,
+	claims EventClaims,
+	schemas EventSchemas,
+) *RedisStreamConsumer {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 10
+	}
+	if cfg.Block <= 0 {
+		cfg.Block = 2 * time.Second
+	}
+	if cfg.ClaimIdle <= 0 {
+		cfg.ClaimIdle = time.Minute
+	}
+	if cfg.DeadLetterSuffix == "" {
+		cfg.DeadLetterSuffix = ":dlq"
+	}
+	return &RedisStreamConsumer{
+		processor: newProcessor(NewIndex(workflowRepo, workflow.BrokerRedis, cfg.DefaultStream), notificationSvc, hub, redisStore, retry, claims, schemas),
+		client:    client,
+		cfg:       cfg,
+		groups:    make(map[string]bool),
+	}
+}
+
+// Start запускает чтение в фоновом режиме
+func (c *RedisStreamConsumer) Start(ctx context.Context) error {
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		lastClaim := time.Time{}
+		for ctx.Err() == nil {
+			streams, err := c.index.Topics(ctx)
+			if err != nil {
+				delay := c.health.fail(err)
+				log.Printf("failed to list redis streams, retrying in %s: %v", delay, err)
+				c.sleep(ctx, delay)
+				continue
+			}
+			if len(streams) == 0 {
+				// Читать нечего — это не сбой
+				c.health.set(StateRunning)
+				c.sleep(ctx, c.cfg.Block)
+				continue
+			}
+
+			if time.Since(lastClaim) >= c.cfg.ClaimIdle/2 {
+				for _, stream := range streams {
+					if err := c.reclaim(ctx, stream); err != nil {
+						log.Printf("failed to reclaim pending entries of %s: %v", stream, err)
+					}
+				}
+				lastClaim = time.Now()
+			}
+
+			err = c.poll(ctx, streams)
+			switch {
+			case ctx.Err() != nil:
+			case err != nil:
+				delay := c.health.fail(err)
+				log.Printf("error from redis streams consumer, retrying in %s: %v", delay, err)
+				c.sleep(ctx, delay)
+			default:
+				c.health.set(StateRunning)
+			}
+		}
+	}()
+
+	log.Printf("redis streams consumer started (group: %s, consumer: %s)", c.cfg.Group, c.cfg.Consumer)
+	return nil
+}
+
+// Stop останавливает чтение и ждет обработки текущей записи
+func (c *RedisStreamConsumer) Stop() error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+	c.health.set(StateStopped)
+	log.Println("redis streams consumer stopped")
+	return nil
+}
+
+// Health возвращает состояние чтения; Lag — записи streams, еще не подтвержденные группой
+// (недоставленные считаются только на Redis 7+)
+func (c *RedisStreamConsumer) Health(ctx context.Context) SourceHealth {
+	health := c.health.report(workflow.BrokerRedis)
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	streams, err := c.index.Topics(ctx)
+	if err != nil {
+		return health
+	}
+	for _, stream := range streams {
+		groups, err := c.client.XInfoGroups(ctx, stream).Result()
+		if err != nil {
+			continue
+		}
+		for _, group := range groups {
+			if group.Name == c.cfg.Group {
+				health.Lag += group.Pending + max(group.Lag, 0)
+			}
+		}
+	}
+	return health
+}
+
+// poll читает новые записи streams (до Block) и обрабатывает их по порядку
+func (c *RedisStreamConsumer) poll(ctx context.Context, streams []string) (err error) {
+	defer recovered(&err)
+	for _, stream := range streams {
+		if err := c.ensureGroup(ctx, stream); err != nil {
+			return err
+		}
+	}
+
+	args := make([]string, 0, 2*len(streams))
+	args = append(args, streams...)
+	for range streams {
+		args = append(args, ">")
+	}
+	result, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    c.cfg.Group,
+		Consumer: c.cfg.Consumer,
+		Streams:  args,
+		Count:    c.cfg.BatchSize,
+		Block:    c.cfg.Block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "NOGROUP") {
+			// Stream удалили вместе с группой — создадим заново на следующем круге
+			c.groups = make(map[string]bool)
+		}
+		return fmt.Errorf("xreadgroup: %w", err)
+	}
+
+	for _, stream := range result {
+		for _, entry := range stream.Messages {
+			if !c.handleEntry(ctx, stream.Stream, entry) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// reclaim забирает записи, которые другой consumer группы получил, но не подтвердил за
+// ClaimIdle (например, реплика упала во время обработки), и обрабатывает их.
+func (c *RedisStreamConsumer) reclaim(ctx context.Context, stream string) error {
+	if err := c.ensureGroup(ctx, stream); err != nil {
+		return err
+	}
+	start := "0-0"
+	for {
+		entries, next, err := c.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   stream,
+			Group:    c.cfg.Group,
+			Consumer: c.cfg.Consumer,
+			MinIdle:  c.cfg.ClaimIdle,
+			Start:    start,
+			Count:    c.cfg.BatchSize,
+		}).Result()
+		if err != nil {
+			return fmt.Errorf("xautoclaim: %w", err)
+		}
+		for _, entry := range entries {
+			log.Printf("reclaimed pending entry %s/%s", stream, entry.ID)
+			if !c.handleEntry(ctx, stream, entry) {
+				return nil
+			}
+		}
+		if next == "0-0" || next == "" || len(entries) == 0 {
+			return nil
+		}
+		start = next
+	}
+}
+
+// ensureGroup создает consumer group, читающую stream с начала (как Kafka при новой группе)
+func (c *RedisStreamConsumer) ensureGroup(ctx context.Context, stream string) error {
+	if c.groups[stream] {
+		return nil
+	}
+	err := c.client.XGroupCreateMkStream(ctx, stream, c.cfg.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create group %s for stream %s: %w", c.cfg.Group, stream, err)
+	}
+	c.groups[stream] = true
+	return nil
+}
+
+// handleEntry обрабатывает запись и подтверждает ее. Возвращает false, если контекст
+// завершился до подтверждения: запись останется в pending и будет забрана повторно.
+func (c *RedisStreamConsumer) handleEntry(ctx context.Context, stream string, entry redis.XMessage) bool {
+	event, err := DecodeStreamEntry(entry.Values)
+	if err != nil {
+		log.Printf("failed to parse redis stream entry %s/%s: %v", stream, entry.ID, err)
+		return c.deadLetter(ctx, stream, entry, StageParse, 0, err) && c.ack(ctx, stream, entry.ID)
+	}
+
+	done, attempts, err := c.handle(ctx, stream, event)
+	if !done {
+		return false
+	}
+	if err != nil {
+		log.Printf("failed to process event %s after %d attempts: %v", event.EventID, attempts, err)
+		if !c.deadLetter(ctx, stream, entry, StageProcess, attempts, err) {
+			return false
+		}
+	}
+	return c.ack(ctx, stream, entry.ID)
+}
+
+func (c *RedisStreamConsumer) ack(ctx context.Context, stream, id string) bool {
+	if err := c.client.XAck(ctx, stream, c.cfg.Group, id).Err(); err != nil {
+		// Запись останется в pending и будет забрана через ClaimIdle; дубли отсечет EventClaims
+		log.Printf("failed to ack redis stream entry %s/%s: %v", stream, id, err)
+	}
+	return true
+}
+
+// deadLetter копирует запись в dead-letter stream с метаданными ошибки, повторяя XADD,
+// пока Redis недоступен. Возвращает false, если контекст завершился раньше.
+func (c *RedisStreamConsumer) deadLetter(ctx context.Context, stream string, entry redis.XMessage, stage string, attempts int, cause error) bool {
+	values := make(map[string]interface{}, len(entry.Values)+6)
+	for name, value := range entry.Values {
+		values[name] = value
+	}
+	values[headerError] = cause.Error()
+	values[headerStage] = stage
+	values[headerAttempts] = strconv.Itoa(attempts)
+	values[headerFailedAt] = time.Now().UTC().Format(time.RFC3339Nano)
+	values[headerOriginalTopic] = stream
+	values[headerOriginalID] = entry.ID
+
+	target := stream + c.cfg.DeadLetterSuffix
+	for attempt := 1; ; attempt++ {
+		err := c.client.XAdd(ctx, &redis.XAddArgs{Stream: target, Values: values}).Err()
+		if err == nil {
+			log.Printf("redis stream entry %s/%s sent to dead-letter stream %s (stage: %s)", stream, entry.ID, target, stage)
+			return true
+		}
+		delay := c.retry.Delay(attempt)
+		log.Printf("failed to add redis stream entry %s/%s to dead-letter stream, retrying in %s: %v", stream, entry.ID, delay, err)
+		if !c.sleep(ctx, delay) {
+			return false
+		}
+	}
+}
+
+// sleep ждет d и возвращает false, если контекст завершился раньше
+func (c *RedisStreamConsumer) sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// DecodeStreamEntry разбирает запись Redis stream в Event. Поле data разбирается как тело
+// сообщения Kafka (собственный формат или CloudEvents, атрибуты binary-режима — в полях
+// ce_*, тип содержимого — в content-type). Без data событие читается из полей event_id,
+// event_type, occurred_at и JSON-полей context и metadata.
+func DecodeStreamEntry(values map[string]interface{}) (Event, error) {
+	field := func(name string) string {
+		s, _ := values[name].(string)
+		return s
+	}
+
+	if data, ok := values["data"]; ok {
+		attrs := make(map[string]string)
+		for name, value := range values {
+			lower := strings.ToLower(name)
+			if strings.HasPrefix(lower, cloudEventsHeaderPrefix) {
+				attrs[strings.TrimPrefix(lower, cloudEventsHeaderPrefix)] = fmt.Sprint(value)
+			}
+		}
+		return decodeEvent(attrs, field("content-type"), []byte(fmt.Sprint(data)))
+	}
+
+	event := Event{
+		EventID:    field("event_id"),
+		EventType:  field("event_type"),
+		OccurredAt: field("occurred_at"),
+	}
+	if event.EventType == "" {
+		return Event{}, errors.New("entry has neither data nor event_type")
+	}
+	for name, target := range map[string]*map[string]interface{}{"context": &event.Context, "metadata": &event.Metadata} {
+		raw := field(name)
+		if raw == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(raw), target); err != nil {
+			return Event{}, fmt.Errorf("field %s: %w", name, err)
+		}
+	}
+	return event, nil
+}
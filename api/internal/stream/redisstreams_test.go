@@ -0,0 +1,136 @@
+package stream
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"notiair/internal/workflow"
+)
+
+func newTestRedisConsumer(t *testing.T, schemas EventSchemas) (*RedisStreamConsumer, *redis.Client) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	consumer := NewRedisStreamConsumer(client, RedisStreamsConfig{
+		Group:         "notiair",
+		Consumer:      "replica-1",
+		DefaultStream: "events",
+		Block:         50 * time.Millisecond,
+		ClaimIdle:     time.Millisecond,
+	}, nil, nil, nil, nil, RetryPolicy{}, nil, schemas)
+	consumer.index = NewIndex(&fakeLister{}, workflow.BrokerRedis, "events")
+	return consumer, client
+}
+
+func TestDecodeStreamEntryFormats(t *testing.T) {
+	for name, values := range map[string]map[string]interface{}{
+		"fields": {
+			"event_id":   "evt-1",
+			"event_type": "order.created",
+			"context":    `{"order":{"amount":150}}`,
+			"metadata":   `{"tenant":"acme"}`,
+		},
+		"data": {
+			"data": `{"event_id":"evt-1","event_type":"order.created","context":{"order":{"amount":150}},"metadata":{"tenant":"acme"}}`,
+		},
+		"binary": {
+			"ce_specversion": "1.0",
+			"ce_id":          "evt-1",
+			"ce_type":        "order.created",
+			"ce_source":      "/shop",
+			"ce_tenant":      "acme",
+			"content-type":   "application/json",
+			"data":           `{"order":{"amount":150}}`,
+		},
+	} {
+		event, err := DecodeStreamEntry(values)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		order, _ := event.Context["order"].(map[string]any)
+		if event.EventID != "evt-1" || event.EventType != "order.created" || order["amount"] != float64(150) || event.Metadata["tenant"] != "acme" {
+			t.Fatalf("%s: unexpected event %+v", name, event)
+		}
+	}
+
+	if _, err := DecodeStreamEntry(map[string]interface{}{"event_id": "evt-1"}); err == nil {
+		t.Fatal("expected error for entry without event_type")
+	}
+}
+
+func TestRedisStreamConsumerAcksAndDeadLetters(t *testing.T) {
+	schemas := &fakeSchemas{}
+	consumer, client := newTestRedisConsumer(t, schemas)
+	ctx := context.Background()
+
+	for _, values := range []map[string]interface{}{
+		{"event_id": "evt-0", "event_type": "user.created", "context": `{"email":"a@b.c"}`},
+		{"event_id": "evt-1", "event_type": "user.created", "context": `{}`},
+		{"event_id": "evt-2"},
+	} {
+		if err := client.XAdd(ctx, &redis.XAddArgs{Stream: "events", Values: values}).Err(); err != nil {
+			t.Fatalf("xadd: %v", err)
+		}
+	}
+
+	if err := consumer.poll(ctx, []string{"events"}); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+
+	if want := []string{"evt-1"}; !reflect.DeepEqual(schemas.rejected, want) {
+		t.Fatalf("rejected %v, want %v", schemas.rejected, want)
+	}
+	pending, err := client.XPending(ctx, "events", "notiair").Result()
+	if err != nil {
+		t.Fatalf("xpending: %v", err)
+	}
+	if pending.Count != 0 {
+		t.Fatalf("expected every entry to be acked, %d pending", pending.Count)
+	}
+
+	dead, err := client.XRange(ctx, "events:dlq", "-", "+").Result()
+	if err != nil {
+		t.Fatalf("xrange: %v", err)
+	}
+	if len(dead) != 1 || dead[0].Values["event_id"] != "evt-2" || dead[0].Values[headerStage] != StageParse || dead[0].Values[headerOriginalTopic] != "events" {
+		t.Fatalf("unexpected dead-letter entries %+v", dead)
+	}
+}
+
+func TestRedisStreamConsumerReclaimsPendingEntries(t *testing.T) {
+	schemas := &fakeSchemas{}
+	consumer, client := newTestRedisConsumer(t, schemas)
+	ctx := context.Background()
+
+	if err := client.XGroupCreateMkStream(ctx, "events", "notiair", "0").Err(); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	if err := client.XAdd(ctx, &redis.XAddArgs{Stream: "events", Values: map[string]interface{}{"event_id": "evt-1", "event_type": "user.created", "context": `{}`}}).Err(); err != nil {
+		t.Fatalf("xadd: %v", err)
+	}
+	// Другая реплика получила запись и упала, не подтвердив ее
+	if err := client.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "notiair", Consumer: "replica-2", Streams: []string{"events", ">"}}).Err(); err != nil {
+		t.Fatalf("xreadgroup: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if err := consumer.reclaim(ctx, "events"); err != nil {
+		t.Fatalf("reclaim: %v", err)
+	}
+	if want := []string{"evt-1"}; !reflect.DeepEqual(schemas.rejected, want) {
+		t.Fatalf("reclaimed entry was not processed, rejected %v", schemas.rejected)
+	}
+	pending, err := client.XPending(ctx, "events", "notiair").Result()
+	if err != nil {
+		t.Fatalf("xpending: %v", err)
+	}
+	if pending.Count != 0 {
+		t.Fatalf("expected reclaimed entry to be acked, %d pending", pending.Count)
+	}
+}
@@ -0,0 +1,134 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// replayJobKeyPrefix — ключи состояния replay в Redis: их видят все реплики API
+	replayJobKeyPrefix = "stream:replay:"
+	// replayJobTTL — сколько хранится состояние replay после последнего обновления
+	replayJobTTL = 24 * time.Hour
+	// replayHeartbeatInterval — как часто реплика, ведущая replay, обновляет его состояние
+	replayHeartbeatInterval = 10 * time.Second
+	// replayStaleAfter — replay без обновлений дольше этого считается брошенным: реплика
+	// упала или была остановлена
+	replayStaleAfter = 3 * replayHeartbeatInterval
+)
+
+// Состояния replay
+const (
+	ReplayRunning   = "running"
+	ReplayCompleted = "completed"
+	ReplayFailed    = "failed"
+)
+
+// ErrReplayJobNotFound — replay с таким ID нет или его состояние уже удалено
+var ErrReplayJobNotFound = errors.New("replay job not found")
+
+// ReplayJob — replay, запущенный в фоне; Result обновляется по мере обработки
+type ReplayJob struct {
+	ID         string       `json:"id"`
+	WorkflowID string       `json:"workflowId"`
+	Topic      string       `json:"topic"`
+	From       time.Time    `json:"from"`
+	To         time.Time    `json:"to"`
+	Status     string       `json:"status"`
+	Result     ReplayResult `json:"result"`
+	Error      string       `json:"error,omitempty"`
+	// Owner — реплика, которая ведет replay; UpdatedAt — ее последний heartbeat
+	Owner      string     `json:"owner"`
+	StartedAt  time.Time  `json:"startedAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// replayJobs хранит состояние replay в Redis, а без него — в памяти реплики
+type replayJobs struct {
+	redisStore *RedisStore
+	owner      string // имя этой реплики
+
+	mu   sync.Mutex
+	jobs map[string]ReplayJob
+}
+
+func newReplayJobs(redisStore *RedisStore) *replayJobs {
+	owner, err := os.Hostname()
+	if err != nil || owner == "" {
+		owner = "unknown"
+	}
+	owner = fmt.Sprintf("%s/%d", owner, os.Getpid())
+	return &replayJobs{redisStore: redisStore, owner: owner, jobs: make(map[string]ReplayJob)}
+}
+
+// save записывает состояние replay и удаляет из памяти устаревшие
+func (r *replayJobs) save(ctx context.Context, job ReplayJob) error {
+	job.UpdatedAt = time.Now().UTC()
+	if r.redisStore != nil {
+		data, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		if err := r.redisStore.client.Set(ctx, replayJobKeyPrefix+job.ID, data, replayJobTTL).Err(); err != nil {
+			return fmt.Errorf("failed to save replay job %s: %w", job.ID, err)
+		}
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, stored := range r.jobs {
+		if time.Since(stored.UpdatedAt) > replayJobTTL {
+			delete(r.jobs, id)
+		}
+	}
+	r.jobs[job.ID] = job
+	return nil
+}
+
+// get возвращает состояние replay. Идущий replay без heartbeat дольше replayStaleAfter
+// возвращается как failed: реплика, которая его вела, больше его не закончит.
+func (r *replayJobs) get(ctx context.Context, id string) (ReplayJob, error) {
+	job, err := r.load(ctx, id)
+	if err != nil {
+		return ReplayJob{}, err
+	}
+	if job.Status == ReplayRunning && time.Since(job.UpdatedAt) > replayStaleAfter {
+		job.Status = ReplayFailed
+		job.Error = fmt.Sprintf("replay was abandoned: replica %s has not reported since %s", job.Owner, job.UpdatedAt.Format(time.RFC3339))
+	}
+	return job, nil
+}
+
+func (r *replayJobs) load(ctx context.Context, id string) (ReplayJob, error) {
+	if r.redisStore == nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		job, ok := r.jobs[id]
+		if !ok {
+			return ReplayJob{}, ErrReplayJobNotFound
+		}
+		return job, nil
+	}
+
+	data, err := r.redisStore.client.Get(ctx, replayJobKeyPrefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return ReplayJob{}, ErrReplayJobNotFound
+	}
+	if err != nil {
+		return ReplayJob{}, fmt.Errorf("failed to load replay job %s: %w", id, err)
+	}
+	var job ReplayJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return ReplayJob{}, fmt.Errorf("failed to decode replay job %s: %w", id, err)
+	}
+	return job, nil
+}
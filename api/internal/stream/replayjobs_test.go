@@ -0,0 +1,86 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestReplayJobsAreSharedThroughRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := &RedisStore{client: redis.NewClient(&redis.Options{Addr: server.Addr()})}
+	t.Cleanup(func() { store.Close() })
+	ctx := context.Background()
+
+	// Состояние, сохраненное одной репликой, видно другой
+	started, other := newReplayJobs(store), newReplayJobs(store)
+	job := ReplayJob{ID: "job-1", Topic: "orders", Status: ReplayRunning}
+	if err := started.save(ctx, job); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	job.Status, job.Result.Dispatched = ReplayCompleted, 3
+	if err := started.save(ctx, job); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	got, err := other.get(ctx, "job-1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Status != ReplayCompleted || got.Result.Dispatched != 3 || got.UpdatedAt.IsZero() {
+		t.Fatalf("unexpected job %+v", got)
+	}
+	if ttl := server.TTL(replayJobKeyPrefix + "job-1"); ttl != replayJobTTL {
+		t.Fatalf("job ttl %s, want %s", ttl, replayJobTTL)
+	}
+	if _, err := other.get(ctx, "missing"); !errors.Is(err, ErrReplayJobNotFound) {
+		t.Fatalf("expected ErrReplayJobNotFound, got %v", err)
+	}
+}
+
+func TestReplayJobsWithoutRedisStayInMemory(t *testing.T) {
+	ctx := context.Background()
+	jobs := newReplayJobs(nil)
+	if err := jobs.save(ctx, ReplayJob{ID: "job-1", Status: ReplayRunning}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if got, err := jobs.get(ctx, "job-1"); err != nil || got.Status != ReplayRunning {
+		t.Fatalf("get: %+v, %v", got, err)
+	}
+	if _, err := jobs.get(ctx, "missing"); !errors.Is(err, ErrReplayJobNotFound) {
+		t.Fatalf("expected ErrReplayJobNotFound, got %v", err)
+	}
+}
+
+func TestStartReplayRequiresStreamTrigger(t *testing.T) {
+	consumer := &Consumer{topic: "events", replays: newReplayJobs(nil)}
+	_, err := consumer.StartReplay(context.Background(), ReplayRequest{Topic: "orders", From: time.Unix(0, 0), To: time.Unix(60, 0)})
+	if !errors.Is(err, ErrNoStreamTrigger) {
+		t.Fatalf("expected ErrNoStreamTrigger, got %v", err)
+	}
+}
+
+func TestReplayJobWithoutHeartbeatIsReportedFailed(t *testing.T) {
+	ctx := context.Background()
+	jobs := newReplayJobs(nil)
+	if err := jobs.save(ctx, ReplayJob{ID: "live", Status: ReplayRunning, Owner: jobs.owner}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	// Реплика, которая вела replay, перестала обновлять его состояние
+	jobs.jobs["abandoned"] = ReplayJob{ID: "abandoned", Status: ReplayRunning, Owner: "api-2/7", UpdatedAt: time.Now().Add(-replayStaleAfter - time.Second)}
+
+	if got, err := jobs.get(ctx, "live"); err != nil || got.Status != ReplayRunning {
+		t.Fatalf("live job: %+v, %v", got, err)
+	}
+	got, err := jobs.get(ctx, "abandoned")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Status != ReplayFailed || got.Error == "" {
+		t.Fatalf("abandoned job should be reported failed, got %+v", got)
+	}
+}
@@ -0,0 +1,75 @@
+package stream
+
+import (
+	"sort"
+
+	"notiair/internal/workflow"
+)
+
+// StreamTrigger — stream-триггер workflow: брокер, топик, event types (точные или glob) и условия where
+type StreamTrigger struct {
+	NodeID string
+	Broker string
+	Topic  string
+	Config workflow.StreamConfig
+}
+
+// Matches проверяет, что событие из топика подходит триггеру
+func (t StreamTrigger) Matches(topic string, event Event) bool {
+	return t.readsTopic(topic) && t.Config.Matches(event.EventType, event.Context, event.Metadata)
+}
+
+// readsTopic сравнивает топик триггера с топиком события; у NATS топик триггера —
+// subject с wildcards "*" и ">", у события — конкретный subject
+func (t StreamTrigger) readsTopic(topic string) bool {
+	if t.Broker == workflow.BrokerNATS {
+		return workflow.MatchSubject(t.Topic, topic)
+	}
+	return t.Topic == topic
+}
+
+// StreamTriggers возвращает триггеры workflow с triggerKind = "stream", читающие broker
+// (workflow.BrokerKafka, workflow.BrokerRedis, workflow.BrokerNATS). Триггер без топика читает defaultTopic;
+// если и он пуст, триггер пропускается.
+func StreamTriggers(wf workflow.Workflow, broker, defaultTopic string) []StreamTrigger {
+	var triggers []StreamTrigger
+	for _, node := range wf.Nodes {
+		cfg, ok := workflow.ParseStreamConfig(node)
+		if !ok || cfg.BrokerName() != broker {
+			continue
+		}
+		topic := cfg.Topic
+		if topic == "" {
+			topic = defaultTopic
+		}
+		if topic == "" {
+			continue
+		}
+		triggers = append(triggers, StreamTrigger{NodeID: node.ID, Broker: broker, Topic: topic, Config: cfg})
+	}
+	return triggers
+}
+
+// SubscribedTopics возвращает отсортированное объединение топиков триггеров broker активных workflows.
+// defaultTopic читается всегда, чтобы интерфейс видел поток событий и без активных workflow.
+func SubscribedTopics(workflows []workflow.Workflow, broker, defaultTopic string) []string {
+	seen := map[string]bool{}
+	if defaultTopic != "" {
+		seen[defaultTopic] = true
+	}
+	for _, wf := range workflows {
+		if !wf.IsActive {
+			continue
+		}
+		for _, trigger := range StreamTriggers(wf, broker, defaultTopic) {
+			seen[trigger.Topic] = true
+		}
+	}
+
+	topics := make([]string, 0, len(seen))
+	for topic := range seen {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
@@ -0,0 +1,43 @@
+package stream
+
+import (
+	"reflect"
+	"testing"
+
+	"notiair/internal/workflow"
+)
+
+func streamWorkflow(id string, active bool) workflow.Workflow {
+	return workflow.Workflow{
+		ID:       id,
+		IsActive: active,
+		Nodes: []workflow.Node{
+			{ID: "orders", Type: workflow.NodeTypeTrigger, Config: map[string]any{"label": "Orders feed", "triggerKind": "stream", "topic": "orders", "eventTypes": []string{"order.created"}}},
+			{ID: "default", Type: workflow.NodeTypeTrigger, Config: map[string]any{"label": "Stream broker", "eventTypes": []string{"user.created"}}},
+			{ID: "manual", Type: workflow.NodeTypeTrigger, Config: map[string]any{"label": "Trigger"}},
+		},
+	}
+}
+
+func TestStreamTriggersUseDefaultTopic(t *testing.T) {
+	triggers := StreamTriggers(streamWorkflow("wf-1", true), workflow.BrokerKafka, "events")
+	if len(triggers) != 2 {
+		t.Fatalf("expected 2 stream triggers, got %+v", triggers)
+	}
+	if !triggers[0].Matches("orders", Event{EventType: "order.created"}) || triggers[0].Matches("events", Event{EventType: "order.created"}) {
+		t.Fatalf("orders trigger matched wrong topic: %+v", triggers[0])
+	}
+	if !triggers[1].Matches("events", Event{EventType: "user.created"}) {
+		t.Fatalf("default trigger should read the default topic: %+v", triggers[1])
+	}
+}
+
+func TestSubscribedTopicsUnionOfActiveWorkflows(t *testing.T) {
+	inactive := streamWorkflow("wf-2", false)
+	inactive.Nodes[0].Config = map[string]any{"label": "Stream broker", "topic": "refunds"}
+
+	got := SubscribedTopics([]workflow.Workflow{streamWorkflow("wf-1", true), inactive}, workflow.BrokerKafka, "events")
+	if want := []string{"events", "orders"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("topics %v, want %v", got, want)
+	}
+}
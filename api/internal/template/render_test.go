@@ -30,3 +30,19 @@ func TestRenderMissing(t *testing.T) {
 		t.Fatalf("got %q", got)
 	}
 }
+
+func TestValidate(t *testing.T) {
+	valid := []string{"", "plain text", "Hi {{name}}", "{{ user.email }} and {{id}}"}
+	for _, body := range valid {
+		if err := Validate(body); err != nil {
+			t.Fatalf("Validate(%q) = %v, want nil", body, err)
+		}
+	}
+
+	invalid := []string{"Hi {{name", "{{}}", "{{  }}", "{{user..email}}", "{{a{b}}"}
+	for _, body := range invalid {
+		if err := Validate(body); err == nil {
+			t.Fatalf("Validate(%q) = nil, want error", body)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package template
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validate reports the first malformed {{placeholder}} in body: unclosed braces,
+// empty placeholders or empty path segments such as {{user..name}}.
+func Validate(body string) error {
+	rest := body
+	offset := 0
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			return nil
+		}
+		end := strings.Index(rest[start+2:], "}}")
+		if end < 0 {
+			return fmt.Errorf("unclosed placeholder at offset %d", offset+start)
+		}
+
+		inner := rest[start+2 : start+2+end]
+		path := strings.TrimSpace(inner)
+		switch {
+		case path == "":
+			return fmt.Errorf("empty placeholder at offset %d", offset+start)
+		case strings.ContainsAny(path, "{}"):
+			return fmt.Errorf("unexpected brace in placeholder %q", path)
+		}
+		for _, part := range strings.Split(path, ".") {
+			if strings.TrimSpace(part) == "" {
+				return fmt.Errorf("empty path segment in placeholder %q", path)
+			}
+		}
+
+		consumed := start + 2 + end + 2
+		rest = rest[consumed:]
+		offset += consumed
+	}
+}
@@ -0,0 +1,177 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"notiair/internal/cloudevents"
+)
+
+const (
+	HeaderGitHub  = "X-Hub-Signature-256"
+	HeaderStripe  = "Stripe-Signature"
+	HeaderGeneric = "X-Signature"
+
+	// StripeTolerance bounds how old a Stripe-style signed timestamp may be.
+	StripeTolerance = 5 * time.Minute
+)
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Request is the part of an inbound HTTP call a webhook trigger needs.
+type Request struct {
+	Method      string
+	ContentType string
+	Headers     map[string]string
+	Query       map[string]string
+	Body        []byte
+}
+
+// Verify checks the HMAC-SHA256 signature of the request against secret. Supported
+// headers are GitHub's X-Hub-Signature-256 (sha256=<hex>), Stripe's Stripe-Signature
+// (t=<unix>,v1=<hex> over "<t>.<body>") and a generic X-Signature (<hex> or sha256=<hex>).
+func Verify(secret string, req Request, now time.Time) error {
+	if secret == "" {
+		return nil
+	}
+
+	if value := header(req.Headers, HeaderStripe); value != "" {
+		return verifyStripe(secret, value, req.Body, now)
+	}
+	for _, name := range []string{HeaderGitHub, HeaderGeneric} {
+		if value := header(req.Headers, name); value != "" {
+			if validHex(secret, strings.TrimPrefix(value, "sha256="), req.Body) {
+				return nil
+			}
+			return ErrInvalidSignature
+		}
+	}
+	return fmt.Errorf("%w: signature header is missing", ErrInvalidSignature)
+}
+
+func verifyStripe(secret, value string, body []byte, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = val
+		case "v1":
+			signatures = append(signatures, val)
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad timestamp", ErrInvalidSignature)
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > StripeTolerance || age < -StripeTolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+
+	signed := append([]byte(timestamp+"."), body...)
+	for _, sig := range signatures {
+		if validHex(secret, sig, signed) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func validHex(secret, signature string, data []byte) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(data)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func header(headers map[string]string, name string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// hiddenHeaders are credentials that must not end up in execution history.
+var hiddenHeaders = map[string]bool{
+	"authorization":       true,
+	"cookie":              true,
+	"x-hub-signature":     true,
+	"x-hub-signature-256": true,
+	"stripe-signature":    true,
+	"x-signature":         true,
+}
+
+// Payload maps the request into the workflow payload: {method, headers, query, body}.
+// JSON bodies are decoded, anything else is passed as a string.
+func Payload(req Request, receivedAt time.Time) map[string]any {
+	headers := make(map[string]any, len(req.Headers))
+	for k, v := range req.Headers {
+		name := strings.ToLower(k)
+		if hiddenHeaders[name] {
+			continue
+		}
+		headers[name] = v
+	}
+
+	query := make(map[string]any, len(req.Query))
+	for k, v := range req.Query {
+		query[k] = v
+	}
+
+	var body any = string(req.Body)
+	if strings.Contains(req.ContentType, "json") && len(req.Body) > 0 {
+		var decoded any
+		if err := json.Unmarshal(req.Body, &decoded); err == nil {
+			body = decoded
+		}
+	}
+
+	return map[string]any{
+		"method":     req.Method,
+		"headers":    headers,
+		"query":      query,
+		"body":       body,
+		"receivedAt": receivedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// cloudEventsHeaderPrefix marks CloudEvents attributes in HTTP binary mode.
+const cloudEventsHeaderPrefix = "ce-"
+
+// ParseCloudEvent recognises a CloudEvent in binary mode (ce-* headers) or structured
+// mode (application/cloudevents+json). ok is false for ordinary requests.
+func ParseCloudEvent(req Request) (ev cloudevents.Event, ok bool, err error) {
+	attrs := make(map[string]string)
+	for k, v := range req.Headers {
+		name := strings.ToLower(k)
+		if strings.HasPrefix(name, cloudEventsHeaderPrefix) {
+			attrs[strings.TrimPrefix(name, cloudEventsHeaderPrefix)] = v
+		}
+	}
+	if _, binary := attrs["specversion"]; binary {
+		ev, err = cloudevents.FromBinary(attrs, req.ContentType, req.Body)
+		return ev, true, err
+	}
+	if strings.HasPrefix(strings.ToLower(req.ContentType), cloudevents.ContentType) {
+		ev, err = cloudevents.ParseStructured(req.Body)
+		return ev, true, err
+	}
+	return cloudevents.Event{}, false, nil
+}
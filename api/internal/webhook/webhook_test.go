@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func sign(secret string, data []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"action":"opened"}`)
+	now := time.Unix(1_700_000_000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	stale := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+
+	cases := []struct {
+		name    string
+		headers map[string]string
+		ok      bool
+	}{
+		{"github", map[string]string{"X-Hub-Signature-256": "sha256=" + sign("s3cret", body)}, true},
+		{"generic", map[string]string{"X-Signature": sign("s3cret", body)}, true},
+		{"stripe", map[string]string{"Stripe-Signature": "t=" + ts + ",v1=" + sign("s3cret", []byte(ts+"."+string(body)))}, true},
+		{"stripe stale", map[string]string{"Stripe-Signature": "t=" + stale + ",v1=" + sign("s3cret", []byte(stale+"."+string(body)))}, false},
+		{"wrong secret", map[string]string{"X-Hub-Signature-256": "sha256=" + sign("other", body)}, false},
+		{"missing", map[string]string{}, false},
+	}
+	for _, c := range cases {
+		err := Verify("s3cret", Request{Headers: c.headers, Body: body}, now)
+		if c.ok && err != nil {
+			t.Fatalf("%s: unexpected error %v", c.name, err)
+		}
+		if !c.ok && !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("%s: expected ErrInvalidSignature, got %v", c.name, err)
+		}
+	}
+
+	if err := Verify("", Request{Body: body}, now); err != nil {
+		t.Fatalf("no secret should skip verification, got %v", err)
+	}
+}
+
+func TestPayload(t *testing.T) {
+	payload := Payload(Request{
+		Method:      "POST",
+		ContentType: "application/json",
+		Headers:     map[string]string{"Authorization": "Bearer x", "X-Request-Id": "r-1"},
+		Query:       map[string]string{"env": "prod"},
+		Body:        []byte(`{"order":{"id":42}}`),
+	}, time.Unix(0, 0))
+
+	headers := payload["headers"].(map[string]any)
+	if _, leaked := headers["authorization"]; leaked {
+		t.Fatalf("authorization header must not be copied: %v", headers)
+	}
+	if headers["x-request-id"] != "r-1" {
+		t.Fatalf("unexpected headers %v", headers)
+	}
+	if payload["query"].(map[string]any)["env"] != "prod" {
+		t.Fatalf("unexpected query %v", payload["query"])
+	}
+	order := payload["body"].(map[string]any)["order"].(map[string]any)
+	if order["id"] != float64(42) {
+		t.Fatalf("unexpected body %v", payload["body"])
+	}
+}
+
+func TestParseCloudEvent(t *testing.T) {
+	binary := Request{
+		ContentType: "application/json",
+		Headers:     map[string]string{"Ce-Specversion": "1.0", "Ce-Id": "e-1", "Ce-Type": "order.created", "Ce-Source": "/shop"},
+		Body:        []byte(`{"order":{"id":42}}`),
+	}
+	ev, ok, err := ParseCloudEvent(binary)
+	if err != nil || !ok || ev.Type != "order.created" || ev.Context()["order"] == nil {
+		t.Fatalf("binary: %+v, %v, %v", ev, ok, err)
+	}
+
+	structured := Request{
+		ContentType: "application/cloudevents+json; charset=utf-8",
+		Body:        []byte(`{"specversion":"1.0","id":"e-1","type":"order.created","source":"/shop","data":{"order":{"id":42}}}`),
+	}
+	if ev, ok, err := ParseCloudEvent(structured); err != nil || !ok || ev.ID != "e-1" {
+		t.Fatalf("structured: %+v, %v, %v", ev, ok, err)
+	}
+
+	if _, ok, _ := ParseCloudEvent(Request{ContentType: "application/json", Body: []byte(`{"a":1}`)}); ok {
+		t.Fatal("plain JSON request is not a CloudEvent")
+	}
+}
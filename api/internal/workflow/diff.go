@@ -0,0 +1,203 @@
+package workflow
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// FieldChange is a single value that differs between two versions. Before or After is
+// nil when the field was added or removed.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+type NodeChange struct {
+	NodeID string        `json:"nodeId"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// VersionDiff describes how version To differs from version From.
+type VersionDiff struct {
+	From          VersionMeta   `json:"from"`
+	To            VersionMeta   `json:"to"`
+	Fields        []FieldChange `json:"fields"`
+	AddedNodes    []Node        `json:"addedNodes"`
+	RemovedNodes  []Node        `json:"removedNodes"`
+	ChangedNodes  []NodeChange  `json:"changedNodes"`
+	AddedEdges    []Edge        `json:"addedEdges"`
+	RemovedEdges  []Edge        `json:"removedEdges"`
+	FilterChanges []FieldChange `json:"filterChanges"`
+}
+
+// Diff compares two version snapshots node by node, edge by edge and filter by filter.
+// Webhook tokens and secrets are reported as changed but never shown.
+func Diff(from, to Version) VersionDiff {
+	d := VersionDiff{
+		From:          from.VersionMeta,
+		To:            to.VersionMeta,
+		Fields:        []FieldChange{},
+		AddedNodes:    []Node{},
+		RemovedNodes:  []Node{},
+		ChangedNodes:  []NodeChange{},
+		AddedEdges:    []Edge{},
+		RemovedEdges:  []Edge{},
+		FilterChanges: []FieldChange{},
+	}
+
+	if from.Name != to.Name {
+		d.Fields = append(d.Fields, FieldChange{Field: "name", Before: from.Name, After: to.Name})
+	}
+	if from.Description != to.Description {
+		d.Fields = append(d.Fields, FieldChange{Field: "description", Before: from.Description, After: to.Description})
+	}
+
+	fromNodes := make(map[string]Node, len(from.Nodes))
+	for _, n := range from.Nodes {
+		fromNodes[n.ID] = n
+	}
+	toNodes := make(map[string]Node, len(to.Nodes))
+	for _, n := range to.Nodes {
+		toNodes[n.ID] = n
+		before, ok := fromNodes[n.ID]
+		if !ok {
+			d.AddedNodes = append(d.AddedNodes, RedactWebhookNodes([]Node{n})[0])
+			continue
+		}
+		if fields := diffNode(before, n); len(fields) > 0 {
+			d.ChangedNodes = append(d.ChangedNodes, NodeChange{NodeID: n.ID, Fields: fields})
+		}
+	}
+	for _, n := range from.Nodes {
+		if _, ok := toNodes[n.ID]; !ok {
+			d.RemovedNodes = append(d.RemovedNodes, RedactWebhookNodes([]Node{n})[0])
+		}
+	}
+
+	fromEdges := make(map[Edge]bool, len(from.Edges))
+	for _, e := range from.Edges {
+		fromEdges[e] = true
+	}
+	toEdges := make(map[Edge]bool, len(to.Edges))
+	for _, e := range to.Edges {
+		toEdges[e] = true
+		if !fromEdges[e] {
+			d.AddedEdges = append(d.AddedEdges, e)
+		}
+	}
+	for _, e := range from.Edges {
+		if !toEdges[e] {
+			d.RemovedEdges = append(d.RemovedEdges, e)
+		}
+	}
+
+	for _, key := range unionKeys(from.Filters, to.Filters) {
+		before, hadBefore := from.Filters[key]
+		after, hasAfter := to.Filters[key]
+		if hadBefore && hasAfter && before == after {
+			continue
+		}
+		change := FieldChange{Field: key}
+		if hadBefore {
+			change.Before = before
+		}
+		if hasAfter {
+			change.After = after
+		}
+		d.FilterChanges = append(d.FilterChanges, change)
+	}
+
+	return d
+}
+
+func diffNode(before, after Node) []FieldChange {
+	var fields []FieldChange
+	if before.Type != after.Type {
+		fields = append(fields, FieldChange{Field: "type", Before: before.Type, After: after.Type})
+	}
+
+	beforeCfg := flattenConfig("config", normalizeConfig(before.Config))
+	afterCfg := flattenConfig("config", normalizeConfig(after.Config))
+	for _, key := range unionKeys(beforeCfg, afterCfg) {
+		b, hadBefore := beforeCfg[key]
+		a, hasAfter := afterCfg[key]
+		if hadBefore && hasAfter && reflect.DeepEqual(a, b) {
+			continue
+		}
+		if secretConfigFields[key] {
+			b, a = redactValue(b), redactValue(a)
+		}
+		fields = append(fields, FieldChange{Field: key, Before: b, After: a})
+	}
+
+	if before.Position != after.Position {
+		fields = append(fields, FieldChange{Field: "position", Before: before.Position, After: after.Position})
+	}
+	return fields
+}
+
+// secretConfigFields are config paths whose values a diff must not reveal.
+var secretConfigFields = map[string]bool{
+	"config.webhookToken":  true,
+	"config.webhookSecret": true,
+}
+
+func redactValue(v any) any {
+	if v == nil {
+		return nil
+	}
+	return RedactedSecret
+}
+
+// normalizeConfig round-trips config through JSON so values compare the same way
+// regardless of how the node was built.
+func normalizeConfig(cfg any) any {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return cfg
+	}
+	var out any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return cfg
+	}
+	return out
+}
+
+// flattenConfig turns nested objects into dotted paths; arrays and scalars are leaves.
+func flattenConfig(prefix string, v any) map[string]any {
+	out := make(map[string]any)
+	m, ok := v.(map[string]any)
+	if !ok {
+		if v != nil {
+			out[prefix] = v
+		}
+		return out
+	}
+	for k, child := range m {
+		for path, leaf := range flattenConfig(prefix+"."+k, child) {
+			out[path] = leaf
+		}
+	}
+	return out
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	for k := range b {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,79 @@
+package workflow
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	from := Version{
+		VersionMeta: VersionMeta{ID: "v1", VersionNumber: 1, Name: "Alerts"},
+		Nodes: []Node{
+			{ID: "tr", Type: NodeTypeTrigger, Config: map[string]any{"variant": "trigger", "eventTypes": []any{"order.created"}}},
+			{ID: "tpl", Type: NodeTypeAction, Config: map[string]any{"variant": "template", "templateBody": "Hi"}},
+			{ID: "old", Type: NodeTypeAction, Config: map[string]any{"variant": "storage"}},
+		},
+		Edges:   []Edge{{From: "tr", To: "tpl"}, {From: "tpl", To: "old"}},
+		Filters: map[string]string{"chan-1": "a", "chan-2": "b"},
+	}
+	to := Version{
+		VersionMeta: VersionMeta{ID: "v2", VersionNumber: 2, Name: "Order alerts"},
+		Nodes: []Node{
+			{ID: "tr", Type: NodeTypeTrigger, Config: map[string]any{"variant": "trigger", "eventTypes": []any{"order.created"}}},
+			{ID: "tpl", Type: NodeTypeAction, Config: map[string]any{"variant": "template", "templateBody": "Hello"}, Position: Position{X: 10}},
+			{ID: "ch", Type: NodeTypeAction, Config: map[string]any{"variant": "channel", "channelId": "c"}},
+		},
+		Edges:   []Edge{{From: "tr", To: "tpl"}, {From: "tpl", To: "ch"}},
+		Filters: map[string]string{"chan-1": "a", "chan-2": "c", "chan-3": "d"},
+	}
+
+	d := Diff(from, to)
+
+	if len(d.Fields) != 1 || d.Fields[0].Field != "name" {
+		t.Fatalf("fields %v", d.Fields)
+	}
+	if len(d.AddedNodes) != 1 || d.AddedNodes[0].ID != "ch" {
+		t.Fatalf("added nodes %v", d.AddedNodes)
+	}
+	if len(d.RemovedNodes) != 1 || d.RemovedNodes[0].ID != "old" {
+		t.Fatalf("removed nodes %v", d.RemovedNodes)
+	}
+	if len(d.ChangedNodes) != 1 || d.ChangedNodes[0].NodeID != "tpl" {
+		t.Fatalf("changed nodes %v", d.ChangedNodes)
+	}
+	fields := d.ChangedNodes[0].Fields
+	if len(fields) != 2 || fields[0].Field != "config.templateBody" || fields[0].After != "Hello" || fields[1].Field != "position" {
+		t.Fatalf("changed fields %v", fields)
+	}
+	if len(d.AddedEdges) != 1 || d.AddedEdges[0] != (Edge{From: "tpl", To: "ch"}) {
+		t.Fatalf("added edges %v", d.AddedEdges)
+	}
+	if len(d.RemovedEdges) != 1 || d.RemovedEdges[0] != (Edge{From: "tpl", To: "old"}) {
+		t.Fatalf("removed edges %v", d.RemovedEdges)
+	}
+	if len(d.FilterChanges) != 2 || d.FilterChanges[0].Field != "chan-2" || d.FilterChanges[1].Before != nil {
+		t.Fatalf("filter changes %v", d.FilterChanges)
+	}
+}
+
+func TestDiffRedactsWebhookSecrets(t *testing.T) {
+	trigger := func(token, secret string) Node {
+		return Node{ID: "t", Type: NodeTypeTrigger, Config: map[string]any{
+			"triggerKind": TriggerKindWebhook, "webhookToken": token, "webhookSecret": secret,
+		}}
+	}
+	from := Version{Nodes: []Node{trigger("old-token", "old-secret")}}
+	to := Version{Nodes: []Node{trigger("new-token", "new-secret"), {ID: "added", Type: NodeTypeTrigger, Config: map[string]any{
+		"triggerKind": TriggerKindWebhook, "webhookToken": "added-token",
+	}}}}
+
+	d := Diff(from, to)
+	if len(d.ChangedNodes) != 1 || len(d.ChangedNodes[0].Fields) != 2 {
+		t.Fatalf("expected token and secret changes, got %+v", d.ChangedNodes)
+	}
+	for _, f := range d.ChangedNodes[0].Fields {
+		if f.Before != RedactedSecret || f.After != RedactedSecret {
+			t.Fatalf("secret leaked in %+v", f)
+		}
+	}
+	if len(d.AddedNodes) != 1 || d.AddedNodes[0].Config.(map[string]any)["webhookToken"] != RedactedSecret {
+		t.Fatalf("added node not redacted: %+v", d.AddedNodes)
+	}
+}
@@ -0,0 +1,57 @@
+package workflow
+
+import "context"
+
+// ChangeFunc is called after a workflow was saved, deleted, restored or published.
+type ChangeFunc func(ctx context.Context, workflowID string)
+
+type notifyingRepository struct {
+	Repository
+	onChange ChangeFunc
+}
+
+// WithChangeHook wraps repo so that every successful write reports the workflow ID to
+// onChange. Reads are passed through untouched.
+func WithChangeHook(repo Repository, onChange ChangeFunc) Repository {
+	return &notifyingRepository{Repository: repo, onChange: onChange}
+}
+
+func (r *notifyingRepository) Save(ctx context.Context, wf Workflow) (Workflow, error) {
+	saved, err := r.Repository.Save(ctx, wf)
+	if err == nil {
+		r.onChange(ctx, saved.ID)
+	}
+	return saved, err
+}
+
+func (r *notifyingRepository) SaveIfMatch(ctx context.Context, wf Workflow, revision int) (Workflow, error) {
+	saved, err := r.Repository.SaveIfMatch(ctx, wf, revision)
+	if err == nil {
+		r.onChange(ctx, saved.ID)
+	}
+	return saved, err
+}
+
+func (r *notifyingRepository) Delete(ctx context.Context, id string) error {
+	err := r.Repository.Delete(ctx, id)
+	if err == nil {
+		r.onChange(ctx, id)
+	}
+	return err
+}
+
+func (r *notifyingRepository) RestoreVersion(ctx context.Context, workflowID, versionID string) (Workflow, error) {
+	restored, err := r.Repository.RestoreVersion(ctx, workflowID, versionID)
+	if err == nil {
+		r.onChange(ctx, workflowID)
+	}
+	return restored, err
+}
+
+func (r *notifyingRepository) PublishVersion(ctx context.Context, workflowID, versionID string) (Workflow, error) {
+	published, err := r.Repository.PublishVersion(ctx, workflowID, versionID)
+	if err == nil {
+		r.onChange(ctx, workflowID)
+	}
+	return published, err
+}
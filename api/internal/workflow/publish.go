@@ -0,0 +1,108 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+
+	workflowpersist "notiair/internal/persistence/workflow"
+)
+
+// Publication states of a saved draft, as reported after save and import.
+const (
+	// PublicationPublished means production runs the saved version.
+	PublicationPublished = "published"
+	// PublicationPending means production still runs an older version.
+	PublicationPending = "pending"
+	// PublicationUnpublished means the workflow does not run until a version is published.
+	PublicationUnpublished = "unpublished"
+)
+
+// PublishedFinder looks up the graph production runs for a workflow.
+type PublishedFinder interface {
+	FindPublished(ctx context.Context, id string) (Workflow, error)
+}
+
+// PublicationOf reports whether production runs the saved draft wf.
+func PublicationOf(ctx context.Context, repo PublishedFinder, wf Workflow) (string, error) {
+	published, err := repo.FindPublished(ctx, wf.ID)
+	if errors.Is(err, ErrNotPublished) {
+		return PublicationUnpublished, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if published.VersionNumber != wf.VersionNumber {
+		return PublicationPending, nil
+	}
+	return PublicationPublished, nil
+}
+
+// PublishVersion makes versionID the graph that production executes. The version
+// must pass Validate, the same rule that guards activation on save.
+func (r *dbRepository) PublishVersion(ctx context.Context, workflowID, versionID string) (Workflow, error) {
+	version, err := r.GetVersion(ctx, workflowID, versionID)
+	if err != nil {
+		return Workflow{}, err
+	}
+
+	if problems := Validate(Workflow{ID: workflowID, Nodes: version.Nodes, Edges: version.Edges}); len(problems) > 0 {
+		return Workflow{}, &ValidationError{Problems: problems}
+	}
+
+	entity, err := r.repo.PublishVersion(ctx, workflowID, versionID)
+	if err != nil {
+		return Workflow{}, err
+	}
+	return entityToWorkflow(entity)
+}
+
+func (r *dbRepository) FindPublished(ctx context.Context, id string) (Workflow, error) {
+	entity, version, err := r.repo.FindPublished(ctx, id)
+	if err != nil {
+		return Workflow{}, err
+	}
+	return publishedToWorkflow(entity, version)
+}
+
+func (r *dbRepository) ListPublished(ctx context.Context) ([]Workflow, error) {
+	published, err := r.repo.ListPublished(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Workflow, len(published))
+	for i, p := range published {
+		wf, err := publishedToWorkflow(p.Workflow, p.Version)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = wf
+	}
+	return out, nil
+}
+
+// publishedToWorkflow overlays the published snapshot on the workflow row. IsActive
+// stays the live on/off switch of the workflow itself.
+func publishedToWorkflow(entity workflowpersist.WorkflowEntity, version workflowpersist.WorkflowVersionEntity) (Workflow, error) {
+	entity.Name = version.Name
+	entity.Description = version.Description
+	entity.Nodes = version.Nodes
+	entity.Edges = version.Edges
+	entity.Filters = version.Filters
+	entity.CanvasZoom = version.CanvasZoom
+	entity.VersionNumber = version.VersionNumber
+	return entityToWorkflow(entity)
+}
+
+func (r *memoryRepository) PublishVersion(ctx context.Context, workflowID, versionID string) (Workflow, error) {
+	return Workflow{}, errors.New("version history not supported in memory repository")
+}
+
+// FindPublished returns the live workflow: the memory repository keeps no versions.
+func (r *memoryRepository) FindPublished(ctx context.Context, id string) (Workflow, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r *memoryRepository) ListPublished(ctx context.Context) ([]Workflow, error) {
+	return r.List(ctx)
+}
@@ -0,0 +1,89 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// TriggerKindSchedule marks a trigger node that runs the workflow on a cron schedule.
+const TriggerKindSchedule = "schedule"
+
+// ScheduleTrigger is a schedule trigger node of a workflow.
+type ScheduleTrigger struct {
+	NodeID   string
+	Cron     string
+	Timezone string
+}
+
+type scheduleConfig struct {
+	TriggerKind string `json:"triggerKind"`
+	Cron        string `json:"cron"`
+	Timezone    string `json:"timezone"`
+}
+
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ScheduleTriggers returns every schedule trigger of wf in node order.
+func ScheduleTriggers(wf Workflow) []ScheduleTrigger {
+	var out []ScheduleTrigger
+	for _, node := range wf.Nodes {
+		if cfg, ok := parseScheduleConfig(node); ok {
+			out = append(out, ScheduleTrigger{NodeID: node.ID, Cron: strings.TrimSpace(cfg.Cron), Timezone: cfg.Timezone})
+		}
+	}
+	return out
+}
+
+// Location returns the trigger's time zone, UTC when none is set.
+func (t ScheduleTrigger) Location() (*time.Location, error) {
+	if t.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(t.Timezone)
+}
+
+// Schedule parses the cron expression (five fields or a descriptor such as @daily).
+func (t ScheduleTrigger) Schedule() (cron.Schedule, error) {
+	return cronParser.Parse(t.Cron)
+}
+
+// Cronspec is the expression with its time zone in the CRON_TZ= form understood by
+// the scheduler.
+func (t ScheduleTrigger) Cronspec() string {
+	if t.Timezone == "" {
+		return t.Cron
+	}
+	return fmt.Sprintf("CRON_TZ=%s %s", t.Timezone, t.Cron)
+}
+
+func parseScheduleConfig(node Node) (scheduleConfig, bool) {
+	if node.Type != NodeTypeTrigger {
+		return scheduleConfig{}, false
+	}
+	var cfg scheduleConfig
+	b, err := json.Marshal(node.Config)
+	if err != nil {
+		return scheduleConfig{}, false
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil || cfg.TriggerKind != TriggerKindSchedule {
+		return scheduleConfig{}, false
+	}
+	return cfg, true
+}
+
+func validateSchedule(t ScheduleTrigger) []Problem {
+	var problems []Problem
+	if t.Cron == "" {
+		problems = append(problems, Problem{NodeID: t.NodeID, Field: "cron", Message: "cron expression is required"})
+	} else if _, err := t.Schedule(); err != nil {
+		problems = append(problems, Problem{NodeID: t.NodeID, Field: "cron", Message: err.Error()})
+	}
+	if _, err := t.Location(); err != nil {
+		problems = append(problems, Problem{NodeID: t.NodeID, Field: "timezone", Message: fmt.Sprintf("unknown time zone %q", t.Timezone)})
+	}
+	return problems
+}
@@ -0,0 +1,263 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+)
+
+// Brokers a stream trigger can read from. A trigger without a broker reads Kafka.
+const (
+	BrokerKafka = "kafka"
+	BrokerRedis = "redis"
+	BrokerNATS  = "nats"
+)
+
+// StreamConfig is the part of a stream trigger's config used to match events.
+type StreamConfig struct {
+	// Broker is BrokerKafka, BrokerRedis or BrokerNATS; Topic is a Kafka topic, a Redis
+	// stream key or a NATS subject, which may contain the wildcards "*" and ">".
+	Broker string `json:"broker"`
+	Topic  string `json:"topic"`
+	// EventTypes are exact types or globs such as "order.*" and "*.failed".
+	EventTypes []string `json:"eventTypes"`
+	// Where narrows matching events further; every condition must hold.
+	Where []Condition `json:"where"`
+}
+
+// Condition compares one event field, addressed by a dotted path under "context" or
+// "metadata", with Value.
+type Condition struct {
+	Path  string `json:"path"`
+	Op    string `json:"op"`
+	Value any    `json:"value,omitempty"`
+}
+
+const (
+	OpEq     = "eq"
+	OpNe     = "ne"
+	OpGt     = "gt"
+	OpGte    = "gte"
+	OpLt     = "lt"
+	OpLte    = "lte"
+	OpIn     = "in"
+	OpExists = "exists"
+)
+
+// ParseStreamConfig returns the config of a stream trigger node.
+func ParseStreamConfig(node Node) (StreamConfig, bool) {
+	if TriggerKindOf(node) != TriggerKindStream {
+		return StreamConfig{}, false
+	}
+	var cfg StreamConfig
+	b, err := json.Marshal(node.Config)
+	if err != nil {
+		return StreamConfig{}, false
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return StreamConfig{}, false
+	}
+	return cfg, true
+}
+
+// BrokerName returns the broker of the trigger, BrokerKafka when it is not set.
+func (c StreamConfig) BrokerName() string {
+	if c.Broker == "" {
+		return BrokerKafka
+	}
+	return c.Broker
+}
+
+// MatchEventType reports whether eventType matches pattern; "*" stands for any run of
+// characters and "?" for one. Malformed patterns match nothing.
+func MatchEventType(pattern, eventType string) bool {
+	if pattern == eventType {
+		return true
+	}
+	ok, err := path.Match(pattern, eventType)
+	return err == nil && ok
+}
+
+// MatchSubject reports whether a NATS subject matches pattern, where "*" stands for one
+// token and a trailing ">" for one or more.
+func MatchSubject(pattern, subject string) bool {
+	if pattern == subject {
+		return true
+	}
+	want := strings.Split(pattern, ".")
+	got := strings.Split(subject, ".")
+	for i, token := range want {
+		if token == ">" {
+			return i == len(want)-1 && len(got) > i
+		}
+		if i >= len(got) || (token != "*" && token != got[i]) {
+			return false
+		}
+	}
+	return len(got) == len(want)
+}
+
+// validSubject reports whether s is a NATS subject or subject pattern.
+func validSubject(s string) bool {
+	tokens := strings.Split(s, ".")
+	for i, token := range tokens {
+		if token == "" || strings.ContainsAny(token, " \t") {
+			return false
+		}
+		if token == ">" && i != len(tokens)-1 {
+			return false
+		}
+		if token != "*" && token != ">" && strings.ContainsAny(token, "*>") {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches reports whether an event of eventType with the given context and metadata
+// passes the trigger's event types and conditions.
+func (c StreamConfig) Matches(eventType string, context, metadata map[string]any) bool {
+	typeOK := false
+	for _, pattern := range c.EventTypes {
+		if MatchEventType(pattern, eventType) {
+			typeOK = true
+			break
+		}
+	}
+	if !typeOK {
+		return false
+	}
+
+	doc := map[string]any{"context": context, "metadata": metadata}
+	for _, cond := range c.Where {
+		if !cond.Eval(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+// Eval applies the condition to doc, a map with "context" and "metadata" keys.
+func (c Condition) Eval(doc map[string]any) bool {
+	got, found := lookupPath(doc, c.Path)
+	switch c.Op {
+	case OpExists:
+		want, ok := c.Value.(bool)
+		if !ok {
+			want = true
+		}
+		return found == want
+	case OpEq:
+		return found && equalValues(got, c.Value)
+	case OpNe:
+		return !found || !equalValues(got, c.Value)
+	case OpIn:
+		list, ok := c.Value.([]any)
+		if !found || !ok {
+			return false
+		}
+		for _, v := range list {
+			if equalValues(got, v) {
+				return true
+			}
+		}
+		return false
+	case OpGt, OpGte, OpLt, OpLte:
+		a, okA := toNumber(got)
+		b, okB := toNumber(c.Value)
+		if !found || !okA || !okB {
+			return false
+		}
+		switch c.Op {
+		case OpGt:
+			return a > b
+		case OpGte:
+			return a >= b
+		case OpLt:
+			return a < b
+		default:
+			return a <= b
+		}
+	}
+	return false
+}
+
+func lookupPath(doc map[string]any, dotted string) (any, bool) {
+	var current any = doc
+	for _, key := range strings.Split(dotted, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func equalValues(a, b any) bool {
+	if x, ok := toNumber(a); ok {
+		y, ok := toNumber(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func validateStream(node Node) []Problem {
+	cfg, _ := ParseStreamConfig(node)
+	var problems []Problem
+	switch cfg.BrokerName() {
+	case BrokerKafka, BrokerRedis:
+	case BrokerNATS:
+		if cfg.Topic != "" && !validSubject(cfg.Topic) {
+			problems = append(problems, Problem{NodeID: node.ID, Field: "topic", Message: fmt.Sprintf("invalid subject %q", cfg.Topic)})
+		}
+	default:
+		problems = append(problems, Problem{NodeID: node.ID, Field: "broker", Message: fmt.Sprintf("unknown broker %q", cfg.Broker)})
+	}
+	for _, pattern := range cfg.EventTypes {
+		if _, err := path.Match(pattern, ""); err != nil {
+			problems = append(problems, Problem{NodeID: node.ID, Field: "eventTypes", Message: fmt.Sprintf("invalid pattern %q", pattern)})
+		}
+	}
+	for i, cond := range cfg.Where {
+		field := fmt.Sprintf("where[%d]", i)
+		if !strings.HasPrefix(cond.Path, "context.") && !strings.HasPrefix(cond.Path, "metadata.") {
+			problems = append(problems, Problem{NodeID: node.ID, Field: field, Message: "path must start with context. or metadata."})
+		}
+		switch cond.Op {
+		case OpEq, OpNe, OpExists:
+		case OpIn:
+			if _, ok := cond.Value.([]any); !ok {
+				problems = append(problems, Problem{NodeID: node.ID, Field: field, Message: "in needs a list value"})
+			}
+		case OpGt, OpGte, OpLt, OpLte:
+			if _, ok := toNumber(cond.Value); !ok {
+				problems = append(problems, Problem{NodeID: node.ID, Field: field, Message: cond.Op + " needs a numeric value"})
+			}
+		default:
+			problems = append(problems, Problem{NodeID: node.ID, Field: field, Message: fmt.Sprintf("unknown operator %q", cond.Op)})
+		}
+	}
+	return problems
+}
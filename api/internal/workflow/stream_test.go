@@ -0,0 +1,101 @@
+package workflow
+
+import "testing"
+
+func TestMatchEventTypeGlobs(t *testing.T) {
+	cases := []struct {
+		pattern, eventType string
+		want               bool
+	}{
+		{"order.created", "order.created", true},
+		{"order.*", "order.created", true},
+		{"order.*", "payment.created", false},
+		{"*.failed", "payment.failed", true},
+		{"*.failed", "payment.succeeded", false},
+		{"order.[", "order.[", true},
+		{"order.[", "order.x", false},
+	}
+	for _, c := range cases {
+		if got := MatchEventType(c.pattern, c.eventType); got != c.want {
+			t.Errorf("MatchEventType(%q, %q) = %v, want %v", c.pattern, c.eventType, got, c.want)
+		}
+	}
+}
+
+func TestMatchSubjectWildcards(t *testing.T) {
+	cases := []struct {
+		pattern, subject string
+		want             bool
+	}{
+		{"orders.created", "orders.created", true},
+		{"orders.*", "orders.created", true},
+		{"orders.*", "orders.eu.created", false},
+		{"orders.>", "orders.eu.created", true},
+		{"orders.>", "orders", false},
+		{"*.created", "refunds.created", true},
+		{"orders.*.created", "orders.eu.paid", false},
+	}
+	for _, c := range cases {
+		if got := MatchSubject(c.pattern, c.subject); got != c.want {
+			t.Errorf("MatchSubject(%q, %q) = %v, want %v", c.pattern, c.subject, got, c.want)
+		}
+	}
+}
+
+func TestStreamConfigConditions(t *testing.T) {
+	cfg := StreamConfig{
+		EventTypes: []string{"order.*"},
+		Where: []Condition{
+			{Path: "context.order.amount", Op: OpGte, Value: 100},
+			{Path: "metadata.source", Op: OpIn, Value: []any{"web", "ios"}},
+		},
+	}
+	context := map[string]any{"order": map[string]any{"amount": 150.0}}
+
+	if !cfg.Matches("order.created", context, map[string]any{"source": "web"}) {
+		t.Fatal("expected event to match")
+	}
+	if cfg.Matches("order.created", context, map[string]any{"source": "pos"}) {
+		t.Fatal("source outside the list should not match")
+	}
+	if cfg.Matches("order.created", map[string]any{"order": map[string]any{"amount": 50.0}}, map[string]any{"source": "web"}) {
+		t.Fatal("small amount should not match")
+	}
+	if cfg.Matches("refund.created", context, map[string]any{"source": "web"}) {
+		t.Fatal("event type outside the glob should not match")
+	}
+}
+
+func TestValidateStreamTrigger(t *testing.T) {
+	wf := validWorkflow()
+	wf.Nodes[0].Config = map[string]any{
+		"triggerKind": "stream",
+		"broker":      "rabbitmq",
+		"eventTypes":  []any{"order.["},
+		"where": []any{
+			map[string]any{"path": "order.amount", "op": "gt", "value": 1},
+			map[string]any{"path": "context.amount", "op": "gt", "value": "big"},
+			map[string]any{"path": "context.amount", "op": "like"},
+		},
+	}
+
+	problems := Validate(wf)
+	for _, field := range []string{"broker", "eventTypes", "where[0]", "where[1]", "where[2]"} {
+		if !hasProblem(problems, "tr", field) {
+			t.Errorf("expected problem on %s, got %v", field, problems)
+		}
+	}
+}
+
+func TestValidateNATSSubject(t *testing.T) {
+	wf := validWorkflow()
+	wf.Nodes[0].Config = map[string]any{"triggerKind": "stream", "broker": "nats", "topic": "orders.>.created", "eventTypes": []any{"orders.*"}}
+	if !hasProblem(Validate(wf), "tr", "topic") {
+		t.Fatal("expected problem on topic with > before the last token")
+	}
+
+	wf.Nodes[0].Config = map[string]any{"triggerKind": "stream", "broker": "nats", "topic": "orders.*.created", "eventTypes": []any{"orders.*"}}
+	if hasProblem(Validate(wf), "tr", "topic") {
+		t.Fatal("wildcard subject should be valid")
+	}
+}
@@ -0,0 +1,166 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	workflowpersist "notiair/internal/persistence/workflow"
+)
+
+// Trigger kinds stored in config.triggerKind of trigger nodes. Webhook and schedule
+// kinds are declared next to their config.
+const (
+	TriggerKindManual = "manual"
+	TriggerKindStream = "stream"
+)
+
+// legacyStreamLabel is the canvas label that marked stream triggers before triggerKind.
+const legacyStreamLabel = "Stream broker"
+
+// TriggerType describes one kind of trigger node.
+type TriggerType struct {
+	Kind string
+	// Validate reports problems in the kind-specific config; nil means nothing to check.
+	Validate func(node Node) []Problem
+}
+
+var triggerTypes = map[string]TriggerType{}
+
+// RegisterTriggerType adds a trigger kind to the registry. Registering the same kind
+// twice is a programming error.
+func RegisterTriggerType(t TriggerType) {
+	if _, dup := triggerTypes[t.Kind]; dup {
+		panic(fmt.Sprintf("workflow: trigger kind %q registered twice", t.Kind))
+	}
+	triggerTypes[t.Kind] = t
+}
+
+// LookupTriggerType returns the registered trigger kind.
+func LookupTriggerType(kind string) (TriggerType, bool) {
+	t, ok := triggerTypes[kind]
+	return t, ok
+}
+
+// TriggerKinds lists registered trigger kinds in alphabetical order.
+func TriggerKinds() []string {
+	kinds := make([]string, 0, len(triggerTypes))
+	for kind := range triggerTypes {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func init() {
+	RegisterTriggerType(TriggerType{Kind: TriggerKindManual})
+	RegisterTriggerType(TriggerType{Kind: TriggerKindStream, Validate: validateStream})
+	RegisterTriggerType(TriggerType{Kind: TriggerKindWebhook})
+	RegisterTriggerType(TriggerType{Kind: TriggerKindSchedule, Validate: func(node Node) []Problem {
+		cfg, _ := parseScheduleConfig(node)
+		return validateSchedule(ScheduleTrigger{NodeID: node.ID, Cron: cfg.Cron, Timezone: cfg.Timezone})
+	}})
+}
+
+type triggerKindConfig struct {
+	TriggerKind string `json:"triggerKind"`
+	Label       string `json:"label"`
+}
+
+// TriggerKindOf returns the kind of a trigger node and "" for other nodes. Triggers
+// saved before triggerKind existed are recognised by their canvas label.
+func TriggerKindOf(node Node) string {
+	if node.Type != NodeTypeTrigger {
+		return ""
+	}
+	var cfg triggerKindConfig
+	if b, err := json.Marshal(node.Config); err == nil {
+		_ = json.Unmarshal(b, &cfg)
+	}
+	switch {
+	case cfg.TriggerKind != "":
+		return cfg.TriggerKind
+	case cfg.Label == legacyStreamLabel:
+		return TriggerKindStream
+	default:
+		return TriggerKindManual
+	}
+}
+
+// TriggerNodes returns the trigger nodes of wf with the given kind.
+func TriggerNodes(wf Workflow, kind string) []Node {
+	var out []Node
+	for _, node := range wf.Nodes {
+		if TriggerKindOf(node) == kind {
+			out = append(out, node)
+		}
+	}
+	return out
+}
+
+func validateTrigger(node Node) []Problem {
+	kind := TriggerKindOf(node)
+	t, ok := LookupTriggerType(kind)
+	if !ok {
+		return []Problem{{NodeID: node.ID, Field: "triggerKind", Message: fmt.Sprintf("unknown trigger kind %q", kind)}}
+	}
+	if t.Validate == nil {
+		return nil
+	}
+	return t.Validate(node)
+}
+
+// AssignTriggerKinds writes the kind into every trigger node that has none, as saves
+// do. It reports whether any node changed.
+func AssignTriggerKinds(nodes []Node) ([]Node, bool) {
+	if len(nodes) == 0 {
+		return nodes, false
+	}
+	out := make([]Node, len(nodes))
+	copy(out, nodes)
+	changed := false
+	for i, node := range out {
+		if node.Type != NodeTypeTrigger {
+			continue
+		}
+		var raw map[string]any
+		if b, err := json.Marshal(node.Config); err == nil {
+			_ = json.Unmarshal(b, &raw)
+		}
+		if raw == nil {
+			raw = map[string]any{}
+		}
+		if kind, _ := raw["triggerKind"].(string); kind != "" {
+			continue
+		}
+		raw["triggerKind"] = TriggerKindOf(node)
+		out[i].Config = raw
+		changed = true
+	}
+	return out, changed
+}
+
+// TriggerKindsMigration names MigrateTriggerKinds in the applied data migrations.
+const TriggerKindsMigration = "workflow-trigger-kinds"
+
+// MigrateTriggerKinds stores triggerKind on trigger nodes of every workflow draft saved
+// before the field existed. Version snapshots are not rewritten: TriggerKindOf
+// recognises their legacy triggers when they are read. It is idempotent.
+func MigrateTriggerKinds(ctx context.Context, repo workflowpersist.Repository) (int64, error) {
+	return repo.RewriteDraftNodes(ctx, func(data []byte) ([]byte, bool, error) {
+		var nodes []Node
+		if len(data) == 0 {
+			return data, false, nil
+		}
+		if err := json.Unmarshal(data, &nodes); err != nil {
+			return nil, false, err
+		}
+		nodes, changed := AssignTriggerKinds(nodes)
+		if !changed {
+			return data, false, nil
+		}
+		out, err := json.Marshal(nodes)
+		return out, err == nil, err
+	})
+}
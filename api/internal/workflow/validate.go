@@ -0,0 +1,173 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	tplrender "notiair/internal/template"
+)
+
+// Problem describes a single defect in a workflow graph.
+type Problem struct {
+	NodeID  string `json:"nodeId,omitempty"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned by Repository.Save when an active workflow has problems.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		if p.NodeID != "" {
+			msgs[i] = fmt.Sprintf("node %s: %s: %s", p.NodeID, p.Field, p.Message)
+		} else {
+			msgs[i] = fmt.Sprintf("%s: %s", p.Field, p.Message)
+		}
+	}
+	return "workflow is invalid: " + strings.Join(msgs, "; ")
+}
+
+type validationConfig struct {
+	Variant      string `json:"variant"`
+	ChannelID    string `json:"channelId"`
+	TemplateBody string `json:"templateBody"`
+	JoinMode     string `json:"joinMode"`
+}
+
+func parseValidationConfig(node Node) validationConfig {
+	var cfg validationConfig
+	b, err := json.Marshal(node.Config)
+	if err != nil {
+		return cfg
+	}
+	_ = json.Unmarshal(b, &cfg)
+	return cfg
+}
+
+// Validate checks graph structure and node configuration. An empty result means the
+// workflow can be activated.
+func Validate(wf Workflow) []Problem {
+	var problems []Problem
+
+	nodes := make(map[string]Node, len(wf.Nodes))
+	triggers := 0
+	var joins []string
+	for _, n := range wf.Nodes {
+		if n.ID == "" {
+			problems = append(problems, Problem{Field: "id", Message: "node id is required"})
+			continue
+		}
+		if _, dup := nodes[n.ID]; dup {
+			problems = append(problems, Problem{NodeID: n.ID, Field: "id", Message: "duplicate node id"})
+			continue
+		}
+		nodes[n.ID] = n
+		if n.Type == NodeTypeTrigger {
+			triggers++
+		}
+
+		cfg := parseValidationConfig(n)
+		switch cfg.Variant {
+		case "channel":
+			if strings.TrimSpace(cfg.ChannelID) == "" {
+				problems = append(problems, Problem{NodeID: n.ID, Field: "channelId", Message: "channel is not selected"})
+			}
+		case "template":
+			if err := tplrender.Validate(cfg.TemplateBody); err != nil {
+				problems = append(problems, Problem{NodeID: n.ID, Field: "templateBody", Message: err.Error()})
+			}
+		case "join":
+			if cfg.JoinMode != "" && cfg.JoinMode != "all" && cfg.JoinMode != "any" {
+				problems = append(problems, Problem{NodeID: n.ID, Field: "joinMode", Message: fmt.Sprintf("unknown join mode %q", cfg.JoinMode)})
+			}
+			joins = append(joins, n.ID)
+		}
+	}
+
+	if triggers == 0 {
+		problems = append(problems, Problem{Field: "nodes", Message: "workflow has no trigger nodes"})
+	}
+
+	adj := make(map[string][]string)
+	for _, e := range wf.Edges {
+		_, fromOK := nodes[e.From]
+		to, toOK := nodes[e.To]
+		if !fromOK {
+			problems = append(problems, Problem{NodeID: e.To, Field: "edges", Message: fmt.Sprintf("edge from unknown node %q", e.From)})
+		}
+		if !toOK {
+			problems = append(problems, Problem{NodeID: e.From, Field: "edges", Message: fmt.Sprintf("edge to unknown node %q", e.To)})
+		}
+		if !fromOK || !toOK {
+			continue
+		}
+		if to.Type == NodeTypeTrigger {
+			problems = append(problems, Problem{NodeID: e.To, Field: "edges", Message: fmt.Sprintf("trigger has incoming edge from %q", e.From)})
+		}
+		adj[e.From] = append(adj[e.From], e.To)
+	}
+
+	for _, id := range joins {
+		if !hasIncoming(adj, id) {
+			problems = append(problems, Problem{NodeID: id, Field: "edges", Message: "join has no incoming edges"})
+		}
+	}
+
+	for _, n := range wf.Nodes {
+		if n.Type == NodeTypeTrigger {
+			problems = append(problems, validateTrigger(n)...)
+		}
+	}
+
+	problems = append(problems, findCycles(wf.Nodes, adj)...)
+	return problems
+}
+
+func hasIncoming(adj map[string][]string, id string) bool {
+	for _, targets := range adj {
+		for _, t := range targets {
+			if t == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findCycles reports every back edge found by a depth-first walk over the graph.
+func findCycles(nodes []Node, adj map[string][]string) []Problem {
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+
+	var problems []Problem
+	state := make(map[string]int, len(nodes))
+
+	var visit func(id string)
+	visit = func(id string) {
+		state[id] = inProgress
+		for _, next := range adj[id] {
+			switch state[next] {
+			case unvisited:
+				visit(next)
+			case inProgress:
+				problems = append(problems, Problem{NodeID: next, Field: "edges", Message: fmt.Sprintf("cycle via edge %s -> %s", id, next)})
+			}
+		}
+		state[id] = done
+	}
+
+	for _, n := range nodes {
+		if state[n.ID] == unvisited {
+			visit(n.ID)
+		}
+	}
+	return problems
+}
@@ -0,0 +1,162 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func validWorkflow() Workflow {
+	return Workflow{
+		ID: "wf-1",
+		Nodes: []Node{
+			{ID: "tr", Type: NodeTypeTrigger, Config: map[string]any{"variant": "trigger"}},
+			{ID: "tpl", Type: NodeTypeAction, Config: map[string]any{"variant": "template", "templateBody": "Hi {{name}}"}},
+			{ID: "ch", Type: NodeTypeAction, Config: map[string]any{"variant": "channel", "channelId": "chan-1"}},
+		},
+		Edges: []Edge{
+			{From: "tr", To: "tpl"},
+			{From: "tpl", To: "ch"},
+		},
+	}
+}
+
+func hasProblem(problems []Problem, nodeID, field string) bool {
+	for _, p := range problems {
+		if p.NodeID == nodeID && p.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidate_ValidWorkflow(t *testing.T) {
+	if problems := Validate(validWorkflow()); len(problems) != 0 {
+		t.Fatalf("expected no problems, got %v", problems)
+	}
+}
+
+func TestValidate_ReportsProblems(t *testing.T) {
+	wf := validWorkflow()
+	wf.Nodes[1].Config = map[string]any{"variant": "template", "templateBody": "Hi {{name"}
+	wf.Nodes[2].Config = map[string]any{"variant": "channel"}
+	wf.Edges = append(wf.Edges,
+		Edge{From: "ch", To: "tpl"},
+		Edge{From: "tpl", To: "tr"},
+		Edge{From: "tpl", To: "ghost"},
+	)
+
+	problems := Validate(wf)
+
+	cases := []struct {
+		nodeID string
+		field  string
+	}{
+		{"tpl", "templateBody"},
+		{"ch", "channelId"},
+		{"tr", "edges"},
+		{"tpl", "edges"},
+	}
+	for _, c := range cases {
+		if !hasProblem(problems, c.nodeID, c.field) {
+			t.Fatalf("expected problem for node %s field %s, got %v", c.nodeID, c.field, problems)
+		}
+	}
+}
+
+func TestValidate_NoTrigger(t *testing.T) {
+	wf := Workflow{Nodes: []Node{{ID: "ch", Type: NodeTypeAction, Config: map[string]any{"variant": "channel", "channelId": "c"}}}}
+	if !hasProblem(Validate(wf), "", "nodes") {
+		t.Fatalf("expected missing trigger problem")
+	}
+}
+
+func TestSave_BlocksActivationButKeepsDrafts(t *testing.T) {
+	repo := NewMemoryRepository()
+	wf := validWorkflow()
+	wf.Nodes[2].Config = map[string]any{"variant": "channel"}
+
+	draft, err := repo.Save(context.Background(), wf)
+	if err != nil {
+		t.Fatalf("draft save: %v", err)
+	}
+	if !hasProblem(draft.Problems, "ch", "channelId") {
+		t.Fatalf("draft saved without its problems: %v", draft.Problems)
+	}
+
+	wf.IsActive = true
+	_, err = repo.Save(context.Background(), wf)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	if !hasProblem(validationErr.Problems, "ch", "channelId") {
+		t.Fatalf("unexpected problems %v", validationErr.Problems)
+	}
+}
+
+func TestSave_ReportsDanglingEdgeOnDraft(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	wf := validWorkflow()
+	wf.Edges = append(wf.Edges, Edge{From: "ch", To: "gone"})
+
+	saved, err := repo.Save(ctx, wf)
+	if err != nil {
+		t.Fatalf("draft with a dangling edge should save: %v", err)
+	}
+	if !hasProblem(saved.Problems, "ch", "edges") {
+		t.Fatalf("expected the dangling edge to be reported, got %v", saved.Problems)
+	}
+
+	stored, err := repo.FindByID(ctx, "wf-1")
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if len(stored.Edges) != 3 || stored.Problems != nil {
+		t.Fatalf("unexpected stored draft %+v", stored)
+	}
+}
+
+func TestValidate_ScheduleTrigger(t *testing.T) {
+	wf := validWorkflow()
+	wf.Nodes[0].Config = map[string]any{"variant": "trigger", "triggerKind": "schedule", "cron": "0 9 * * 1", "timezone": "Europe/Moscow"}
+	if problems := Validate(wf); len(problems) != 0 {
+		t.Fatalf("expected no problems, got %v", problems)
+	}
+
+	triggers := ScheduleTriggers(wf)
+	if len(triggers) != 1 || triggers[0].Cronspec() != "CRON_TZ=Europe/Moscow 0 9 * * 1" {
+		t.Fatalf("unexpected triggers %+v", triggers)
+	}
+
+	wf.Nodes[0].Config = map[string]any{"variant": "trigger", "triggerKind": "schedule", "cron": "0 25 * * *", "timezone": "Mars/Olympus"}
+	problems := Validate(wf)
+	if !hasProblem(problems, "tr", "cron") || !hasProblem(problems, "tr", "timezone") {
+		t.Fatalf("expected cron and timezone problems, got %v", problems)
+	}
+}
+
+func TestValidate_UnknownTriggerKind(t *testing.T) {
+	wf := validWorkflow()
+	wf.Nodes[0].Config = map[string]any{"variant": "trigger", "triggerKind": "carrier-pigeon"}
+	if problems := Validate(wf); !hasProblem(problems, "tr", "triggerKind") {
+		t.Fatalf("expected triggerKind problem, got %v", problems)
+	}
+}
+
+func TestTriggerKindOf_LegacyStreamLabel(t *testing.T) {
+	legacy := Node{ID: "tr", Type: NodeTypeTrigger, Config: map[string]any{"label": "Stream broker"}}
+	renamed := Node{ID: "tr", Type: NodeTypeTrigger, Config: map[string]any{"label": "Orders", "triggerKind": "stream"}}
+	if TriggerKindOf(legacy) != TriggerKindStream || TriggerKindOf(renamed) != TriggerKindStream {
+		t.Fatal("expected both nodes to be stream triggers")
+	}
+
+	nodes, changed := AssignTriggerKinds([]Node{legacy, {ID: "m", Type: NodeTypeTrigger, Config: map[string]any{"label": "Manual"}}})
+	if !changed || nodes[0].Config.(map[string]any)["triggerKind"] != TriggerKindStream || nodes[1].Config.(map[string]any)["triggerKind"] != TriggerKindManual {
+		t.Fatalf("unexpected nodes %+v", nodes)
+	}
+	if _, changed := AssignTriggerKinds(nodes); changed {
+		t.Fatal("assigning kinds twice should be a no-op")
+	}
+}
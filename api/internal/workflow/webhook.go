@@ -0,0 +1,228 @@
+package workflow
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// TriggerKindWebhook marks a trigger node that is started by POST /hooks/:workflowId/:token.
+const TriggerKindWebhook = "webhook"
+
+// WebhookConfig is the part of a webhook trigger's config used to accept requests.
+type WebhookConfig struct {
+	TriggerKind string `json:"triggerKind"`
+	Token       string `json:"webhookToken"`
+	// Secret enables HMAC signature checks when set.
+	Secret string `json:"webhookSecret"`
+}
+
+func parseWebhookConfig(node Node) (WebhookConfig, bool) {
+	if node.Type != NodeTypeTrigger {
+		return WebhookConfig{}, false
+	}
+	var cfg WebhookConfig
+	b, err := json.Marshal(node.Config)
+	if err != nil {
+		return WebhookConfig{}, false
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil || cfg.TriggerKind != TriggerKindWebhook {
+		return WebhookConfig{}, false
+	}
+	return cfg, true
+}
+
+// FindWebhookTrigger returns the webhook trigger whose token matches.
+func FindWebhookTrigger(wf Workflow, token string) (Node, WebhookConfig, bool) {
+	if token == "" {
+		return Node{}, WebhookConfig{}, false
+	}
+	for _, node := range wf.Nodes {
+		cfg, ok := parseWebhookConfig(node)
+		if !ok || cfg.Token == "" {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(cfg.Token), []byte(token)) == 1 {
+			return node, cfg, true
+		}
+	}
+	return Node{}, WebhookConfig{}, false
+}
+
+// assignWebhookTokens gives every webhook trigger a token. Tokens already stored for
+// the same node in previous are kept so the hook URL stays stable across saves.
+func assignWebhookTokens(wf Workflow, previous []Node) (Workflow, error) {
+	known := make(map[string]string)
+	for _, node := range previous {
+		if cfg, ok := parseWebhookConfig(node); ok && cfg.Token != "" {
+			known[node.ID] = cfg.Token
+		}
+	}
+
+	nodes := make([]Node, len(wf.Nodes))
+	copy(nodes, wf.Nodes)
+	for i, node := range nodes {
+		cfg, ok := parseWebhookConfig(node)
+		if !ok || cfg.Token != "" {
+			continue
+		}
+
+		token, ok := known[node.ID]
+		if !ok {
+			var err error
+			if token, err = newWebhookToken(); err != nil {
+				return Workflow{}, err
+			}
+		}
+
+		raw, err := configMap(node)
+		if err != nil {
+			return Workflow{}, err
+		}
+		raw["webhookToken"] = token
+		nodes[i].Config = raw
+	}
+	wf.Nodes = nodes
+	return wf, nil
+}
+
+// RedactedSecret replaces webhook tokens and secrets in responses and exports. Saving
+// a node with the placeholder keeps the value already stored for that node.
+const RedactedSecret = "********"
+
+// RedactWebhookSecrets masks the token and HMAC secret of every webhook trigger so
+// read and export paths never return them.
+func RedactWebhookSecrets(wf Workflow) Workflow {
+	wf.Nodes = RedactWebhookNodes(wf.Nodes)
+	return wf
+}
+
+// RedactWebhookNodes masks webhook tokens and secrets in a node list, such as a version snapshot.
+func RedactWebhookNodes(nodes []Node) []Node {
+	out := make([]Node, len(nodes))
+	copy(out, nodes)
+	for i, node := range out {
+		cfg, ok := parseWebhookConfig(node)
+		if !ok || (cfg.Token == "" && cfg.Secret == "") {
+			continue
+		}
+		raw, err := configMap(node)
+		if err != nil {
+			continue
+		}
+		for _, key := range []string{"webhookToken", "webhookSecret"} {
+			if value, _ := raw[key].(string); value != "" {
+				raw[key] = RedactedSecret
+			}
+		}
+		out[i].Config = raw
+	}
+	return out
+}
+
+// RevealNewWebhookTokens redacts saved but keeps the tokens that differ from the ones
+// stored for the same node in previous, so the caller that just created or replaced a
+// hook can read its URL once.
+func RevealNewWebhookTokens(saved Workflow, previous []Node) Workflow {
+	known := make(map[string]string)
+	for _, node := range previous {
+		if cfg, ok := parseWebhookConfig(node); ok {
+			known[node.ID] = cfg.Token
+		}
+	}
+
+	out := RedactWebhookSecrets(saved)
+	for i, node := range saved.Nodes {
+		cfg, ok := parseWebhookConfig(node)
+		if !ok || cfg.Token == "" || known[node.ID] == cfg.Token {
+			continue
+		}
+		if raw, err := configMap(out.Nodes[i]); err == nil {
+			raw["webhookToken"] = cfg.Token
+			out.Nodes[i].Config = raw
+		}
+	}
+	return out
+}
+
+// RestoreWebhookSecrets replaces redaction placeholders with the values stored for the
+// same node in previous. A placeholder without a stored value is dropped, so a new
+// token is generated and no secret is set.
+func RestoreWebhookSecrets(wf Workflow, previous []Node) (Workflow, error) {
+	known := make(map[string]WebhookConfig)
+	for _, node := range previous {
+		if cfg, ok := parseWebhookConfig(node); ok {
+			known[node.ID] = cfg
+		}
+	}
+
+	nodes := make([]Node, len(wf.Nodes))
+	copy(nodes, wf.Nodes)
+	for i, node := range nodes {
+		cfg, ok := parseWebhookConfig(node)
+		if !ok || (cfg.Token != RedactedSecret && cfg.Secret != RedactedSecret) {
+			continue
+		}
+		raw, err := configMap(node)
+		if err != nil {
+			return Workflow{}, err
+		}
+		stored := known[node.ID]
+		restore := func(key, value string) {
+			if raw[key] != RedactedSecret {
+				return
+			}
+			if value == "" {
+				delete(raw, key)
+				return
+			}
+			raw[key] = value
+		}
+		restore("webhookToken", stored.Token)
+		restore("webhookSecret", stored.Secret)
+		nodes[i].Config = raw
+	}
+	wf.Nodes = nodes
+	return wf, nil
+}
+
+// hasRedactedWebhookSecrets reports whether any webhook trigger carries a redaction placeholder.
+func hasRedactedWebhookSecrets(wf Workflow) bool {
+	for _, node := range wf.Nodes {
+		if cfg, ok := parseWebhookConfig(node); ok && (cfg.Token == RedactedSecret || cfg.Secret == RedactedSecret) {
+			return true
+		}
+	}
+	return false
+}
+
+func configMap(node Node) (map[string]any, error) {
+	var raw map[string]any
+	b, err := json.Marshal(node.Config)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// needsWebhookTokens reports whether any webhook trigger is missing its token.
+func needsWebhookTokens(wf Workflow) bool {
+	for _, node := range wf.Nodes {
+		if cfg, ok := parseWebhookConfig(node); ok && cfg.Token == "" {
+			return true
+		}
+	}
+	return false
+}
+
+func newWebhookToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
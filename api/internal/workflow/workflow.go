@@ -13,16 +13,22 @@ import (
 type NodeType string
 
 type Workflow struct {
-	ID          string            `json:"id"`
-	Name        string            `json:"name"`
-	Description string            `json:"description"`
-	Nodes       []Node            `json:"nodes"`
-	Edges       []Edge            `json:"edges"`
-	Filters     map[string]string `json:"filters"`
-	IsActive    bool              `json:"isActive"`
-	CanvasZoom  *float64          `json:"canvasZoom,omitempty"`
-	CreatedAt   time.Time         `json:"createdAt"`
-	UpdatedAt   time.Time         `json:"updatedAt"`
+	ID                 string            `json:"id"`
+	Name               string            `json:"name"`
+	Description        string            `json:"description"`
+	Nodes              []Node            `json:"nodes"`
+	Edges              []Edge            `json:"edges"`
+	Filters            map[string]string `json:"filters"`
+	IsActive           bool              `json:"isActive"`
+	CanvasZoom         *float64          `json:"canvasZoom,omitempty"`
+	VersionNumber      int               `json:"versionNumber"`
+	PublishedVersionID *string           `json:"publishedVersionId,omitempty"`
+	Revision           int               `json:"revision"`
+	CreatedAt          time.Time         `json:"createdAt"`
+	UpdatedAt          time.Time         `json:"updatedAt"`
+	// Problems is what Validate found when the workflow was saved; drafts are stored
+	// with them. It is not persisted and is empty on reads.
+	Problems []Problem `json:"problems,omitempty"`
 }
 
 type Node struct {
@@ -56,6 +62,17 @@ type VersionMeta struct {
 	CreatedAt     time.Time `json:"createdAt"`
 	IsActive      bool      `json:"isActive"`
 	Name          string    `json:"name"`
+	IsPublished   bool      `json:"isPublished"`
+	Label         string    `json:"label"`
+	Comment       string    `json:"comment"`
+	Pinned        bool      `json:"pinned"`
+}
+
+// VersionUpdate annotates a version; nil fields are left untouched.
+type VersionUpdate struct {
+	Label   *string
+	Comment *string
+	Pinned  *bool
 }
 
 type Version struct {
@@ -68,14 +85,33 @@ type Version struct {
 	RestoredFromVersionID *string           `json:"restoredFromVersionId,omitempty"`
 }
 
+// ErrRevisionConflict is returned by SaveIfMatch when the workflow changed in between.
+// Revision increases on every change to the draft and backs the workflow ETag.
+var ErrRevisionConflict = workflowpersist.ErrRevisionConflict
+
+// ErrNotFound is returned when the requested workflow or version does not exist.
+var ErrNotFound = workflowpersist.ErrNotFound
+
+// ErrNotPublished is returned by FindPublished for a workflow with no published version.
+var ErrNotPublished = workflowpersist.ErrNotPublished
+
 type Repository interface {
 	Save(ctx context.Context, wf Workflow) (Workflow, error)
+	// SaveIfMatch saves wf only if the stored workflow is still at revision; revision 0
+	// means the workflow must not exist yet.
+	SaveIfMatch(ctx context.Context, wf Workflow, revision int) (Workflow, error)
 	FindByID(ctx context.Context, id string) (Workflow, error)
 	List(ctx context.Context) ([]Workflow, error)
 	Delete(ctx context.Context, id string) error
 	ListVersions(ctx context.Context, workflowID string) ([]VersionMeta, error)
 	GetVersion(ctx context.Context, workflowID, versionID string) (Version, error)
 	RestoreVersion(ctx context.Context, workflowID, versionID string) (Workflow, error)
+	PublishVersion(ctx context.Context, workflowID, versionID string) (Workflow, error)
+	UpdateVersion(ctx context.Context, workflowID, versionID string, update VersionUpdate) (VersionMeta, error)
+	// FindPublished returns the workflow with the graph of its published version.
+	FindPublished(ctx context.Context, id string) (Workflow, error)
+	// ListPublished returns every published workflow with its published graph.
+	ListPublished(ctx context.Context) ([]Workflow, error)
 }
 
 type memoryRepository struct {
@@ -88,15 +124,46 @@ func NewMemoryRepository() Repository {
 }
 
 func (r *memoryRepository) Save(ctx context.Context, wf Workflow) (Workflow, error) {
+	return r.save(wf, nil)
+}
+
+func (r *memoryRepository) SaveIfMatch(ctx context.Context, wf Workflow, revision int) (Workflow, error) {
+	return r.save(wf, &revision)
+}
+
+func (r *memoryRepository) save(wf Workflow, expected *int) (Workflow, error) {
+	problems, err := checkActivation(wf)
+	if err != nil {
+		return Workflow{}, err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	current := r.workflows[wf.ID]
+	if expected != nil && *expected != current.Revision {
+		return Workflow{}, ErrRevisionConflict
+	}
+
+	wf.Nodes, _ = AssignTriggerKinds(wf.Nodes)
+	wf, err = RestoreWebhookSecrets(wf, current.Nodes)
+	if err != nil {
+		return Workflow{}, err
+	}
+	wf, err = assignWebhookTokens(wf, current.Nodes)
+	if err != nil {
+		return Workflow{}, err
+	}
+
 	wf.UpdatedAt = time.Now()
 	if wf.CreatedAt.IsZero() {
 		wf.CreatedAt = wf.UpdatedAt
 	}
+	wf.Revision = current.Revision + 1
+	wf.Problems = nil
 
 	r.workflows[wf.ID] = wf
+	wf.Problems = problems
 	return wf, nil
 }
 
@@ -106,7 +173,7 @@ func (r *memoryRepository) FindByID(ctx context.Context, id string) (Workflow, e
 
 	wf, ok := r.workflows[id]
 	if !ok {
-		return Workflow{}, errors.New("workflow not found")
+		return Workflow{}, ErrNotFound
 	}
 	return wf, nil
 }
@@ -130,6 +197,16 @@ func (r *memoryRepository) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+// checkActivation validates wf on every save. Drafts are saved with their problems;
+// activating an invalid graph is rejected.
+func checkActivation(wf Workflow) ([]Problem, error) {
+	problems := Validate(wf)
+	if wf.IsActive && len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return problems, nil
+}
+
 type dbRepository struct {
 	repo workflowpersist.Repository
 }
@@ -139,6 +216,35 @@ func NewDBRepository(repo workflowpersist.Repository) Repository {
 }
 
 func (r *dbRepository) Save(ctx context.Context, wf Workflow) (Workflow, error) {
+	return r.save(ctx, wf, nil)
+}
+
+func (r *dbRepository) SaveIfMatch(ctx context.Context, wf Workflow, revision int) (Workflow, error) {
+	return r.save(ctx, wf, &revision)
+}
+
+func (r *dbRepository) save(ctx context.Context, wf Workflow, expectedRevision *int) (Workflow, error) {
+	problems, err := checkActivation(wf)
+	if err != nil {
+		return Workflow{}, err
+	}
+
+	wf.Nodes, _ = AssignTriggerKinds(wf.Nodes)
+	if needsWebhookTokens(wf) || hasRedactedWebhookSecrets(wf) {
+		var previous []Node
+		if wf.ID != "" {
+			if current, err := r.FindByID(ctx, wf.ID); err == nil {
+				previous = current.Nodes
+			}
+		}
+		if wf, err = RestoreWebhookSecrets(wf, previous); err != nil {
+			return Workflow{}, err
+		}
+		if wf, err = assignWebhookTokens(wf, previous); err != nil {
+			return Workflow{}, err
+		}
+	}
+
 	nodesJSON, err := json.Marshal(wf.Nodes)
 	if err != nil {
 		return Workflow{}, err
@@ -158,40 +264,19 @@ func (r *dbRepository) Save(ctx context.Context, wf Workflow) (Workflow, error)
 		Filters:     wf.Filters,
 		IsActive:    wf.IsActive,
 		CanvasZoom:  wf.CanvasZoom,
+
+		ExpectedRevision: expectedRevision,
 	})
 	if err != nil {
 		return Workflow{}, err
 	}
 
-	var nodes []Node
-	if err := json.Unmarshal(entity.Nodes, &nodes); err != nil {
-		return Workflow{}, err
-	}
-
-	var edges []Edge
-	if err := json.Unmarshal(entity.Edges, &edges); err != nil {
+	saved, err := entityToWorkflow(entity)
+	if err != nil {
 		return Workflow{}, err
 	}
-
-	filters := make(map[string]string)
-	for k, v := range entity.Filters {
-		if str, ok := v.(string); ok {
-			filters[k] = str
-		}
-	}
-
-	return Workflow{
-		ID:          entity.ID,
-		Name:        entity.Name,
-		Description: entity.Description,
-		Nodes:       nodes,
-		Edges:       edges,
-		Filters:     filters,
-		IsActive:    entity.IsActive,
-		CanvasZoom:  entity.CanvasZoom,
-		CreatedAt:   entity.CreatedAt,
-		UpdatedAt:   entity.UpdatedAt,
-	}, nil
+	saved.Problems = problems
+	return saved, nil
 }
 
 func (r *dbRepository) FindByID(ctx context.Context, id string) (Workflow, error) {
@@ -199,36 +284,7 @@ func (r *dbRepository) FindByID(ctx context.Context, id string) (Workflow, error
 	if err != nil {
 		return Workflow{}, err
 	}
-
-	var nodes []Node
-	if err := json.Unmarshal(entity.Nodes, &nodes); err != nil {
-		return Workflow{}, err
-	}
-
-	var edges []Edge
-	if err := json.Unmarshal(entity.Edges, &edges); err != nil {
-		return Workflow{}, err
-	}
-
-	filters := make(map[string]string)
-	for k, v := range entity.Filters {
-		if str, ok := v.(string); ok {
-			filters[k] = str
-		}
-	}
-
-	return Workflow{
-		ID:          entity.ID,
-		Name:        entity.Name,
-		Description: entity.Description,
-		Nodes:       nodes,
-		Edges:       edges,
-		Filters:     filters,
-		IsActive:    entity.IsActive,
-		CanvasZoom:  entity.CanvasZoom,
-		CreatedAt:   entity.CreatedAt,
-		UpdatedAt:   entity.UpdatedAt,
-	}, nil
+	return entityToWorkflow(entity)
 }
 
 func (r *dbRepository) List(ctx context.Context) ([]Workflow, error) {
@@ -239,35 +295,11 @@ func (r *dbRepository) List(ctx context.Context) ([]Workflow, error) {
 
 	workflows := make([]Workflow, len(entities))
 	for i, entity := range entities {
-		var nodes []Node
-		if err := json.Unmarshal(entity.Nodes, &nodes); err != nil {
-			return nil, err
-		}
-
-		var edges []Edge
-		if err := json.Unmarshal(entity.Edges, &edges); err != nil {
+		wf, err := entityToWorkflow(entity)
+		if err != nil {
 			return nil, err
 		}
-
-		filters := make(map[string]string)
-		for k, v := range entity.Filters {
-			if str, ok := v.(string); ok {
-				filters[k] = str
-			}
-		}
-
-		workflows[i] = Workflow{
-			ID:          entity.ID,
-			Name:        entity.Name,
-			Description: entity.Description,
-			Nodes:       nodes,
-			Edges:       edges,
-			Filters:     filters,
-			IsActive:    entity.IsActive,
-			CanvasZoom:  entity.CanvasZoom,
-			CreatedAt:   entity.CreatedAt,
-			UpdatedAt:   entity.UpdatedAt,
-		}
+		workflows[i] = wf
 	}
 
 	return workflows, nil
@@ -284,19 +316,39 @@ func (r *dbRepository) ListVersions(ctx context.Context, workflowID string) ([]V
 	}
 	out := make([]VersionMeta, len(metas))
 	for i, m := range metas {
-		out[i] = VersionMeta{
-			ID:            m.ID,
-			WorkflowID:    m.WorkflowID,
-			VersionNumber: m.VersionNumber,
-			Source:        m.Source,
-			CreatedAt:     m.CreatedAt,
-			IsActive:      m.IsActive,
-			Name:          m.Name,
-		}
+		out[i] = versionMetaFromPersistence(m)
 	}
 	return out, nil
 }
 
+func (r *dbRepository) UpdateVersion(ctx context.Context, workflowID, versionID string, update VersionUpdate) (VersionMeta, error) {
+	meta, err := r.repo.UpdateVersion(ctx, workflowID, versionID, workflowpersist.VersionUpdateInput{
+		Label:   update.Label,
+		Comment: update.Comment,
+		Pinned:  update.Pinned,
+	})
+	if err != nil {
+		return VersionMeta{}, err
+	}
+	return versionMetaFromPersistence(meta), nil
+}
+
+func versionMetaFromPersistence(m workflowpersist.VersionMeta) VersionMeta {
+	return VersionMeta{
+		ID:            m.ID,
+		WorkflowID:    m.WorkflowID,
+		VersionNumber: m.VersionNumber,
+		Source:        m.Source,
+		CreatedAt:     m.CreatedAt,
+		IsActive:      m.IsActive,
+		Name:          m.Name,
+		IsPublished:   m.IsPublished,
+		Label:         m.Label,
+		Comment:       m.Comment,
+		Pinned:        m.Pinned,
+	}
+}
+
 func (r *dbRepository) GetVersion(ctx context.Context, workflowID, versionID string) (Version, error) {
 	entity, err := r.repo.FindVersionByID(ctx, workflowID, versionID)
 	if err != nil {
@@ -329,6 +381,9 @@ func (r *dbRepository) GetVersion(ctx context.Context, workflowID, versionID str
 			CreatedAt:     entity.CreatedAt,
 			IsActive:      entity.IsActive,
 			Name:          entity.Name,
+			Label:         entity.Label,
+			Comment:       entity.Comment,
+			Pinned:        entity.Pinned,
 		},
 		Description:           entity.Description,
 		Nodes:                 nodes,
@@ -355,6 +410,10 @@ func (r *memoryRepository) GetVersion(ctx context.Context, workflowID, versionID
 	return Version{}, errors.New("version history not supported in memory repository")
 }
 
+func (r *memoryRepository) UpdateVersion(ctx context.Context, workflowID, versionID string, update VersionUpdate) (VersionMeta, error) {
+	return VersionMeta{}, errors.New("version history not supported in memory repository")
+}
+
 func (r *memoryRepository) RestoreVersion(ctx context.Context, workflowID, versionID string) (Workflow, error) {
 	return Workflow{}, errors.New("version history not supported in memory repository")
 }
@@ -378,15 +437,18 @@ func entityToWorkflow(entity workflowpersist.WorkflowEntity) (Workflow, error) {
 	}
 
 	return Workflow{
-		ID:          entity.ID,
-		Name:        entity.Name,
-		Description: entity.Description,
-		Nodes:       nodes,
-		Edges:       edges,
-		Filters:     filters,
-		IsActive:    entity.IsActive,
-		CanvasZoom:  entity.CanvasZoom,
-		CreatedAt:   entity.CreatedAt,
-		UpdatedAt:   entity.UpdatedAt,
+		ID:                 entity.ID,
+		Name:               entity.Name,
+		Description:        entity.Description,
+		Nodes:              nodes,
+		Edges:              edges,
+		Filters:            filters,
+		IsActive:           entity.IsActive,
+		CanvasZoom:         entity.CanvasZoom,
+		VersionNumber:      entity.VersionNumber,
+		PublishedVersionID: entity.PublishedVersionID,
+		Revision:           entity.Revision,
+		CreatedAt:          entity.CreatedAt,
+		UpdatedAt:          entity.UpdatedAt,
 	}, nil
 }
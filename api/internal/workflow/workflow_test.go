@@ -0,0 +1,106 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSaveIfMatch_RejectsStaleRevision(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	wf := validWorkflow()
+
+	created, err := repo.SaveIfMatch(ctx, wf, 0)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := repo.SaveIfMatch(ctx, wf, 0); !errors.Is(err, ErrRevisionConflict) {
+		t.Fatalf("expected conflict when creating twice, got %v", err)
+	}
+	if _, err := repo.SaveIfMatch(ctx, wf, created.Revision); err != nil {
+		t.Fatalf("save at current revision: %v", err)
+	}
+	if _, err := repo.SaveIfMatch(ctx, wf, created.Revision); !errors.Is(err, ErrRevisionConflict) {
+		t.Fatalf("expected conflict for stale revision, got %v", err)
+	}
+}
+
+func TestSave_AssignsStableWebhookTokens(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	wf := validWorkflow()
+	wf.Nodes[0].Config = map[string]any{"variant": "trigger", "triggerKind": TriggerKindWebhook}
+
+	saved, err := repo.Save(ctx, wf)
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	token, _ := saved.Nodes[0].Config.(map[string]any)["webhookToken"].(string)
+	if len(token) != 32 {
+		t.Fatalf("expected generated token, got %q", token)
+	}
+
+	// The editor may send the node back without the token; it must be kept.
+	resaved, err := repo.Save(ctx, wf)
+	if err != nil {
+		t.Fatalf("resave: %v", err)
+	}
+	if _, _, ok := FindWebhookTrigger(resaved, token); !ok {
+		t.Fatalf("token changed on resave: %v", resaved.Nodes[0].Config)
+	}
+	if _, _, ok := FindWebhookTrigger(resaved, "nope"); ok {
+		t.Fatalf("unexpected match for unknown token")
+	}
+}
+
+func TestRedactedWebhookSecretsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	wf := validWorkflow()
+	wf.Nodes[0].Config = map[string]any{"variant": "trigger", "triggerKind": TriggerKindWebhook, "webhookSecret": "s3cret"}
+
+	saved, err := repo.Save(ctx, wf)
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	_, stored, _ := FindWebhookTrigger(saved, saved.Nodes[0].Config.(map[string]any)["webhookToken"].(string))
+
+	redacted := RedactWebhookSecrets(saved)
+	cfg := redacted.Nodes[0].Config.(map[string]any)
+	if cfg["webhookToken"] != RedactedSecret || cfg["webhookSecret"] != RedactedSecret {
+		t.Fatalf("secrets not redacted: %v", cfg)
+	}
+	if saved.Nodes[0].Config.(map[string]any)["webhookSecret"] != "s3cret" {
+		t.Fatalf("redaction modified the stored workflow")
+	}
+
+	// Saving the redacted workflow back keeps the stored token and secret.
+	resaved, err := repo.Save(ctx, redacted)
+	if err != nil {
+		t.Fatalf("resave: %v", err)
+	}
+	if _, cfg, ok := FindWebhookTrigger(resaved, stored.Token); !ok || cfg.Secret != "s3cret" {
+		t.Fatalf("stored secrets lost on resave: %v", resaved.Nodes[0].Config)
+	}
+
+	// A placeholder without a stored value is dropped rather than saved.
+	copied := redacted
+	copied.ID = "copy"
+	fresh, err := repo.Save(ctx, copied)
+	if err != nil {
+		t.Fatalf("save copy: %v", err)
+	}
+	_, freshCfg, _ := FindWebhookTrigger(fresh, fresh.Nodes[0].Config.(map[string]any)["webhookToken"].(string))
+	if freshCfg.Token == RedactedSecret || freshCfg.Token == stored.Token || freshCfg.Secret != "" {
+		t.Fatalf("unexpected secrets on copy: %+v", freshCfg)
+	}
+
+	revealed := RevealNewWebhookTokens(fresh, nil)
+	if revealed.Nodes[0].Config.(map[string]any)["webhookToken"] != freshCfg.Token {
+		t.Fatalf("new token not revealed: %v", revealed.Nodes[0].Config)
+	}
+	if again := RevealNewWebhookTokens(fresh, fresh.Nodes); again.Nodes[0].Config.(map[string]any)["webhookToken"] != RedactedSecret {
+		t.Fatalf("known token revealed: %v", again.Nodes[0].Config)
+	}
+}
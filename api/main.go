@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
@@ -12,12 +15,20 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/nats-io/nats.go"
 	"gorm.io/gorm"
 
 	"notiair/handlers"
+	"notiair/internal/apply"
+	"notiair/internal/bundle"
 	"notiair/internal/config"
+	"notiair/internal/eventschema"
 	"notiair/internal/persistence/channel"
 	"notiair/internal/persistence/database"
+	"notiair/internal/persistence/dedup"
+	eventschemapersistence "notiair/internal/persistence/eventschema"
+	"notiair/internal/persistence/execution"
+	"notiair/internal/persistence/migration"
 	"notiair/internal/persistence/outbox"
 	persiststorage "notiair/internal/persistence/storage"
 	"notiair/internal/persistence/serviceconfig"
@@ -25,6 +36,7 @@ import (
 	"notiair/internal/storage"
 	"notiair/internal/queue"
 	"notiair/internal/routing"
+	"notiair/internal/schedule"
 	"notiair/internal/stream"
 	"notiair/internal/templates"
 	"notiair/internal/workflow"
@@ -37,9 +49,15 @@ var (
 	dbConn            *gorm.DB
 	queueClient       queue.Client
 	serviceConfigRepo serviceconfig.Repository
+	eventSchemas      *eventschema.Registry
 	streamConsumer    *stream.Consumer
+	streamSources     stream.Sources // Kafka, Redis Streams и NATS JetStream
+	natsConn          *nats.Conn
 	streamHub         *stream.Hub
 	redisStore        *stream.RedisStore
+	workflowChanges   *stream.WorkflowChanges
+	deadLetters       *stream.DeadLetterQueue
+	workflowScheduler *schedule.Scheduler
 )
 
 func initConfig() {
@@ -59,14 +77,53 @@ func initDatabase() {
 
 	serviceConfigRepo = serviceconfig.NewRepository(dbConn)
 
-	if err := dbConn.AutoMigrate(&outbox.Message{}, &serviceconfig.ServiceConfig{}, &channel.Channel{}, &workflowpersistence.WorkflowEntity{}, &workflowpersistence.WorkflowVersionEntity{}, &persiststorage.Record{}); err != nil {
+	// Workflows saved before the draft/published split get their latest version published once.
+	needsPublishBackfill := dbConn.Migrator().HasTable(&workflowpersistence.WorkflowEntity{}) &&
+		!dbConn.Migrator().HasColumn(&workflowpersistence.WorkflowEntity{}, "PublishedVersionID")
+
+	if err := dbConn.AutoMigrate(&outbox.Message{}, &serviceconfig.ServiceConfig{}, &channel.Channel{}, &workflowpersistence.WorkflowEntity{}, &workflowpersistence.WorkflowVersionEntity{}, &persiststorage.Record{}, &execution.Execution{}, &execution.Step{}, &dedup.ProcessedEvent{}, &eventschemapersistence.Schema{}, &eventschemapersistence.Rejection{}, &migration.Applied{}); err != nil {
 		log.Fatalf("migrate db: %v", err)
 	}
 
+	// Один реестр на процесс: сохранение схемы через API сразу сбрасывает кеш consumer
+	eventSchemas = eventschema.NewRegistry(eventschemapersistence.NewRepository(dbConn))
+
 	if err := seedServiceConfigs(context.Background()); err != nil {
 		log.Fatalf("seed service configs: %v", err)
 	}
 
+	if needsPublishBackfill {
+		published, err := workflowpersistence.NewRepository(dbConn).BackfillPublishedVersions(context.Background())
+		if err != nil {
+			log.Fatalf("backfill published workflow versions: %v", err)
+		}
+		log.Printf("published latest version of %d existing workflows", published)
+	}
+
+	// Revision 0 означает "workflow еще не существует", поэтому старые строки переводим на 1 (идемпотентно).
+	revised, err := workflowpersistence.NewRepository(dbConn).BackfillRevisions(context.Background())
+	if err != nil {
+		log.Fatalf("backfill workflow revisions: %v", err)
+	}
+	if revised > 0 {
+		log.Printf("set revision 1 on %d existing workflows", revised)
+	}
+
+	// Черновикам, сохраненным до появления config.triggerKind, проставляем тип один раз;
+	// снимки версий не переписываются — их триггеры распознает TriggerKindOf при чтении.
+	var migrated int64
+	_, err = migration.NewRepository(dbConn).RunOnce(context.Background(), workflow.TriggerKindsMigration, func(ctx context.Context) error {
+		var err error
+		migrated, err = workflow.MigrateTriggerKinds(ctx, workflowpersistence.NewRepository(dbConn))
+		return err
+	})
+	if err != nil {
+		log.Fatalf("migrate workflow trigger kinds: %v", err)
+	}
+	if migrated > 0 {
+		log.Printf("set trigger kind on %d workflow drafts", migrated)
+	}
+
 	log.Println("database initialized successfully")
 }
 
@@ -86,16 +143,22 @@ func seedServiceConfigs(ctx context.Context) error {
 
 func buildApplication() *fiber.App {
 	templateRepo := templates.NewMemoryRepository()
-	workflowPersistenceRepo := workflowpersistence.NewRepository(dbConn)
+	workflowPersistenceRepo := workflowpersistence.NewRepositoryWithLimit(dbConn, appConfig.Workflow.MaxVersions)
 	workflowRepo := workflow.NewDBRepository(workflowPersistenceRepo)
+	if workflowChanges != nil {
+		// Изменения workflows из API сбрасывают индекс триггеров stream consumer на всех репликах
+		workflowRepo = workflow.WithChangeHook(workflowRepo, workflowChanges.Notify)
+	}
 	storageRepo := persiststorage.NewRepository(dbConn)
 	storageSvc := storage.NewService(storageRepo)
 	routerSvc := routing.NewService(workflowRepo, storageSvc)
 	outboxRepo := outbox.NewRepository(dbConn)
+	executionRepo := execution.NewRepository(dbConn)
 
-	notificationService := services.NewNotificationService(routerSvc, queueClient, outboxRepo)
+	notificationService := services.NewNotificationService(routerSvc, queueClient, outboxRepo, executionRepo)
 	queueInspector := queue.NewNoopInspector()
 	channelRepo := channel.NewRepository(dbConn)
+	bundleSvc := bundle.NewService(workflowRepo, templateRepo, channelRepo)
 	streamConfig := handlers.StreamConfig{
 		Brokers: appConfig.Stream.Brokers,
 		Topic:   appConfig.Stream.Topic,
@@ -117,7 +180,14 @@ func buildApplication() *fiber.App {
 		go streamHub.Run()
 	}
 	
-	apiHandlers := handlers.NewAPI(notificationService, templateRepo, workflowRepo, queueInspector, serviceConfigRepo, channelRepo, streamConfig, streamHub, redisStore, storageSvc)
+	// DLQ и управление offset есть только у Kafka; без нее handlers отвечают 503
+	var deadLetterStore handlers.DeadLetterStore
+	var streamAdmin handlers.StreamAdmin
+	if streamConsumer != nil {
+		deadLetterStore, streamAdmin = deadLetters, streamConsumer
+	}
+
+	apiHandlers := handlers.NewAPI(notificationService, templateRepo, workflowRepo, queueInspector, serviceConfigRepo, channelRepo, streamConfig, streamHub, redisStore, storageSvc, executionRepo, routerSvc, bundleSvc, deadLetterStore, streamAdmin, eventSchemas, streamSources)
 
 	app := fiber.New(fiber.Config{
 		AppName:      "NotiAir Notification API",
@@ -128,7 +198,8 @@ func buildApplication() *fiber.App {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     "*",
 		AllowMethods:     "GET,POST,PUT,PATCH,DELETE,OPTIONS",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization",
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,If-Match",
+		ExposeHeaders:    "ETag",
 		AllowCredentials: false,
 	}))
 	app.Use(requestid.New())
@@ -141,13 +212,14 @@ func buildApplication() *fiber.App {
 }
 
 func initStreamConsumer() error {
-	workflowPersistenceRepo := workflowpersistence.NewRepository(dbConn)
+	workflowPersistenceRepo := workflowpersistence.NewRepositoryWithLimit(dbConn, appConfig.Workflow.MaxVersions)
 	workflowRepo := workflow.NewDBRepository(workflowPersistenceRepo)
 	storageRepo := persiststorage.NewRepository(dbConn)
 	storageSvc := storage.NewService(storageRepo)
 	routerSvc := routing.NewService(workflowRepo, storageSvc)
 	outboxRepo := outbox.NewRepository(dbConn)
-	notificationService := services.NewNotificationService(routerSvc, queueClient, outboxRepo)
+	executionRepo := execution.NewRepository(dbConn)
+	notificationService := services.NewNotificationService(routerSvc, queueClient, outboxRepo, executionRepo)
 
 	// Создаем Redis store если еще не создан
 	if redisStore == nil {
@@ -165,39 +237,200 @@ func initStreamConsumer() error {
 		go streamHub.Run()
 	}
 
-	var err error
-	streamConsumer, err = stream.NewConsumer(
-		appConfig.Stream.Brokers,
-		appConfig.Stream.Topic,
-		appConfig.Stream.GroupID,
-		workflowRepo,
-		notificationService,
-		streamHub,
-		redisStore,
-	)
-	if err != nil {
-		return err
+	retry := stream.RetryPolicy{
+		MaxRetries: appConfig.Stream.MaxRetries,
+		Backoff:    appConfig.Stream.RetryBackoff,
+		MaxBackoff: appConfig.Stream.RetryMaxBackoff,
+	}
+
+	// Kafka не обязательна: сервис может читать только Redis Streams или NATS
+	if appConfig.Stream.KafkaEnabled {
+		// DLQ для событий, которые не удалось разобрать или обработать после повторов
+		var err error
+		deadLetters, err = stream.NewDeadLetterQueue(appConfig.Stream.Brokers, appConfig.Stream.DLQTopic)
+		if err != nil {
+			return err
+		}
+
+		streamConsumer, err = stream.NewConsumer(
+			appConfig.Stream.Brokers,
+			appConfig.Stream.Topic,
+			appConfig.Stream.GroupID,
+			workflowRepo,
+			notificationService,
+			streamHub,
+			redisStore,
+			retry,
+			deadLetters,
+			dedup.NewRepository(dbConn),
+			stream.Concurrency{
+				Workers: appConfig.Stream.Workers,
+				KeyPath: appConfig.Stream.OrderingKey,
+			},
+			eventSchemas,
+		)
+		if err != nil {
+			return err
+		}
+		streamSources = append(streamSources, streamConsumer)
+	}
+
+	// Триггеры с broker = "redis" читаются из Redis Streams того же REDIS_URL
+	if appConfig.Stream.Redis.Enabled && redisStore != nil {
+		streamSources = append(streamSources, stream.NewRedisStreamConsumer(
+			redisStore.Client(),
+			stream.RedisStreamsConfig{
+				Group:         appConfig.Stream.Redis.Group,
+				Consumer:      appConfig.Stream.Redis.Consumer,
+				DefaultStream: appConfig.Stream.Redis.DefaultStream,
+				ClaimIdle:     appConfig.Stream.Redis.ClaimIdle,
+			},
+			workflowRepo,
+			notificationService,
+			streamHub,
+			redisStore,
+			retry,
+			dedup.NewRepository(dbConn),
+			eventSchemas,
+		))
+	}
+
+	// Триггеры с broker = "nats" читаются из NATS JetStream
+	if appConfig.Stream.NATS.URL != "" {
+		var err error
+		natsConn, err = nats.Connect(appConfig.Stream.NATS.URL, nats.Name("notiair"), nats.MaxReconnects(-1))
+		if err != nil {
+			return fmt.Errorf("connect nats: %w", err)
+		}
+		natsConsumer, err := stream.NewNATSConsumer(
+			natsConn,
+			stream.NATSConfig{
+				Stream:           appConfig.Stream.NATS.Stream,
+				Durable:          appConfig.Stream.NATS.Durable,
+				DefaultSubject:   appConfig.Stream.NATS.DefaultSubject,
+				AckWait:          appConfig.Stream.NATS.AckWait,
+				DeadLetterPrefix: appConfig.Stream.NATS.DeadLetterPrefix,
+			},
+			workflowRepo,
+			notificationService,
+			streamHub,
+			redisStore,
+			retry,
+			dedup.NewRepository(dbConn),
+			eventSchemas,
+		)
+		if err != nil {
+			return err
+		}
+		streamSources = append(streamSources, natsConsumer)
+	}
+
+	// Индекс триггеров consumers сбрасывается при изменении workflows (в т.ч. на других репликах)
+	workflowChanges = stream.NewWorkflowChanges(redisStore)
+	for _, source := range streamSources {
+		workflowChanges.Subscribe(source.InvalidateIndex)
 	}
 
 	return nil
 }
 
+// initScheduler готовит запуск workflow по расписанию (schedule-триггеры).
+func initScheduler() error {
+	if !appConfig.Schedule.Enabled {
+		return nil
+	}
+
+	workflowRepo := workflow.NewDBRepository(workflowpersistence.NewRepositoryWithLimit(dbConn, appConfig.Workflow.MaxVersions))
+	storageSvc := storage.NewService(persiststorage.NewRepository(dbConn))
+	routerSvc := routing.NewService(workflowRepo, storageSvc)
+	notificationService := services.NewNotificationService(routerSvc, queueClient, outbox.NewRepository(dbConn), execution.NewRepository(dbConn))
+
+	var err error
+	workflowScheduler, err = schedule.NewScheduler(appConfig.Schedule, appConfig.Queue, workflowRepo, notificationService)
+	return err
+}
+
+// retentionInterval — как часто удаляются устаревшие записи журналов
+const retentionInterval = time.Hour
+
+// runRetention раз в interval удаляет записи старше retention через deleteOlderThan,
+// пока не завершится ctx; what называет записи в логах. retention <= 0 отключает очистку.
+func runRetention(ctx context.Context, what string, interval, retention time.Duration, deleteOlderThan func(ctx context.Context, before time.Time) (int64, error)) {
+	if retention <= 0 {
+		return
+	}
+
+	prune := func() {
+		deleted, err := deleteOlderThan(ctx, time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("failed to prune %s: %v", what, err)
+			return
+		}
+		if deleted > 0 {
+			log.Printf("pruned %d %s older than %s", deleted, what, retention)
+		}
+	}
+
+	prune()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			prune()
+		}
+	}
+}
+
 func runServer(app *fiber.App) {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	go runRetention(ctx, "executions", retentionInterval,
+		time.Duration(appConfig.Execution.RetentionDays)*24*time.Hour, execution.NewRepository(dbConn).DeleteOlderThan)
+	// Забываем обработанные пары (event_id, workflow_id) старше STREAM_DEDUP_RETENTION_HOURS
+	go runRetention(ctx, "processed stream events", retentionInterval,
+		appConfig.Stream.DedupRetention, dedup.NewRepository(dbConn).DeleteOlderThan)
+	// Удаляем из журнала отклоненные события старше STREAM_REJECTION_RETENTION_HOURS
+	go runRetention(ctx, "rejected stream events", retentionInterval,
+		appConfig.Stream.RejectionRetention, eventschemapersistence.NewRepository(dbConn).DeleteRejectionsOlderThan)
+
 	// Запускаем stream consumer
-	if streamConsumer != nil {
-		if err := streamConsumer.Start(ctx); err != nil {
+	if workflowChanges != nil {
+		go workflowChanges.Run(ctx)
+	}
+	// DLQ и соединение NATS закрываются после остановки consumers (defer выполняются в обратном порядке)
+	if deadLetters != nil {
+		defer func() {
+			if err := deadLetters.Close(); err != nil {
+				log.Printf("failed to close dead-letter queue: %v", err)
+			}
+		}()
+	}
+	if natsConn != nil {
+		defer natsConn.Close()
+	}
+	for _, source := range streamSources {
+		if err := source.Start(ctx); err != nil {
 			log.Fatalf("failed to start stream consumer: %v", err)
 		}
 		defer func() {
-			if err := streamConsumer.Stop(); err != nil {
+			if err := source.Stop(); err != nil {
 				log.Printf("failed to stop stream consumer: %v", err)
 			}
 		}()
 	}
 
+	// Запускаем планировщик: cron-записи регистрирует только реплика-лидер
+	if workflowScheduler != nil {
+		if err := workflowScheduler.Start(ctx); err != nil {
+			log.Fatalf("failed to start scheduler: %v", err)
+		}
+		defer workflowScheduler.Stop()
+	}
+
 	go func() {
 		log.Printf("server starting on %s", appConfig.HTTP.Addr)
 		if err := app.Listen(appConfig.HTTP.Addr); err != nil {
@@ -225,7 +458,61 @@ func runServer(app *fiber.App) {
 	}
 }
 
+// runApply синхронизирует workflow из каталога с YAML/JSON-бандлами с базой данных.
+func runApply(args []string) {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	dir := fs.String("f", "", "directory with workflow bundles (*.yaml, *.yml, *.json)")
+	dryRun := fs.Bool("dry-run", false, "print the plan without applying it")
+	prune := fs.Bool("prune", false, "delete workflows that are not defined in the directory")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: notiair apply -f <dir> [--dry-run] [--prune]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if *dir == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	defs, err := apply.LoadDir(*dir)
+	if err != nil {
+		log.Fatalf("load definitions: %v", err)
+	}
+
+	initConfig()
+	initDatabase()
+
+	workflowRepo := workflow.NewDBRepository(workflowpersistence.NewRepositoryWithLimit(dbConn, appConfig.Workflow.MaxVersions))
+	// Сообщаем запущенным репликам об изменениях, чтобы они сбросили индекс триггеров
+	if store, err := stream.NewRedisStore(appConfig.Redis.URL); err == nil {
+		defer store.Close()
+		workflowRepo = workflow.WithChangeHook(workflowRepo, stream.NewWorkflowChanges(store).Notify)
+	}
+	bundleSvc := bundle.NewService(workflowRepo, templates.NewMemoryRepository(), channel.NewRepository(dbConn))
+	syncer := apply.NewSyncer(workflowRepo, bundleSvc)
+
+	ctx := context.Background()
+	plan, err := syncer.Plan(ctx, defs, *prune)
+	if err != nil {
+		log.Fatalf("plan: %v", err)
+	}
+	plan.Write(os.Stdout)
+
+	if *dryRun || !plan.HasChanges() {
+		return
+	}
+	if err := syncer.Apply(ctx, plan); err != nil {
+		log.Fatalf("apply: %v", err)
+	}
+	fmt.Println("Apply complete.")
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "apply" {
+		runApply(os.Args[2:])
+		return
+	}
+
 	initConfig()
 	initDatabase()
 	initQueue()
@@ -234,6 +521,9 @@ func main() {
 	if err := initStreamConsumer(); err != nil {
 		log.Fatalf("failed to init stream consumer: %v", err)
 	}
+	if err := initScheduler(); err != nil {
+		log.Fatalf("failed to init scheduler: %v", err)
+	}
 
 	app := buildApplication()
 	runServer(app)
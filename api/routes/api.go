@@ -16,19 +16,29 @@ func New(apiHandlers *handlers.API) *API {
 }
 
 func (a *API) Register(router fiber.Router) {
+	router.Get("/health", a.handlers.Health)
+	router.Get("/ready", a.handlers.Ready)
 	router.Post("/notifications/dispatch", a.handlers.DispatchNotification)
 	router.Get("/templates", a.handlers.ListTemplates)
 	router.Post("/templates", a.handlers.SaveTemplate)
 	router.Get("/workflows", a.handlers.ListWorkflows)
+	router.Post("/workflows/import", a.handlers.ImportWorkflow)
 	router.Get("/workflows/:id/versions", a.handlers.ListWorkflowVersions)
 	router.Get("/workflows/:id/versions/:versionId", a.handlers.GetWorkflowVersion)
+	router.Patch("/workflows/:id/versions/:versionId", a.handlers.UpdateWorkflowVersion)
+	router.Get("/workflows/:id/versions/:a/diff/:b", a.handlers.DiffWorkflowVersions)
 	router.Post("/workflows/:id/versions/:versionId/restore", a.handlers.RestoreWorkflowVersion)
+	router.Post("/workflows/:id/versions/:versionId/publish", a.handlers.PublishWorkflowVersion)
 	router.Get("/workflows/:id", a.handlers.GetWorkflow)
+	router.Get("/workflows/:id/export", a.handlers.ExportWorkflow)
+	router.Post("/workflows/:id/test", a.handlers.TestWorkflow)
 	router.Post("/workflows", a.handlers.SaveWorkflow)
 	router.Delete("/workflows/:id", a.handlers.DeleteWorkflow)
 	router.Get("/workflows/:id/storage", a.handlers.ListStorageRecords)
 	router.Get("/workflows/:id/storage/:recordId", a.handlers.GetStorageRecord)
 	router.Delete("/workflows/:id/storage/:recordId", a.handlers.DeleteStorageRecord)
+	router.Get("/workflows/:id/executions", a.handlers.ListWorkflowExecutions)
+	router.Get("/executions/:id", a.handlers.GetExecution)
 	router.Get("/queues/pending", a.handlers.ListQueue)
 	router.Get("/connectors/telegram", a.handlers.ListTelegramTokens)
 	router.Post("/connectors/telegram", a.handlers.CreateTelegramToken)
@@ -44,7 +54,22 @@ func (a *API) Register(router fiber.Router) {
 	router.Post("/connectors/:connectorId/channels", a.handlers.CreateChannel)
 	router.Put("/channels/:id", a.handlers.UpdateChannel)
 	router.Delete("/channels/:id", a.handlers.DeleteChannel)
+	router.Post("/hooks/:workflowId/:token", a.handlers.ReceiveWebhook)
 	router.Get("/stream/messages", a.handlers.GetStreamMessages)
+	router.Get("/stream/dlq", a.handlers.ListDeadLetters)
+	router.Post("/stream/dlq/:id/replay", a.handlers.ReplayDeadLetter)
+	router.Get("/stream/consumer", a.handlers.GetStreamConsumer)
+	router.Get("/stream/consumers", a.handlers.ListStreamConsumers)
+	router.Post("/stream/consumer/pause", a.handlers.PauseStreamConsumer)
+	router.Post("/stream/consumer/resume", a.handlers.ResumeStreamConsumer)
+	router.Post("/stream/consumer/offsets", a.handlers.ResetStreamOffsets)
+	router.Post("/stream/replay", a.handlers.ReplayStream)
+	router.Get("/stream/replay/:id", a.handlers.GetStreamReplay)
+	router.Get("/stream/rejections", a.handlers.ListRejectedEvents)
+	router.Get("/event-schemas", a.handlers.ListEventSchemas)
+	router.Get("/event-schemas/:eventType", a.handlers.GetEventSchema)
+	router.Put("/event-schemas/:eventType", a.handlers.PutEventSchema)
+	router.Delete("/event-schemas/:eventType", a.handlers.DeleteEventSchema)
 	
 	// WebSocket endpoint для получения событий в реальном времени
 	router.Get("/stream/ws", websocket.New(a.handlers.StreamWebSocket))
@@ -3,13 +3,26 @@ package services
 import (
 	"context"
 	"fmt"
+	"log"
+	"time"
 
+	"github.com/google/uuid"
+
+	"notiair/internal/persistence/execution"
 	"notiair/internal/persistence/outbox"
 	"notiair/internal/routing"
 )
 
+const (
+	SourceManual   = "manual"
+	SourceStream   = "stream"
+	SourceWebhook  = "webhook"
+	SourceSchedule = "schedule"
+	SourceReplay   = "replay"
+)
+
 type WorkflowRouter interface {
-	ResolveTargets(ctx context.Context, workflowID string, payload map[string]any) ([]routing.Task, error)
+	ResolveTargets(ctx context.Context, workflowID string, payload map[string]any, triggerIDs ...string) (routing.Result, error)
 }
 
 type QueueClient interface {
@@ -21,10 +34,15 @@ type OutboxRepository interface {
 	MarkQueued(ctx context.Context, id string) error
 }
 
+type ExecutionRecorder interface {
+	Create(ctx context.Context, input execution.CreateInput) (execution.Execution, error)
+}
+
 type NotificationService struct {
-	router WorkflowRouter
-	queue  QueueClient
-	outbox OutboxRepository
+	router     WorkflowRouter
+	queue      QueueClient
+	outbox     OutboxRepository
+	executions ExecutionRecorder
 }
 
 type DispatchInput struct {
@@ -32,22 +50,37 @@ type DispatchInput struct {
 	TemplateID string
 	Variables  map[string]string
 	Payload    map[string]any
+	// Source names what triggered the run (SourceManual, SourceStream, ...).
+	Source string
+	// TriggerIDs limits the run to the trigger nodes that fired; empty runs from all triggers.
+	TriggerIDs []string
 }
 
-func NewNotificationService(router WorkflowRouter, queue QueueClient, outboxRepo OutboxRepository) *NotificationService {
+func NewNotificationService(router WorkflowRouter, queue QueueClient, outboxRepo OutboxRepository, executions ExecutionRecorder) *NotificationService {
 	return &NotificationService{
-		router: router,
-		queue:  queue,
-		outbox: outboxRepo,
+		router:     router,
+		queue:      queue,
+		outbox:     outboxRepo,
+		executions: executions,
 	}
 }
 
-func (s *NotificationService) Dispatch(ctx context.Context, input DispatchInput) error {
-	tasks, err := s.router.ResolveTargets(ctx, input.WorkflowID, input.Payload)
-	if err != nil {
-		return err
+// Dispatch runs the workflow, queues its tasks and records the run in execution
+// history. The execution ID is returned even when dispatch fails.
+func (s *NotificationService) Dispatch(ctx context.Context, input DispatchInput) (string, error) {
+	executionID := uuid.NewString()
+	startedAt := time.Now()
+
+	result, err := s.router.ResolveTargets(ctx, input.WorkflowID, input.Payload, input.TriggerIDs...)
+	if err == nil {
+		err = s.enqueue(ctx, input, result.Tasks)
 	}
 
+	s.recordExecution(ctx, executionID, input, result, startedAt, err)
+	return executionID, err
+}
+
+func (s *NotificationService) enqueue(ctx context.Context, input DispatchInput, tasks []routing.Task) error {
 	for _, task := range tasks {
 		msg, err := s.outbox.CreatePending(ctx, outbox.CreateInput{
 			WorkflowID: input.WorkflowID,
@@ -75,3 +108,52 @@ func (s *NotificationService) Dispatch(ctx context.Context, input DispatchInput)
 
 	return nil
 }
+
+func (s *NotificationService) recordExecution(ctx context.Context, id string, input DispatchInput, result routing.Result, startedAt time.Time, dispatchErr error) {
+	if s.executions == nil {
+		return
+	}
+
+	source := input.Source
+	if source == "" {
+		source = SourceManual
+	}
+
+	status := execution.StatusSucceeded
+	errMsg := ""
+	if dispatchErr != nil {
+		status = execution.StatusFailed
+		errMsg = dispatchErr.Error()
+	}
+
+	steps := make([]execution.StepInput, len(result.Steps))
+	for i, st := range result.Steps {
+		steps[i] = execution.StepInput{
+			NodeID:    st.NodeID,
+			Variant:   st.Variant,
+			Input:     st.Input,
+			Output:    st.Output,
+			Decision:  st.Decision,
+			Error:     st.Error,
+			StartedAt: st.StartedAt,
+			Duration:  st.Duration,
+		}
+	}
+
+	if _, err := s.executions.Create(ctx, execution.CreateInput{
+		ID:            id,
+		WorkflowID:    input.WorkflowID,
+		VersionID:     result.VersionID,
+		VersionNumber: result.VersionNumber,
+		TriggerSource: source,
+		Input:         input.Payload,
+		Status:        status,
+		Error:         errMsg,
+		TaskCount:     len(result.Tasks),
+		StartedAt:     startedAt,
+		Duration:      time.Since(startedAt),
+		Steps:         steps,
+	}); err != nil {
+		log.Printf("failed to record execution %s for workflow %s: %v", id, input.WorkflowID, err)
+	}
+}
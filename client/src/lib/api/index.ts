@@ -1,8 +1,10 @@
 import type { QueueItem } from "$lib/types/queue";
 import type {
 	WorkflowDraft,
+	WorkflowPublication,
 	WorkflowVersion,
 	WorkflowVersionMeta,
+	WorkflowVersionUpdate,
 } from "$lib/types/workflow";
 
 const API_URL = import.meta.env.VITE_API_URL ?? "http://localhost:8080/api/v1";
@@ -20,14 +22,24 @@ export async function getWorkflow(id: string): Promise<WorkflowDraft> {
 	return res.json();
 }
 
+/**
+ * Сохраняет workflow. Для существующего workflow нужно передать revision,
+ * полученную при загрузке: если его успели изменить, вернётся 409.
+ */
 export async function saveWorkflow(
 	payload: WorkflowDraft,
+	revision?: number,
 ): Promise<WorkflowDraft> {
+	const headers: Record<string, string> = {
+		"Content-Type": "application/json",
+	};
+	if (revision !== undefined) headers["If-Match"] = `"${revision}"`;
 	const res = await fetch(`${API_URL}/workflows`, {
 		method: "POST",
-		headers: { "Content-Type": "application/json" },
+		headers,
 		body: JSON.stringify(payload),
 	});
+	if (res.status === 409) throw new Error("errors.workflowConflict");
 	if (!res.ok) throw new Error("errors.saveWorkflow");
 	return res.json();
 }
@@ -71,6 +83,75 @@ export async function restoreWorkflowVersion(
 	return res.json();
 }
 
+export async function publishWorkflowVersion(
+	workflowId: string,
+	versionId: string,
+): Promise<WorkflowDraft> {
+	const res = await fetch(
+		`${API_URL}/workflows/${workflowId}/versions/${versionId}/publish`,
+		{ method: "POST" },
+	);
+	if (!res.ok) throw new Error("errors.publishWorkflowVersion");
+	return res.json();
+}
+
+export async function updateWorkflowVersion(
+	workflowId: string,
+	versionId: string,
+	update: WorkflowVersionUpdate,
+): Promise<WorkflowVersionMeta> {
+	const res = await fetch(
+		`${API_URL}/workflows/${workflowId}/versions/${versionId}`,
+		{
+			method: "PATCH",
+			headers: { "Content-Type": "application/json" },
+			body: JSON.stringify(update),
+		},
+	);
+	if (!res.ok) throw new Error("errors.updateWorkflowVersion");
+	return res.json();
+}
+
+export async function exportWorkflow(
+	workflowId: string,
+	format: "json" | "yaml" = "json",
+): Promise<Blob> {
+	const res = await fetch(
+		`${API_URL}/workflows/${workflowId}/export?format=${format}`,
+	);
+	if (!res.ok) throw new Error("errors.exportWorkflow");
+	return res.blob();
+}
+
+export async function importWorkflow(
+	bundle: string,
+	options: {
+		format?: "json" | "yaml";
+		onConflict?: "fail" | "overwrite" | "copy";
+		channels?: Record<string, string>;
+	} = {},
+): Promise<{
+	workflow: WorkflowDraft;
+	created: boolean;
+	publication: WorkflowPublication;
+}> {
+	const params = new URLSearchParams();
+	if (options.onConflict) params.set("onConflict", options.onConflict);
+	for (const [name, id] of Object.entries(options.channels ?? {})) {
+		params.append("channel", `${name}=${id}`);
+	}
+	const res = await fetch(`${API_URL}/workflows/import?${params}`, {
+		method: "POST",
+		headers: {
+			"Content-Type":
+				options.format === "yaml" ? "application/yaml" : "application/json",
+		},
+		body: bundle,
+	});
+	if (!res.ok) throw new Error("errors.importWorkflow");
+	return res.json();
+}
+
 export type StorageRecordListItem = {
 	id: string;
 	workflowId: string;
@@ -368,3 +449,25 @@ export async function getStreamMessages(
 	if (!res.ok) throw new Error("errors.loadMessages");
 	return res.json();
 }
+
+export type EventSchemaField = {
+	path: string;
+	type?: string;
+	required: boolean;
+	description?: string;
+};
+
+export type EventSchema = {
+	eventType: string;
+	description: string;
+	schema: Record<string, unknown>;
+	fields: EventSchemaField[];
+	updatedAt: string;
+};
+
+export async function listEventSchemas(): Promise<EventSchema[]> {
+	const res = await fetch(`${API_URL}/event-schemas`);
+	if (!res.ok) throw new Error("errors.loadEventSchemas");
+	const data = await res.json();
+	return Array.isArray(data) ? data : [];
+}
@@ -20,6 +20,16 @@ export type WorkflowVersionMeta = {
 	createdAt: string;
 	isActive: boolean;
 	name: string;
+	isPublished: boolean;
+	label: string;
+	comment: string;
+	pinned: boolean;
+};
+
+export type WorkflowVersionUpdate = {
+	label?: string;
+	comment?: string;
+	pinned?: boolean;
 };
 
 export type WorkflowVersion = WorkflowVersionMeta & {
@@ -31,6 +41,9 @@ export type WorkflowVersion = WorkflowVersionMeta & {
 	restoredFromVersionId?: string;
 };
 
+/** Исполняет ли production сохранённый черновик: сохранение и импорт не публикуют */
+export type WorkflowPublication = "published" | "pending" | "unpublished";
+
 export type WorkflowDraft = {
 	id: string;
 	name: string;
@@ -41,6 +54,13 @@ export type WorkflowDraft = {
 	isActive?: boolean;
 	/** Масштаб холста редактора (например 1, 1.25); опционально для старых записей */
 	canvasZoom?: number;
+	versionNumber?: number;
+	/** Ревизия черновика; передаётся в If-Match при сохранении */
+	revision?: number;
+	/** Версия, которая исполняется в production; сохранение меняет только черновик */
+	publishedVersionId?: string;
+	/** Приходит в ответе на сохранение */
+	publication?: WorkflowPublication;
 	createdAt?: string;
 	updatedAt?: string;
 	activeNode?: WorkflowNode | null;
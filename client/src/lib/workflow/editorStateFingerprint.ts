@@ -31,6 +31,9 @@ export type WorkflowEditorCanvasNode = {
 	templatePayload?: Record<string, unknown>;
 	triggerPayload?: Record<string, unknown>;
 	eventTypes?: string[];
+	topic?: string;
+	triggerKind?: "manual" | "stream" | "webhook" | "schedule";
+	triggerSettings?: Record<string, unknown>;
 	storageMode?: "raw" | "rendered";
 };
 
@@ -76,6 +79,15 @@ function buildNodeConfig(
 		config.eventTypes = node.eventTypes;
 	}
 
+	if (node.variant === "trigger" && node.topic) {
+		config.topic = node.topic;
+	}
+
+	if (node.variant === "trigger") {
+		Object.assign(config, node.triggerSettings);
+		config.triggerKind = node.triggerKind || "manual";
+	}
+
 	if (node.variant === "storage") {
 		config.storageMode = node.storageMode || "raw";
 	}
@@ -0,0 +1,89 @@
+import type { EventSchema, EventSchemaField } from "$lib/api";
+
+/** Ключи payload stream-события, которые есть всегда, независимо от схемы */
+export const STREAM_PAYLOAD_FIELDS: EventSchemaField[] = [
+	{ path: "event_id", type: "string", required: true },
+	{ path: "event_type", type: "string", required: true },
+	{ path: "occurred_at", type: "string", required: false },
+	{ path: "topic", type: "string", required: true },
+	{ path: "context", type: "object", required: false },
+	{ path: "metadata", type: "object", required: false },
+];
+
+const PLACEHOLDER_RE = /\{\{([^}]+)\}\}/g;
+
+/** Пути плейсхолдеров {{path}} шаблона без повторов, в порядке появления */
+export function extractPlaceholders(body: string): string[] {
+	const paths: string[] = [];
+	for (const match of body.matchAll(PLACEHOLDER_RE)) {
+		const path = match[1].trim();
+		if (path && !paths.includes(path)) paths.push(path);
+	}
+	return paths;
+}
+
+/** Сопоставление event_type с шаблоном триггера (glob: *, ?, [...]) как на сервере */
+export function matchesEventType(pattern: string, eventType: string): boolean {
+	if (!/[*?[\\]/.test(pattern)) return pattern === eventType;
+	let source = "";
+	for (let i = 0; i < pattern.length; i++) {
+		const ch = pattern[i];
+		if (ch === "*") source += ".*";
+		else if (ch === "?") source += ".";
+		else if (ch === "\\" && i + 1 < pattern.length) {
+			i++;
+			source += pattern[i].replace(/[.*+?^${}()|[\]\\]/g, "\\$&");
+		} else if (ch === "[") {
+			const end = pattern.indexOf("]", i + 1);
+			if (end < 0) return false;
+			let set = pattern.slice(i + 1, end).replace(/\\/g, "\\\\");
+			if (set.startsWith("^")) set = `\\${set}`;
+			source += `[${set}]`;
+			i = end;
+		} else source += ch.replace(/[.*+?^${}()|[\]\\]/g, "\\$&");
+	}
+	try {
+		return new RegExp(`^${source}$`).test(eventType);
+	} catch {
+		return false;
+	}
+}
+
+/**
+ * Поля схем, подходящих под event_type триггеров. Пустой массив — ни у одного
+ * event_type нет схемы, и проверять плейсхолдеры не по чему.
+ */
+export function schemaFieldsFor(
+	schemas: EventSchema[],
+	eventTypes: string[],
+): EventSchemaField[] {
+	const byPath = new Map<string, EventSchemaField>();
+	for (const schema of schemas) {
+		if (!eventTypes.some((p) => matchesEventType(p, schema.eventType))) continue;
+		for (const field of schema.fields) {
+			const known = byPath.get(field.path);
+			// Поле обязательно, только если оно обязательно во всех схемах
+			byPath.set(
+				field.path,
+				known
+					? { ...known, required: known.required && field.required }
+					: field,
+			);
+		}
+	}
+	if (byPath.size === 0) return [];
+	for (const field of STREAM_PAYLOAD_FIELDS) {
+		if (!byPath.has(field.path)) byPath.set(field.path, field);
+	}
+	return [...byPath.values()].sort((a, b) => a.path.localeCompare(b.path));
+}
+
+/** Плейсхолдеры шаблона, которых нет среди полей схемы */
+export function unknownPlaceholders(
+	body: string,
+	fields: EventSchemaField[],
+): string[] {
+	if (!body || fields.length === 0) return [];
+	const known = new Set(fields.map((f) => f.path));
+	return extractPlaceholders(body).filter((path) => !known.has(path));
+}